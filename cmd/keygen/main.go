@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/dbvitor/chat-go/pkg/auth"
+)
+
+// keygen prints a new SESSION_KEYS value. Pass the current value with -rotate
+// to keep it after the new key, so existing sessions stay valid.
+func main() {
+	rotate := flag.String("rotate", "", "current SESSION_KEYS value to keep after the new key")
+	keep := flag.Int("keep", 2, "number of keys to keep when rotating, including the new one")
+	flag.Parse()
+
+	key, err := auth.GenerateSessionKey()
+	if err != nil {
+		log.Fatalf("Failed to generate session key: %v", err)
+	}
+
+	keys := []string{key}
+	for _, previous := range strings.Split(*rotate, ",") {
+		previous = strings.TrimSpace(previous)
+		if previous != "" && len(keys) < *keep {
+			keys = append(keys, previous)
+		}
+	}
+
+	if _, err := auth.ParseSessionKeys(strings.Join(keys, ",")); err != nil {
+		log.Fatalf("Invalid current keys: %v", err)
+	}
+
+	fmt.Printf("SESSION_KEYS=%s\n", strings.Join(keys, ","))
+}
@@ -1,15 +1,20 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/dbvitor/chat-go/internal/config"
 	"github.com/dbvitor/chat-go/internal/database"
 	"github.com/dbvitor/chat-go/internal/handlers"
 	"github.com/dbvitor/chat-go/pkg/auth"
 	"github.com/dbvitor/chat-go/pkg/broker"
+	"github.com/dbvitor/chat-go/pkg/notify"
+	"github.com/dbvitor/chat-go/pkg/storage"
 	"github.com/joho/godotenv"
 )
 
@@ -57,11 +62,40 @@ func main() {
 	}
 	log.Printf("Successfully registered consumer for stock results queue: %s", resultQueue)
 
+	// Consume link previews
+	unfurlResults, err := rabbitMQ.ConsumeUnfurlResults()
+	if err != nil {
+		log.Fatalf("Failed to consume unfurl results: %v", err)
+	}
+
 	// Initialize authentication
-	auth.Initialize()
+	err = auth.Initialize(database.NewSessionRepository(database.DB), database.NewAccessTokenRepository(database.DB))
+	if err != nil {
+		log.Fatalf("Failed to initialize authentication: %v", err)
+	}
+
+	// Initialize attachment storage
+	store, err := storage.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	// Initialize notifications, used for password reset links
+	notifier, err := notify.New(
+		config.String("NOTIFIER_BACKEND", "log"),
+		config.String("NOTIFIER_FILE", "./data/notifications.log"),
+	)
+	if err != nil {
+		log.Fatalf("Failed to initialize notifier: %v", err)
+	}
 
 	// Create and start HTTP server
-	server := handlers.NewServer(database.DB, rabbitMQ, stockResults)
+	server := handlers.NewServer(database.DB, rabbitMQ, stockResults, unfurlResults, store, notifier)
+
+	// Deliver scheduled messages and reminders
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go runScheduler(ctx, database.DB, server, config.Duration("SCHEDULER_INTERVAL", 15*time.Second))
 
 	// Handle graceful shutdown
 	c := make(chan os.Signal, 1)
@@ -70,6 +104,7 @@ func main() {
 	go func() {
 		<-c
 		log.Println("Shutting down...")
+		cancel()
 		rabbitMQ.Close()
 		database.Close()
 		os.Exit(0)
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/database"
+	"github.com/dbvitor/chat-go/internal/handlers"
+)
+
+// runScheduler delivers due scheduled messages every interval until ctx is
+// done. Replicas compete for a database advisory lock and only the one
+// holding it delivers; if its connection drops, another replica takes over
+// on its next tick. Messages are claimed with row locks, so a replica that
+// briefly overlaps with a new leader still can't deliver one twice.
+func runScheduler(ctx context.Context, db *sql.DB, server *handlers.Server, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lock *database.AdvisoryLock
+	defer func() {
+		if lock != nil {
+			lock.Release()
+		}
+	}()
+
+	for {
+		if lock != nil {
+			if err := lock.Check(ctx); err != nil {
+				log.Printf("Lost the scheduler lock: %v", err)
+				lock.Release()
+				lock = nil
+			}
+		}
+		if lock == nil {
+			var err error
+			lock, err = database.TryAdvisoryLock(ctx, db, database.SchedulerLockID)
+			if err != nil {
+				log.Printf("Error taking the scheduler lock: %v", err)
+			} else if lock != nil {
+				log.Println("This replica now delivers scheduled messages")
+			}
+		}
+
+		if lock != nil {
+			delivered, err := server.DeliverScheduledMessages(time.Now())
+			if err != nil {
+				log.Printf("Error delivering scheduled messages: %v", err)
+			} else if delivered > 0 {
+				log.Printf("Delivered %d scheduled messages", delivered)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
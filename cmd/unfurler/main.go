@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/internal/services"
+	"github.com/dbvitor/chat-go/pkg/broker"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// Load environment variables
+	err := godotenv.Load()
+	if err != nil {
+		log.Println("Warning: .env file not found")
+	}
+
+	// Initialize RabbitMQ connection
+	rabbitMQ, err := broker.NewRabbitMQ()
+	if err != nil {
+		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
+	}
+	defer rabbitMQ.Close()
+
+	// Create unfurl service
+	unfurlService := services.NewUnfurlService()
+
+	// Consume unfurl requests
+	unfurlRequests, err := rabbitMQ.ConsumeUnfurlRequests()
+	if err != nil {
+		log.Fatalf("Failed to consume unfurl requests: %v", err)
+	}
+
+	// Handle graceful shutdown
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-c
+		log.Println("Shutting down...")
+		rabbitMQ.Close()
+		os.Exit(0)
+	}()
+
+	log.Println("Unfurler started. Waiting for requests...")
+
+	// Process unfurl requests
+	for delivery := range unfurlRequests {
+		go func(delivery []byte) {
+			// Parse request
+			var request models.UnfurlRequest
+			if err := json.Unmarshal(delivery, &request); err != nil {
+				log.Printf("Error parsing request: %v", err)
+				return
+			}
+
+			log.Printf("Unfurling %s for message %s", request.URL, request.MessageID)
+
+			result := &models.UnfurlResult{
+				MessageID:  request.MessageID,
+				ChatroomID: request.ChatroomID,
+			}
+
+			// Fetch the preview; the service enforces its own timeouts
+			preview, err := unfurlService.Unfurl(context.Background(), request.URL)
+			if err != nil {
+				log.Printf("Error unfurling %s: %v", request.URL, err)
+				result.Error = err.Error()
+			} else {
+				preview.MessageID = request.MessageID
+				result.Preview = preview
+			}
+
+			// Publish result
+			if err := rabbitMQ.PublishUnfurlResult(result); err != nil {
+				log.Printf("Error publishing result: %v", err)
+			}
+		}(delivery.Body)
+	}
+}
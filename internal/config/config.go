@@ -0,0 +1,101 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// String returns the value of an environment variable, or a default value if unset
+func String(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// Int returns an environment variable parsed as an integer, or a default value
+func Int(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using default: %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// Float returns an environment variable parsed as a float, or a default value
+func Float(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using default: %g", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// Bool returns an environment variable parsed as a boolean, or a default value
+func Bool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using default: %t", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// Duration returns an environment variable parsed as a duration (e.g. "15m"), or a default value
+func Duration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid value for %s: %q, using default: %s", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// List returns a comma-separated environment variable as a slice, or a default value
+func List(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Production reports whether the application runs in production mode, set
+// with APP_ENV=production
+func Production() bool {
+	return strings.EqualFold(String("APP_ENV", "development"), "production")
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/lib/pq"
+)
+
+// AccessTokenRepository handles personal access token database operations
+type AccessTokenRepository struct {
+	db *sql.DB
+}
+
+// NewAccessTokenRepository creates a new access token repository
+func NewAccessTokenRepository(db *sql.DB) *AccessTokenRepository {
+	return &AccessTokenRepository{db: db}
+}
+
+// Create saves a new token and fills in its ID
+func (r *AccessTokenRepository) Create(token *models.AccessToken) error {
+	query := `INSERT INTO access_tokens (user_id, name, scopes, prefix, token_hash, created_at, expires_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7)
+	          RETURNING id`
+
+	return r.db.QueryRow(
+		query,
+		token.UserID,
+		token.Name,
+		pq.Array(token.Scopes),
+		token.Prefix,
+		token.TokenHash,
+		token.CreatedAt,
+		token.ExpiresAt,
+	).Scan(&token.ID)
+}
+
+// GetByHash retrieves a token by the hash of its plaintext value
+func (r *AccessTokenRepository) GetByHash(hash string) (*models.AccessToken, error) {
+	query := `SELECT id, user_id, name, scopes, prefix, token_hash, created_at, last_used_at, expires_at, revoked_at
+	          FROM access_tokens
+	          WHERE token_hash = $1`
+
+	return scanAccessToken(r.db.QueryRow(query, hash))
+}
+
+// GetByUserID retrieves the tokens of a user that haven't been revoked, newest first
+func (r *AccessTokenRepository) GetByUserID(userID string) ([]*models.AccessToken, error) {
+	query := `SELECT id, user_id, name, scopes, prefix, token_hash, created_at, last_used_at, expires_at, revoked_at
+	          FROM access_tokens
+	          WHERE user_id = $1 AND revoked_at IS NULL
+	          ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*models.AccessToken
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// CountActiveByUserID counts the tokens of a user that are neither revoked nor expired
+func (r *AccessTokenRepository) CountActiveByUserID(userID string) (int, error) {
+	query := `SELECT COUNT(*)
+	          FROM access_tokens
+	          WHERE user_id = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > $2)`
+
+	var count int
+	err := r.db.QueryRow(query, userID, time.Now()).Scan(&count)
+	return count, err
+}
+
+// Touch records that a token was just used
+func (r *AccessTokenRepository) Touch(id string, lastUsedAt time.Time) error {
+	query := `UPDATE access_tokens SET last_used_at = $1 WHERE id = $2`
+	_, err := r.db.Exec(query, lastUsedAt, id)
+	return err
+}
+
+// Revoke disables a token of a user. It reports whether an active token was revoked.
+func (r *AccessTokenRepository) Revoke(userID, id string) (bool, error) {
+	query := `UPDATE access_tokens
+	          SET revoked_at = $1
+	          WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL`
+
+	result, err := r.db.Exec(query, time.Now(), id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// scanAccessToken reads a token from a row of the access token queries
+func scanAccessToken(row interface{ Scan(...interface{}) error }) (*models.AccessToken, error) {
+	var token models.AccessToken
+	var lastUsedAt, expiresAt, revokedAt sql.NullTime
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		pq.Array(&token.Scopes),
+		&token.Prefix,
+		&token.TokenHash,
+		&token.CreatedAt,
+		&lastUsedAt,
+		&expiresAt,
+		&revokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+
+	return &token, nil
+}
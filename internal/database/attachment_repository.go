@@ -0,0 +1,179 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/lib/pq"
+)
+
+// AttachmentRepository handles attachment database operations
+type AttachmentRepository struct {
+	db *sql.DB
+}
+
+// NewAttachmentRepository creates a new attachment repository
+func NewAttachmentRepository(db *sql.DB) *AttachmentRepository {
+	return &AttachmentRepository{db: db}
+}
+
+// Create adds a new attachment to the database
+func (r *AttachmentRepository) Create(attachment *models.Attachment) error {
+	query := `INSERT INTO attachments (user_id, chatroom_id, filename, content_type, size, storage_key, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7)
+	          RETURNING id`
+
+	err := r.db.QueryRow(
+		query,
+		attachment.UserID,
+		attachment.ChatroomID,
+		attachment.Filename,
+		attachment.ContentType,
+		attachment.Size,
+		attachment.StorageKey,
+		attachment.CreatedAt,
+	).Scan(&attachment.ID)
+
+	return err
+}
+
+// GetByID retrieves an attachment by ID
+func (r *AttachmentRepository) GetByID(id string) (*models.Attachment, error) {
+	query := `SELECT id, COALESCE(message_id::text, ''), user_id, chatroom_id, filename, content_type, size, storage_key, created_at
+	          FROM attachments
+	          WHERE id = $1`
+
+	var attachment models.Attachment
+	err := r.db.QueryRow(query, id).Scan(
+		&attachment.ID,
+		&attachment.MessageID,
+		&attachment.UserID,
+		&attachment.ChatroomID,
+		&attachment.Filename,
+		&attachment.ContentType,
+		&attachment.Size,
+		&attachment.StorageKey,
+		&attachment.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &attachment, nil
+}
+
+// GetByMessageIDs retrieves the attachments of several messages, grouped by message ID
+func (r *AttachmentRepository) GetByMessageIDs(messageIDs []string) (map[string][]*models.Attachment, error) {
+	query := `SELECT id, message_id, user_id, chatroom_id, filename, content_type, size, storage_key, created_at
+	          FROM attachments
+	          WHERE message_id = ANY($1)
+	          ORDER BY created_at`
+
+	rows, err := r.db.Query(query, pq.Array(messageIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := make(map[string][]*models.Attachment)
+	for rows.Next() {
+		var attachment models.Attachment
+		err := rows.Scan(
+			&attachment.ID,
+			&attachment.MessageID,
+			&attachment.UserID,
+			&attachment.ChatroomID,
+			&attachment.Filename,
+			&attachment.ContentType,
+			&attachment.Size,
+			&attachment.StorageKey,
+			&attachment.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		attachments[attachment.MessageID] = append(attachments[attachment.MessageID], &attachment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// GetByUserID retrieves every attachment uploaded by a user, oldest first
+func (r *AttachmentRepository) GetByUserID(userID string) ([]*models.Attachment, error) {
+	query := `SELECT id, COALESCE(message_id::text, ''), user_id, chatroom_id, filename, content_type, size, storage_key, created_at
+	          FROM attachments
+	          WHERE user_id = $1
+	          ORDER BY created_at`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []*models.Attachment
+	for rows.Next() {
+		var attachment models.Attachment
+		err := rows.Scan(
+			&attachment.ID,
+			&attachment.MessageID,
+			&attachment.UserID,
+			&attachment.ChatroomID,
+			&attachment.Filename,
+			&attachment.ContentType,
+			&attachment.Size,
+			&attachment.StorageKey,
+			&attachment.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, &attachment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// Delete removes an attachment from the database
+func (r *AttachmentRepository) Delete(id string) error {
+	query := `DELETE FROM attachments WHERE id = $1`
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+// DeleteUnsentBefore removes the attachments that were uploaded before the
+// cutoff but never sent with a message, returning their storage keys
+func (r *AttachmentRepository) DeleteUnsentBefore(before time.Time) ([]string, error) {
+	query := `DELETE FROM attachments WHERE message_id IS NULL AND created_at < $1 RETURNING storage_key`
+
+	rows, err := r.db.Query(query, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var storageKeys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		storageKeys = append(storageKeys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return storageKeys, nil
+}
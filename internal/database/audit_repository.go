@@ -0,0 +1,95 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// AuditRepository handles audit log database operations
+type AuditRepository struct {
+	db *sql.DB
+}
+
+// NewAuditRepository creates a new audit repository
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// Create adds an entry to the audit log and fills in its ID
+func (r *AuditRepository) Create(entry *models.AuditEntry) error {
+	details, err := json.Marshal(entry.Details)
+	if err != nil {
+		return err
+	}
+	if entry.Details == nil {
+		details = []byte("{}")
+	}
+
+	query := `INSERT INTO audit_log (actor_id, actor_username, action, target_type, target_id, details, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7)
+	          RETURNING id`
+
+	return r.db.QueryRow(
+		query,
+		entry.ActorID,
+		entry.ActorUsername,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		details,
+		entry.CreatedAt,
+	).Scan(&entry.ID)
+}
+
+// GetRecent retrieves audit entries, newest first. If before is set, only
+// entries created before it are returned, for pagination.
+func (r *AuditRepository) GetRecent(before time.Time, limit int) ([]*models.AuditEntry, error) {
+	query := `SELECT id, actor_id, actor_username, action, target_type, target_id, details, created_at
+	          FROM audit_log
+	          WHERE ($1::timestamp IS NULL OR created_at < $1)
+	          ORDER BY created_at DESC
+	          LIMIT $2`
+
+	var beforeArg interface{}
+	if !before.IsZero() {
+		beforeArg = before
+	}
+
+	rows, err := r.db.Query(query, beforeArg, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.AuditEntry
+	for rows.Next() {
+		var entry models.AuditEntry
+		var details []byte
+		err := rows.Scan(
+			&entry.ID,
+			&entry.ActorID,
+			&entry.ActorUsername,
+			&entry.Action,
+			&entry.TargetType,
+			&entry.TargetID,
+			&details,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(details, &entry.Details); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
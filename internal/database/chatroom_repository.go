@@ -2,6 +2,8 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/dbvitor/chat-go/internal/models"
@@ -19,13 +21,14 @@ func NewChatroomRepository(db *sql.DB) *ChatroomRepository {
 
 // Create adds a new chatroom to the database
 func (r *ChatroomRepository) Create(chatroom *models.Chatroom) error {
-	query := `INSERT INTO chatrooms (name, created_at, updated_at) 
-	          VALUES ($1, $2, $3) 
+	query := `INSERT INTO chatrooms (name, created_by, created_at, updated_at) 
+	          VALUES ($1, $2, $3, $4) 
 	          RETURNING id`
 
 	err := r.db.QueryRow(
 		query,
 		chatroom.Name,
+		sql.NullString{String: chatroom.CreatedBy, Valid: chatroom.CreatedBy != ""},
 		chatroom.CreatedAt,
 		chatroom.UpdatedAt,
 	).Scan(&chatroom.ID)
@@ -35,99 +38,309 @@ func (r *ChatroomRepository) Create(chatroom *models.Chatroom) error {
 
 // GetByID retrieves a chatroom by ID
 func (r *ChatroomRepository) GetByID(id string) (*models.Chatroom, error) {
-	query := `SELECT id, name, created_at, updated_at 
+	query := `SELECT id, name, topic, description, created_by, archived_at, created_at, updated_at 
 	          FROM chatrooms 
 	          WHERE id = $1`
 
-	var chatroom models.Chatroom
-	err := r.db.QueryRow(query, id).Scan(
-		&chatroom.ID,
-		&chatroom.Name,
-		&chatroom.CreatedAt,
-		&chatroom.UpdatedAt,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return &chatroom, nil
+	return scanChatroom(r.db.QueryRow(query, id))
 }
 
 // GetByName retrieves a chatroom by name
 func (r *ChatroomRepository) GetByName(name string) (*models.Chatroom, error) {
-	query := `SELECT id, name, created_at, updated_at 
+	query := `SELECT id, name, topic, description, created_by, archived_at, created_at, updated_at 
 	          FROM chatrooms 
 	          WHERE name = $1`
 
-	var chatroom models.Chatroom
-	err := r.db.QueryRow(query, name).Scan(
-		&chatroom.ID,
-		&chatroom.Name,
-		&chatroom.CreatedAt,
-		&chatroom.UpdatedAt,
-	)
+	return scanChatroom(r.db.QueryRow(query, name))
+}
 
-	if err != nil {
-		return nil, err
+// chatroomOrders maps the orders of chatroom listings to ORDER BY clauses;
+// the name and ID break ties so pages don't overlap
+var chatroomOrders = map[string]string{
+	models.ChatroomSortName:     "LOWER(c.name), c.id",
+	models.ChatroomSortActivity: "lm.created_at DESC NULLS LAST, LOWER(c.name), c.id",
+	models.ChatroomSortMembers:  "member_count DESC, LOWER(c.name), c.id",
+}
+
+// Joins computing the member counts of chatroom listings. Members are the
+// users who created, posted in or read a room, as in GetMemberships. Sorting
+// by members needs every room's count, which one pass over the tables
+// aggregates; other orders count the listed rooms only, using the
+// chatroom_id indexes.
+const (
+	aggregatedMemberCounts = `LEFT JOIN (SELECT chatroom_id, COUNT(*) AS member_count
+	                            FROM (SELECT id AS chatroom_id, created_by AS user_id FROM chatrooms WHERE created_by IS NOT NULL
+	                                  UNION SELECT chatroom_id, user_id FROM messages
+	                                  UNION SELECT chatroom_id, user_id FROM read_receipts) members
+	                            GROUP BY chatroom_id) rc
+	                 ON rc.chatroom_id = c.id`
+	lateralMemberCounts = `LEFT JOIN LATERAL (SELECT COUNT(*) AS member_count
+	                                    FROM (SELECT c.created_by AS user_id WHERE c.created_by IS NOT NULL
+	                                          UNION SELECT m.user_id FROM messages m WHERE m.chatroom_id = c.id
+	                                          UNION SELECT rr.user_id FROM read_receipts rr WHERE rr.chatroom_id = c.id) members) rc
+	                 ON TRUE`
+)
+
+// likeEscaper escapes the wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// GetSummaries retrieves a page of chatrooms with their member counts and
+// latest messages, whose content is cut to previewLength characters. The
+// latest message of each room is found with the (chatroom_id, created_at) index.
+func (r *ChatroomRepository) GetSummaries(query models.ChatroomQuery, previewLength int) ([]*models.ChatroomSummary, error) {
+	order, ok := chatroomOrders[query.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown chatroom order %q", query.Sort)
 	}
 
-	return &chatroom, nil
-}
+	args := []interface{}{previewLength, query.Limit, query.Offset}
+	where := ""
+	if query.Name != "" {
+		args = append(args, "%"+likeEscaper.Replace(query.Name)+"%")
+		where = fmt.Sprintf("WHERE c.name ILIKE $%d", len(args))
+	}
 
-// GetAll retrieves all chatrooms
-func (r *ChatroomRepository) GetAll() ([]*models.Chatroom, error) {
-	query := `SELECT id, name, created_at, updated_at 
-	          FROM chatrooms 
-	          ORDER BY name`
+	memberCounts := lateralMemberCounts
+	if query.Sort == models.ChatroomSortMembers {
+		memberCounts = aggregatedMemberCounts
+	}
+
+	sqlQuery := fmt.Sprintf(`SELECT c.id, c.name, c.topic, c.description, c.created_by, c.archived_at, c.created_at, c.updated_at,
+	                 COALESCE(rc.member_count, 0) AS member_count,
+	                 lm.id, lm.username, LEFT(lm.content, $1), lm.created_at
+	          FROM chatrooms c
+	          %s
+	          LEFT JOIN LATERAL (SELECT m.id, m.username, m.content, m.created_at
+	                             FROM messages m
+	                             WHERE m.chatroom_id = c.id
+	                             ORDER BY m.created_at DESC
+	                             LIMIT 1) lm ON TRUE
+	          %s
+	          ORDER BY %s
+	          LIMIT $2 OFFSET $3`, memberCounts, where, order)
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.Query(sqlQuery, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var chatrooms []*models.Chatroom
+	var summaries []*models.ChatroomSummary
 	for rows.Next() {
 		var chatroom models.Chatroom
+		var summary models.ChatroomSummary
+		var createdBy, lastID, lastUsername, lastContent sql.NullString
+		var archivedAt, lastCreatedAt sql.NullTime
 		err := rows.Scan(
 			&chatroom.ID,
 			&chatroom.Name,
+			&chatroom.Topic,
+			&chatroom.Description,
+			&createdBy,
+			&archivedAt,
 			&chatroom.CreatedAt,
 			&chatroom.UpdatedAt,
+			&summary.MemberCount,
+			&lastID,
+			&lastUsername,
+			&lastContent,
+			&lastCreatedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
-		chatrooms = append(chatrooms, &chatroom)
+
+		chatroom.CreatedBy = createdBy.String
+		if archivedAt.Valid {
+			chatroom.ArchivedAt = &archivedAt.Time
+		}
+		summary.Chatroom = &chatroom
+		if lastID.Valid {
+			summary.LastMessage = &models.MessagePreview{
+				ID:        lastID.String,
+				Username:  lastUsername.String,
+				Content:   lastContent.String,
+				CreatedAt: lastCreatedAt.Time,
+			}
+		}
+		summaries = append(summaries, &summary)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return chatrooms, nil
+	return summaries, nil
+}
+
+// CountByCreator counts the chatrooms created by a user
+func (r *ChatroomRepository) CountByCreator(userID string) (int, error) {
+	query := `SELECT COUNT(*) FROM chatrooms WHERE created_by = $1`
+
+	var count int
+	err := r.db.QueryRow(query, userID).Scan(&count)
+	return count, err
 }
 
-// Update updates chatroom information
+// GetMemberships retrieves the chatrooms a user created, posted in or read,
+// ordered by name
+func (r *ChatroomRepository) GetMemberships(userID string) ([]*models.Membership, error) {
+	query := `SELECT c.id, c.name, COALESCE(c.created_by = $1, FALSE),
+	                 COUNT(m.id), MIN(m.created_at), MAX(m.created_at), rr.read_at
+	          FROM chatrooms c
+	          LEFT JOIN messages m ON m.chatroom_id = c.id AND m.user_id = $1
+	          LEFT JOIN read_receipts rr ON rr.chatroom_id = c.id AND rr.user_id = $1
+	          WHERE c.created_by = $1 OR m.id IS NOT NULL OR rr.user_id IS NOT NULL
+	          GROUP BY c.id, c.name, c.created_by, rr.read_at
+	          ORDER BY c.name`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memberships []*models.Membership
+	for rows.Next() {
+		var membership models.Membership
+		var firstMessageAt, lastMessageAt, lastReadAt sql.NullTime
+		err := rows.Scan(
+			&membership.ChatroomID,
+			&membership.ChatroomName,
+			&membership.Creator,
+			&membership.MessageCount,
+			&firstMessageAt,
+			&lastMessageAt,
+			&lastReadAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if firstMessageAt.Valid {
+			membership.FirstMessageAt = &firstMessageAt.Time
+		}
+		if lastMessageAt.Valid {
+			membership.LastMessageAt = &lastMessageAt.Time
+		}
+		if lastReadAt.Valid {
+			membership.LastReadAt = &lastReadAt.Time
+		}
+		memberships = append(memberships, &membership)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return memberships, nil
+}
+
+// Update saves the name, topic and description of a chatroom
 func (r *ChatroomRepository) Update(chatroom *models.Chatroom) error {
 	query := `UPDATE chatrooms 
-	          SET name = $1, updated_at = $2 
-	          WHERE id = $3`
+	          SET name = $1, topic = $2, description = $3, updated_at = $4 
+	          WHERE id = $5`
 
+	chatroom.UpdatedAt = time.Now()
 	_, err := r.db.Exec(
 		query,
 		chatroom.Name,
-		time.Now(),
+		chatroom.Topic,
+		chatroom.Description,
+		chatroom.UpdatedAt,
 		chatroom.ID,
 	)
 
 	return err
 }
 
-// Delete removes a chatroom from the database
-func (r *ChatroomRepository) Delete(id string) error {
-	query := `DELETE FROM chatrooms WHERE id = $1`
-	_, err := r.db.Exec(query, id)
+// SetArchived archives a chatroom at archivedAt, or restores it when
+// archivedAt is nil
+func (r *ChatroomRepository) SetArchived(id string, archivedAt *time.Time) error {
+	query := `UPDATE chatrooms SET archived_at = $1, updated_at = $2 WHERE id = $3`
+	_, err := r.db.Exec(query, archivedAt, time.Now(), id)
 	return err
 }
+
+// Delete removes a chatroom along with its messages and everything that
+// refers to them, in one transaction. It returns the storage keys of the
+// room's attachments, whose files the caller should delete.
+func (r *ChatroomRepository) Delete(id string) ([]string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`DELETE FROM attachments WHERE chatroom_id = $1 RETURNING storage_key`, id)
+	if err != nil {
+		return nil, err
+	}
+	var storageKeys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		storageKeys = append(storageKeys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Children of the room's messages first, then the messages and the room
+	queries := []string{
+		`DELETE FROM notifications WHERE chatroom_id = $1`,
+		`DELETE FROM read_receipts WHERE chatroom_id = $1`,
+		`DELETE FROM pins WHERE chatroom_id = $1`,
+		`DELETE FROM scheduled_messages WHERE chatroom_id = $1`,
+		`DELETE FROM mentions WHERE message_id IN (SELECT id FROM messages WHERE chatroom_id = $1)`,
+		`DELETE FROM link_previews WHERE message_id IN (SELECT id FROM messages WHERE chatroom_id = $1)`,
+		`DELETE FROM messages WHERE chatroom_id = $1`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query, id); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM chatrooms WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if affected == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return storageKeys, tx.Commit()
+}
+
+// scanChatroom reads a chatroom from a row of the chatroom queries
+func scanChatroom(row interface{ Scan(...interface{}) error }) (*models.Chatroom, error) {
+	var chatroom models.Chatroom
+	var createdBy sql.NullString
+	var archivedAt sql.NullTime
+	err := row.Scan(
+		&chatroom.ID,
+		&chatroom.Name,
+		&chatroom.Topic,
+		&chatroom.Description,
+		&createdBy,
+		&archivedAt,
+		&chatroom.CreatedAt,
+		&chatroom.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	chatroom.CreatedBy = createdBy.String
+	if archivedAt.Valid {
+		chatroom.ArchivedAt = &archivedAt.Time
+	}
+	return &chatroom, nil
+}
@@ -2,10 +2,11 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // DB is the database connection
@@ -14,6 +15,13 @@ var DB *sql.DB
 // BotUserID is the ID of the bot user in the database
 const BotUserID = "00000000-0000-0000-0000-000000000000"
 
+// DeletedUserID is the ID of the placeholder user that messages of deleted
+// accounts are attributed to
+const DeletedUserID = "00000000-0000-0000-0000-000000000001"
+
+// DeletedUsername is shown as the author of messages of deleted accounts
+const DeletedUsername = "Deleted user"
+
 // Initialize sets up the database connection
 func Initialize() error {
 	dbHost := os.Getenv("DB_HOST")
@@ -48,9 +56,21 @@ func createTables() error {
 		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 		username VARCHAR(50) UNIQUE NOT NULL,
 		password VARCHAR(100) NOT NULL,
+		role VARCHAR(20) NOT NULL DEFAULT 'user',
+		display_name VARCHAR(50) NOT NULL DEFAULT '',
+		avatar_key VARCHAR(255) NOT NULL DEFAULT '',
+		status_text VARCHAR(140) NOT NULL DEFAULT '',
+		time_zone VARCHAR(64) NOT NULL DEFAULT '',
+		disabled_at TIMESTAMP,
 		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
 		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
-	);`
+	);
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name VARCHAR(50) NOT NULL DEFAULT '';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_key VARCHAR(255) NOT NULL DEFAULT '';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS status_text VARCHAR(140) NOT NULL DEFAULT '';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS time_zone VARCHAR(64) NOT NULL DEFAULT '';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP;`
 
 	_, err := DB.Exec(userTableQuery)
 	if err != nil {
@@ -62,9 +82,17 @@ func createTables() error {
 	CREATE TABLE IF NOT EXISTS chatrooms (
 		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 		name VARCHAR(50) UNIQUE NOT NULL,
+		topic VARCHAR(250) NOT NULL DEFAULT '',
+		description TEXT NOT NULL DEFAULT '',
+		created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		archived_at TIMESTAMP,
 		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
 		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
-	);`
+	);
+	ALTER TABLE chatrooms ADD COLUMN IF NOT EXISTS created_by UUID REFERENCES users(id) ON DELETE SET NULL;
+	ALTER TABLE chatrooms ADD COLUMN IF NOT EXISTS topic VARCHAR(250) NOT NULL DEFAULT '';
+	ALTER TABLE chatrooms ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+	ALTER TABLE chatrooms ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP;`
 
 	_, err = DB.Exec(chatroomTableQuery)
 	if err != nil {
@@ -88,6 +116,249 @@ func createTables() error {
 		return err
 	}
 
+	// Index used to load a room's history and count unread messages
+	_, err = DB.Exec("CREATE INDEX IF NOT EXISTS idx_messages_chatroom_created_at ON messages (chatroom_id, created_at);")
+	if err != nil {
+		return err
+	}
+
+	// Full-text search index on message content
+	_, err = DB.Exec("CREATE INDEX IF NOT EXISTS idx_messages_content_search ON messages USING GIN (to_tsvector('english', content));")
+	if err != nil {
+		return err
+	}
+
+	// Create read receipts table
+	readReceiptTableQuery := `
+	CREATE TABLE IF NOT EXISTS read_receipts (
+		user_id UUID NOT NULL REFERENCES users(id),
+		chatroom_id UUID NOT NULL REFERENCES chatrooms(id),
+		message_id UUID NOT NULL REFERENCES messages(id),
+		message_created_at TIMESTAMP NOT NULL,
+		read_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (user_id, chatroom_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_read_receipts_chatroom_id ON read_receipts (chatroom_id);`
+
+	_, err = DB.Exec(readReceiptTableQuery)
+	if err != nil {
+		return err
+	}
+
+	// Create attachments table; message_id stays NULL until the upload is sent
+	attachmentTableQuery := `
+	CREATE TABLE IF NOT EXISTS attachments (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		message_id UUID REFERENCES messages(id),
+		user_id UUID NOT NULL REFERENCES users(id),
+		chatroom_id UUID NOT NULL REFERENCES chatrooms(id),
+		filename VARCHAR(255) NOT NULL,
+		content_type VARCHAR(100) NOT NULL,
+		size BIGINT NOT NULL,
+		storage_key VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_attachments_message_id ON attachments (message_id);`
+
+	_, err = DB.Exec(attachmentTableQuery)
+	if err != nil {
+		return err
+	}
+
+	// Create mentions and notifications tables
+	notificationTableQuery := `
+	CREATE TABLE IF NOT EXISTS mentions (
+		message_id UUID NOT NULL REFERENCES messages(id),
+		user_id UUID NOT NULL REFERENCES users(id),
+		PRIMARY KEY (message_id, user_id)
+	);
+	CREATE TABLE IF NOT EXISTS notifications (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id),
+		type VARCHAR(20) NOT NULL,
+		message_id UUID NOT NULL REFERENCES messages(id),
+		chatroom_id UUID NOT NULL REFERENCES chatrooms(id),
+		actor_id UUID NOT NULL REFERENCES users(id),
+		read_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_notifications_user_created_at ON notifications (user_id, created_at DESC);`
+
+	_, err = DB.Exec(notificationTableQuery)
+	if err != nil {
+		return err
+	}
+
+	// Create link previews table
+	linkPreviewTableQuery := `
+	CREATE TABLE IF NOT EXISTS link_previews (
+		message_id UUID NOT NULL REFERENCES messages(id),
+		url TEXT NOT NULL,
+		title TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		image_url TEXT NOT NULL DEFAULT '',
+		site_name TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (message_id, url)
+	);`
+
+	_, err = DB.Exec(linkPreviewTableQuery)
+	if err != nil {
+		return err
+	}
+
+	// Create pins table; a message can be pinned once
+	pinTableQuery := `
+	CREATE TABLE IF NOT EXISTS pins (
+		message_id UUID PRIMARY KEY REFERENCES messages(id),
+		chatroom_id UUID NOT NULL REFERENCES chatrooms(id),
+		pinned_by UUID NOT NULL REFERENCES users(id),
+		pinned_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_pins_chatroom_pinned_at ON pins (chatroom_id, pinned_at DESC);`
+
+	_, err = DB.Exec(pinTableQuery)
+	if err != nil {
+		return err
+	}
+
+	// Create scheduled messages table; rows are deleted once delivered
+	scheduledMessageTableQuery := `
+	CREATE TABLE IF NOT EXISTS scheduled_messages (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id),
+		chatroom_id UUID NOT NULL REFERENCES chatrooms(id),
+		kind VARCHAR(20) NOT NULL,
+		content TEXT NOT NULL,
+		send_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_scheduled_messages_send_at ON scheduled_messages (send_at);
+	CREATE INDEX IF NOT EXISTS idx_scheduled_messages_user_id ON scheduled_messages (user_id, send_at);`
+
+	_, err = DB.Exec(scheduledMessageTableQuery)
+	if err != nil {
+		return err
+	}
+
+	// Create sessions table
+	sessionTableQuery := `
+	CREATE TABLE IF NOT EXISTS sessions (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id),
+		user_agent TEXT NOT NULL DEFAULT '',
+		ip_address VARCHAR(45) NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		last_seen_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		expires_at TIMESTAMP NOT NULL,
+		revoked_at TIMESTAMP
+	);
+	ALTER TABLE sessions ADD COLUMN IF NOT EXISTS pending BOOLEAN NOT NULL DEFAULT FALSE;
+	CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);`
+
+	_, err = DB.Exec(sessionTableQuery)
+	if err != nil {
+		return err
+	}
+
+	// Create access tokens table
+	accessTokenTableQuery := `
+	CREATE TABLE IF NOT EXISTS access_tokens (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id),
+		name VARCHAR(100) NOT NULL,
+		scopes TEXT[] NOT NULL,
+		prefix VARCHAR(20) NOT NULL,
+		token_hash CHAR(64) NOT NULL UNIQUE,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		last_used_at TIMESTAMP,
+		expires_at TIMESTAMP,
+		revoked_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_access_tokens_user_id ON access_tokens (user_id);`
+
+	_, err = DB.Exec(accessTokenTableQuery)
+	if err != nil {
+		return err
+	}
+
+	// Create password resets table
+	passwordResetTableQuery := `
+	CREATE TABLE IF NOT EXISTS password_resets (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id),
+		token_hash CHAR(64) NOT NULL UNIQUE,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		expires_at TIMESTAMP NOT NULL,
+		used_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_password_resets_user_id ON password_resets (user_id);`
+
+	_, err = DB.Exec(passwordResetTableQuery)
+	if err != nil {
+		return err
+	}
+
+	// Create two-factor authentication tables; enabled_at stays NULL until
+	// the user confirms enrollment with a first code
+	twoFactorTableQuery := `
+	CREATE TABLE IF NOT EXISTS two_factor (
+		user_id UUID PRIMARY KEY REFERENCES users(id),
+		secret VARCHAR(64) NOT NULL,
+		last_used_step BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		enabled_at TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS recovery_codes (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id),
+		code_hash CHAR(64) NOT NULL,
+		used_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);`
+
+	_, err = DB.Exec(twoFactorTableQuery)
+	if err != nil {
+		return err
+	}
+
+	// Create user identities table, linking accounts of an OpenID Connect
+	// provider to local users
+	identityTableQuery := `
+	CREATE TABLE IF NOT EXISTS user_identities (
+		issuer TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		user_id UUID NOT NULL REFERENCES users(id),
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (issuer, subject)
+	);
+	CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);`
+
+	_, err = DB.Exec(identityTableQuery)
+	if err != nil {
+		return err
+	}
+
+	// Create audit log table; it has no foreign keys and keeps the actor's
+	// username, so entries outlive the accounts and rooms they refer to
+	auditLogTableQuery := `
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		actor_id UUID NOT NULL,
+		actor_username VARCHAR(50) NOT NULL,
+		action VARCHAR(50) NOT NULL,
+		target_type VARCHAR(20) NOT NULL,
+		target_id TEXT NOT NULL,
+		details JSONB NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log (created_at DESC);`
+
+	_, err = DB.Exec(auditLogTableQuery)
+	if err != nil {
+		return err
+	}
+
 	// Insert default chatroom if none exists
 	_, err = DB.Exec("INSERT INTO chatrooms (name) VALUES ('General') ON CONFLICT DO NOTHING;")
 	if err != nil {
@@ -96,18 +367,41 @@ func createTables() error {
 
 	// Insert the bot user if it doesn't exist
 	botUserQuery := `
-	INSERT INTO users (id, username, password, created_at, updated_at)
-	VALUES ($1, 'Stock Bot', 'botpassword', NOW(), NOW())
-	ON CONFLICT (id) DO NOTHING;
+	INSERT INTO users (id, username, password, role, created_at, updated_at)
+	VALUES ($1, 'Stock Bot', 'botpassword', 'bot', NOW(), NOW())
+	ON CONFLICT (id) DO UPDATE SET role = 'bot';
 	`
 	_, err = DB.Exec(botUserQuery, BotUserID)
 	if err != nil {
 		return err
 	}
 
+	// Insert the placeholder for deleted users; it has no usable password and
+	// is disabled, so nobody can log in as it
+	deletedUserQuery := `
+	INSERT INTO users (id, username, password, role, disabled_at, created_at, updated_at)
+	VALUES ($1, $2, '', 'bot', NOW(), NOW(), NOW())
+	ON CONFLICT (id) DO NOTHING;
+	`
+	_, err = DB.Exec(deletedUserQuery, DeletedUserID, DeletedUsername)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// IsNotFound reports whether err means the requested row doesn't exist,
+// including lookups by an ID that isn't a valid UUID
+func IsNotFound(err error) bool {
+	if errors.Is(err, sql.ErrNoRows) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "22P02" // invalid_text_representation
+}
+
 // Close closes the database connection
 func Close() error {
 	if DB != nil {
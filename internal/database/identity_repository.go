@@ -0,0 +1,34 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// IdentityRepository handles the links between identity provider accounts and users
+type IdentityRepository struct {
+	db *sql.DB
+}
+
+// NewIdentityRepository creates a new identity repository
+func NewIdentityRepository(db *sql.DB) *IdentityRepository {
+	return &IdentityRepository{db: db}
+}
+
+// GetUserID retrieves the user linked to an account of an identity provider
+func (r *IdentityRepository) GetUserID(issuer, subject string) (string, error) {
+	query := `SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2`
+
+	var userID string
+	err := r.db.QueryRow(query, issuer, subject).Scan(&userID)
+	return userID, err
+}
+
+// Create links an account of an identity provider to a user
+func (r *IdentityRepository) Create(issuer, subject, userID string) error {
+	query := `INSERT INTO user_identities (issuer, subject, user_id, created_at)
+	          VALUES ($1, $2, $3, $4)`
+
+	_, err := r.db.Exec(query, issuer, subject, userID, time.Now())
+	return err
+}
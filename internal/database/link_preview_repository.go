@@ -0,0 +1,80 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/lib/pq"
+)
+
+// LinkPreviewRepository handles link preview database operations
+type LinkPreviewRepository struct {
+	db *sql.DB
+}
+
+// NewLinkPreviewRepository creates a new link preview repository
+func NewLinkPreviewRepository(db *sql.DB) *LinkPreviewRepository {
+	return &LinkPreviewRepository{db: db}
+}
+
+// Create saves the preview of a URL in a message, replacing any previous one
+func (r *LinkPreviewRepository) Create(preview *models.LinkPreview) error {
+	query := `INSERT INTO link_previews (message_id, url, title, description, image_url, site_name, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7)
+	          ON CONFLICT (message_id, url) DO UPDATE
+	          SET title = EXCLUDED.title,
+	              description = EXCLUDED.description,
+	              image_url = EXCLUDED.image_url,
+	              site_name = EXCLUDED.site_name,
+	              created_at = EXCLUDED.created_at`
+
+	_, err := r.db.Exec(
+		query,
+		preview.MessageID,
+		preview.URL,
+		preview.Title,
+		preview.Description,
+		preview.ImageURL,
+		preview.SiteName,
+		preview.CreatedAt,
+	)
+	return err
+}
+
+// GetByMessageIDs retrieves the previews of several messages, grouped by message ID
+func (r *LinkPreviewRepository) GetByMessageIDs(messageIDs []string) (map[string][]*models.LinkPreview, error) {
+	query := `SELECT message_id, url, title, description, image_url, site_name, created_at
+	          FROM link_previews
+	          WHERE message_id = ANY($1)
+	          ORDER BY created_at ASC`
+
+	rows, err := r.db.Query(query, pq.Array(messageIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	previews := make(map[string][]*models.LinkPreview)
+	for rows.Next() {
+		var preview models.LinkPreview
+		err := rows.Scan(
+			&preview.MessageID,
+			&preview.URL,
+			&preview.Title,
+			&preview.Description,
+			&preview.ImageURL,
+			&preview.SiteName,
+			&preview.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		previews[preview.MessageID] = append(previews[preview.MessageID], &preview)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return previews, nil
+}
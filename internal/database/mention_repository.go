@@ -0,0 +1,59 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/lib/pq"
+)
+
+// MentionRepository handles mention database operations
+type MentionRepository struct {
+	db *sql.DB
+}
+
+// NewMentionRepository creates a new mention repository
+func NewMentionRepository(db *sql.DB) *MentionRepository {
+	return &MentionRepository{db: db}
+}
+
+// Create records that a message mentions a user
+func (r *MentionRepository) Create(messageID string, mention *models.Mention) error {
+	query := `INSERT INTO mentions (message_id, user_id)
+	          VALUES ($1, $2)
+	          ON CONFLICT DO NOTHING`
+
+	_, err := r.db.Exec(query, messageID, mention.UserID)
+	return err
+}
+
+// GetByMessageIDs retrieves the mentions of several messages, grouped by message ID
+func (r *MentionRepository) GetByMessageIDs(messageIDs []string) (map[string][]*models.Mention, error) {
+	query := `SELECT mn.message_id, u.id, u.username
+	          FROM mentions mn
+	          JOIN users u ON u.id = mn.user_id
+	          WHERE mn.message_id = ANY($1)
+	          ORDER BY u.username`
+
+	rows, err := r.db.Query(query, pq.Array(messageIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := make(map[string][]*models.Mention)
+	for rows.Next() {
+		var messageID string
+		var mention models.Mention
+		if err := rows.Scan(&messageID, &mention.UserID, &mention.Username); err != nil {
+			return nil, err
+		}
+		mentions[messageID] = append(mentions[messageID], &mention)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return mentions, nil
+}
@@ -2,8 +2,18 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 
 	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/lib/pq"
+)
+
+// Markers wrapped around matched terms in search snippets. They are control
+// characters so they can't be confused with HTML once the snippet is escaped.
+const (
+	SnippetStartMarker = "\x02"
+	SnippetStopMarker  = "\x03"
 )
 
 // MessageRepository handles message database operations
@@ -16,14 +26,15 @@ func NewMessageRepository(db *sql.DB) *MessageRepository {
 	return &MessageRepository{db: db}
 }
 
-// Create adds a new message to the database
-func (r *MessageRepository) Create(message *models.Message) error {
-	query := `INSERT INTO messages (user_id, username, chatroom_id, content, type, created_at) 
+// insertMessageQuery inserts a message and returns its ID
+const insertMessageQuery = `INSERT INTO messages (user_id, username, chatroom_id, content, type, created_at) 
 	          VALUES ($1, $2, $3, $4, $5, $6) 
 	          RETURNING id`
 
+// Create adds a new message to the database
+func (r *MessageRepository) Create(message *models.Message) error {
 	err := r.db.QueryRow(
-		query,
+		insertMessageQuery,
 		message.UserID,
 		message.Username,
 		message.ChatroomID,
@@ -35,12 +46,58 @@ func (r *MessageRepository) Create(message *models.Message) error {
 	return err
 }
 
+// CreateWithAttachments adds a new message and links the given uploads to it
+// in one transaction. Only attachments uploaded by the message author to the
+// same chatroom and not yet sent are linked. If any of them can't be linked,
+// nothing is saved and false is returned.
+func (r *MessageRepository) CreateWithAttachments(message *models.Message, attachmentIDs []string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		insertMessageQuery,
+		message.UserID,
+		message.Username,
+		message.ChatroomID,
+		message.Content,
+		message.Type,
+		message.CreatedAt,
+	).Scan(&message.ID)
+	if err != nil {
+		return false, err
+	}
+
+	query := `UPDATE attachments
+	          SET message_id = $1
+	          WHERE id = ANY($2) AND user_id = $3 AND chatroom_id = $4 AND message_id IS NULL`
+
+	result, err := tx.Exec(query, message.ID, pq.Array(attachmentIDs), message.UserID, message.ChatroomID)
+	if err != nil {
+		return false, err
+	}
+
+	linked, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if linked != int64(len(attachmentIDs)) {
+		message.ID = ""
+		return false, nil
+	}
+
+	return true, tx.Commit()
+}
+
 // GetByChatroomID retrieves messages for a specific chatroom, limited to the last 50
 func (r *MessageRepository) GetByChatroomID(chatroomID string, limit int) ([]*models.Message, error) {
-	query := `SELECT id, user_id, username, chatroom_id, content, type, created_at 
-	          FROM messages 
-	          WHERE chatroom_id = $1 
-	          ORDER BY created_at DESC 
+	query := `SELECT m.id, m.user_id, m.username, u.display_name, u.avatar_key, m.chatroom_id, m.content, m.type, m.created_at 
+	          FROM messages m 
+	          JOIN users u ON u.id = m.user_id 
+	          WHERE m.chatroom_id = $1 
+	          ORDER BY m.created_at DESC 
 	          LIMIT $2`
 
 	rows, err := r.db.Query(query, chatroomID, limit)
@@ -50,61 +107,165 @@ func (r *MessageRepository) GetByChatroomID(chatroomID string, limit int) ([]*mo
 	defer rows.Close()
 
 	var messages []*models.Message
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	return messages, nil
+}
+
+// GetByID retrieves a message by ID
+func (r *MessageRepository) GetByID(id string) (*models.Message, error) {
+	query := `SELECT m.id, m.user_id, m.username, u.display_name, u.avatar_key, m.chatroom_id, m.content, m.type, m.created_at 
+	          FROM messages m 
+	          JOIN users u ON u.id = m.user_id 
+	          WHERE m.id = $1`
+
+	return scanMessage(r.db.QueryRow(query, id))
+}
+
+// Search finds messages matching a full-text query, best matches first.
+// It relies on the GIN index on to_tsvector('english', content).
+func (r *MessageRepository) Search(query models.SearchQuery) ([]*models.SearchResult, error) {
+	headlineOptions := fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=30, MinWords=10, MaxFragments=2",
+		SnippetStartMarker, SnippetStopMarker)
+
+	args := []interface{}{query.Text, headlineOptions}
+	conditions := []string{"to_tsvector('english', m.content) @@ q.query"}
+
+	if query.ChatroomID != "" {
+		args = append(args, query.ChatroomID)
+		conditions = append(conditions, fmt.Sprintf("m.chatroom_id = $%d", len(args)))
+	}
+	if query.Username != "" {
+		args = append(args, query.Username)
+		conditions = append(conditions, fmt.Sprintf("LOWER(m.username) = LOWER($%d)", len(args)))
+	}
+	if !query.Before.IsZero() {
+		args = append(args, query.Before)
+		conditions = append(conditions, fmt.Sprintf("m.created_at < $%d", len(args)))
+	}
+
+	args = append(args, query.Limit, query.Offset)
+	sqlQuery := fmt.Sprintf(`SELECT m.id, m.user_id, m.username, u.display_name, u.avatar_key, m.chatroom_id, m.content, m.type, m.created_at,
+	                 ts_headline('english', m.content, q.query, $2),
+	                 ts_rank(to_tsvector('english', m.content), q.query) AS rank
+	          FROM messages m
+	          JOIN chatrooms c ON c.id = m.chatroom_id
+	          JOIN users u ON u.id = m.user_id
+	          CROSS JOIN websearch_to_tsquery('english', $1) AS q(query)
+	          WHERE %s
+	          ORDER BY rank DESC, m.created_at DESC
+	          LIMIT $%d OFFSET $%d`, strings.Join(conditions, " AND "), len(args)-1, len(args))
+
+	rows, err := r.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*models.SearchResult
 	for rows.Next() {
 		var message models.Message
+		var result models.SearchResult
+		var avatarKey string
 		err := rows.Scan(
 			&message.ID,
 			&message.UserID,
 			&message.Username,
+			&message.DisplayName,
+			&avatarKey,
 			&message.ChatroomID,
 			&message.Content,
 			&message.Type,
 			&message.CreatedAt,
+			&result.Snippet,
+			&result.Rank,
 		)
 		if err != nil {
 			return nil, err
 		}
-		messages = append(messages, &message)
+		message.AvatarURL = models.AvatarURL(message.UserID, avatarKey)
+		result.Message = &message
+		results = append(results, &result)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
-		messages[i], messages[j] = messages[j], messages[i]
+	return results, nil
+}
+
+// GetByUserID retrieves every message written by a user, oldest first
+func (r *MessageRepository) GetByUserID(userID string) ([]*models.Message, error) {
+	query := `SELECT m.id, m.user_id, m.username, u.display_name, u.avatar_key, m.chatroom_id, m.content, m.type, m.created_at 
+	          FROM messages m 
+	          JOIN users u ON u.id = m.user_id 
+	          WHERE m.user_id = $1 
+	          ORDER BY m.created_at`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*models.Message
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
 
 	return messages, nil
 }
 
-// GetByID retrieves a message by ID
-func (r *MessageRepository) GetByID(id string) (*models.Message, error) {
-	query := `SELECT id, user_id, username, chatroom_id, content, type, created_at 
-	          FROM messages 
-	          WHERE id = $1`
+// Delete removes a message from the database
+func (r *MessageRepository) Delete(id string) error {
+	query := `DELETE FROM messages WHERE id = $1`
+	_, err := r.db.Exec(query, id)
+	return err
+}
 
+// scanMessage reads a message and the profile of its author from a row of
+// the message queries
+func scanMessage(row interface{ Scan(...interface{}) error }) (*models.Message, error) {
 	var message models.Message
-	err := r.db.QueryRow(query, id).Scan(
+	var avatarKey string
+	err := row.Scan(
 		&message.ID,
 		&message.UserID,
 		&message.Username,
+		&message.DisplayName,
+		&avatarKey,
 		&message.ChatroomID,
 		&message.Content,
 		&message.Type,
 		&message.CreatedAt,
 	)
-
 	if err != nil {
 		return nil, err
 	}
 
+	message.AvatarURL = models.AvatarURL(message.UserID, avatarKey)
 	return &message, nil
 }
-
-// Delete removes a message from the database
-func (r *MessageRepository) Delete(id string) error {
-	query := `DELETE FROM messages WHERE id = $1`
-	_, err := r.db.Exec(query, id)
-	return err
-}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// NotificationRepository handles notification database operations
+type NotificationRepository struct {
+	db *sql.DB
+}
+
+// NewNotificationRepository creates a new notification repository
+func NewNotificationRepository(db *sql.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+// Create adds a new notification to the database
+func (r *NotificationRepository) Create(notification *models.Notification) error {
+	query := `INSERT INTO notifications (user_id, type, message_id, chatroom_id, actor_id, created_at)
+	          VALUES ($1, $2, $3, $4, $5, $6)
+	          RETURNING id`
+
+	err := r.db.QueryRow(
+		query,
+		notification.UserID,
+		notification.Type,
+		notification.MessageID,
+		notification.ChatroomID,
+		notification.ActorID,
+		notification.CreatedAt,
+	).Scan(&notification.ID)
+
+	return err
+}
+
+// GetByUserID retrieves a user's notifications, newest first. If before is set,
+// only notifications created before it are returned, for pagination.
+func (r *NotificationRepository) GetByUserID(userID string, unreadOnly bool, before time.Time, limit int) ([]*models.Notification, error) {
+	query := `SELECT n.id, n.user_id, n.type, n.message_id, n.chatroom_id, n.actor_id,
+	                 m.username, m.content, n.read_at, n.created_at
+	          FROM notifications n
+	          JOIN messages m ON m.id = n.message_id
+	          WHERE n.user_id = $1
+	            AND ($2 = FALSE OR n.read_at IS NULL)
+	            AND ($3::timestamp IS NULL OR n.created_at < $3)
+	          ORDER BY n.created_at DESC
+	          LIMIT $4`
+
+	var beforeArg interface{}
+	if !before.IsZero() {
+		beforeArg = before
+	}
+
+	rows, err := r.db.Query(query, userID, unreadOnly, beforeArg, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []*models.Notification
+	for rows.Next() {
+		var notification models.Notification
+		var readAt sql.NullTime
+		err := rows.Scan(
+			&notification.ID,
+			&notification.UserID,
+			&notification.Type,
+			&notification.MessageID,
+			&notification.ChatroomID,
+			&notification.ActorID,
+			&notification.ActorUsername,
+			&notification.Content,
+			&readAt,
+			&notification.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if readAt.Valid {
+			notification.ReadAt = &readAt.Time
+		}
+		notifications = append(notifications, &notification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+// CountUnread returns the number of unread notifications of a user
+func (r *NotificationRepository) CountUnread(userID string) (int, error) {
+	query := `SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL`
+
+	var count int
+	err := r.db.QueryRow(query, userID).Scan(&count)
+	return count, err
+}
+
+// MarkRead marks one of a user's notifications as read and reports whether it exists
+func (r *NotificationRepository) MarkRead(id, userID string) (bool, error) {
+	query := `UPDATE notifications
+	          SET read_at = COALESCE(read_at, $3)
+	          WHERE id = $1 AND user_id = $2`
+
+	result, err := r.db.Exec(query, id, userID, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// MarkAllRead marks every unread notification of a user as read
+func (r *NotificationRepository) MarkAllRead(userID string) error {
+	query := `UPDATE notifications SET read_at = $2 WHERE user_id = $1 AND read_at IS NULL`
+	_, err := r.db.Exec(query, userID, time.Now())
+	return err
+}
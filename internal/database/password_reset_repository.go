@@ -0,0 +1,96 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// PasswordResetRepository handles password reset database operations
+type PasswordResetRepository struct {
+	db *sql.DB
+}
+
+// NewPasswordResetRepository creates a new password reset repository
+func NewPasswordResetRepository(db *sql.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db}
+}
+
+// Create saves a new password reset and fills in its ID
+func (r *PasswordResetRepository) Create(reset *models.PasswordReset) error {
+	query := `INSERT INTO password_resets (user_id, token_hash, created_at, expires_at)
+	          VALUES ($1, $2, $3, $4)
+	          RETURNING id`
+
+	return r.db.QueryRow(
+		query,
+		reset.UserID,
+		reset.TokenHash,
+		reset.CreatedAt,
+		reset.ExpiresAt,
+	).Scan(&reset.ID)
+}
+
+// GetByHash retrieves a password reset by the hash of its token
+func (r *PasswordResetRepository) GetByHash(hash string) (*models.PasswordReset, error) {
+	query := `SELECT id, user_id, token_hash, created_at, expires_at, used_at
+	          FROM password_resets
+	          WHERE token_hash = $1`
+
+	var reset models.PasswordReset
+	var usedAt sql.NullTime
+	err := r.db.QueryRow(query, hash).Scan(
+		&reset.ID,
+		&reset.UserID,
+		&reset.TokenHash,
+		&reset.CreatedAt,
+		&reset.ExpiresAt,
+		&usedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if usedAt.Valid {
+		reset.UsedAt = &usedAt.Time
+	}
+
+	return &reset, nil
+}
+
+// MarkUsed consumes a password reset. It reports whether the reset was still
+// unused and unexpired, so two concurrent requests can't both use it.
+func (r *PasswordResetRepository) MarkUsed(id string, now time.Time) (bool, error) {
+	query := `UPDATE password_resets
+	          SET used_at = $1
+	          WHERE id = $2 AND used_at IS NULL AND expires_at > $1`
+
+	result, err := r.db.Exec(query, now, id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// InvalidateByUserID consumes every pending password reset of a user, so only
+// the most recently sent link works
+func (r *PasswordResetRepository) InvalidateByUserID(userID string) error {
+	query := `UPDATE password_resets SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL`
+	_, err := r.db.Exec(query, time.Now(), userID)
+	return err
+}
+
+// DeleteExpired removes password resets that expired before the cutoff
+func (r *PasswordResetRepository) DeleteExpired(before time.Time) (int64, error) {
+	query := `DELETE FROM password_resets WHERE expires_at < $1`
+
+	result, err := r.db.Exec(query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
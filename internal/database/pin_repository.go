@@ -0,0 +1,110 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// PinRepository handles pinned message database operations
+type PinRepository struct {
+	db *sql.DB
+}
+
+// NewPinRepository creates a new pin repository
+func NewPinRepository(db *sql.DB) *PinRepository {
+	return &PinRepository{db: db}
+}
+
+// Create pins a message. It reports false if the message was already pinned.
+func (r *PinRepository) Create(pin *models.Pin) (bool, error) {
+	query := `INSERT INTO pins (message_id, chatroom_id, pinned_by, pinned_at) 
+	          VALUES ($1, $2, $3, $4) 
+	          ON CONFLICT (message_id) DO NOTHING`
+
+	result, err := r.db.Exec(query, pin.MessageID, pin.ChatroomID, pin.PinnedBy, pin.PinnedAt)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// Delete unpins a message of a chatroom. It reports false if the message
+// wasn't pinned there.
+func (r *PinRepository) Delete(chatroomID, messageID string) (bool, error) {
+	query := `DELETE FROM pins WHERE chatroom_id = $1 AND message_id = $2`
+
+	result, err := r.db.Exec(query, chatroomID, messageID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// CountByChatroomID counts the pinned messages of a chatroom
+func (r *PinRepository) CountByChatroomID(chatroomID string) (int, error) {
+	query := `SELECT COUNT(*) FROM pins WHERE chatroom_id = $1`
+
+	var count int
+	err := r.db.QueryRow(query, chatroomID).Scan(&count)
+	return count, err
+}
+
+// GetByChatroomID retrieves the pinned messages of a chatroom, most
+// recently pinned first
+func (r *PinRepository) GetByChatroomID(chatroomID string) ([]*models.Pin, error) {
+	query := `SELECT p.chatroom_id, p.message_id, p.pinned_by, pu.username, p.pinned_at,
+	                 m.id, m.user_id, m.username, u.display_name, u.avatar_key, m.chatroom_id, m.content, m.type, m.created_at
+	          FROM pins p
+	          JOIN users pu ON pu.id = p.pinned_by
+	          JOIN messages m ON m.id = p.message_id
+	          JOIN users u ON u.id = m.user_id
+	          WHERE p.chatroom_id = $1
+	          ORDER BY p.pinned_at DESC`
+
+	rows, err := r.db.Query(query, chatroomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pins := []*models.Pin{}
+	for rows.Next() {
+		var pin models.Pin
+		var message models.Message
+		var avatarKey string
+		err := rows.Scan(
+			&pin.ChatroomID,
+			&pin.MessageID,
+			&pin.PinnedBy,
+			&pin.PinnedByUsername,
+			&pin.PinnedAt,
+			&message.ID,
+			&message.UserID,
+			&message.Username,
+			&message.DisplayName,
+			&avatarKey,
+			&message.ChatroomID,
+			&message.Content,
+			&message.Type,
+			&message.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		message.AvatarURL = models.AvatarURL(message.UserID, avatarKey)
+		pin.Message = &message
+		pins = append(pins, &pin)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pins, nil
+}
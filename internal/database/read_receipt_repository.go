@@ -0,0 +1,145 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/lib/pq"
+)
+
+// ReadReceiptRepository handles read receipt database operations
+type ReadReceiptRepository struct {
+	db *sql.DB
+}
+
+// NewReadReceiptRepository creates a new read receipt repository
+func NewReadReceiptRepository(db *sql.DB) *ReadReceiptRepository {
+	return &ReadReceiptRepository{db: db}
+}
+
+// Upsert moves a user's read pointer forward, never backwards.
+// It reports whether the pointer changed.
+func (r *ReadReceiptRepository) Upsert(receipt *models.ReadReceipt) (bool, error) {
+	query := `INSERT INTO read_receipts (user_id, chatroom_id, message_id, message_created_at, read_at)
+	          SELECT $1, $2, m.id, m.created_at, $4
+	          FROM messages m
+	          WHERE m.id = $3 AND m.chatroom_id = $2
+	          ON CONFLICT (user_id, chatroom_id) DO UPDATE
+	          SET message_id = EXCLUDED.message_id,
+	              message_created_at = EXCLUDED.message_created_at,
+	              read_at = EXCLUDED.read_at
+	          WHERE read_receipts.message_created_at < EXCLUDED.message_created_at`
+
+	result, err := r.db.Exec(
+		query,
+		receipt.UserID,
+		receipt.ChatroomID,
+		receipt.MessageID,
+		receipt.ReadAt,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// GetByUserAndChatroomID retrieves a user's read position in a chatroom
+func (r *ReadReceiptRepository) GetByUserAndChatroomID(userID, chatroomID string) (*models.ReadReceipt, error) {
+	query := `SELECT rr.user_id, u.username, rr.chatroom_id, rr.message_id, rr.message_created_at, rr.read_at
+	          FROM read_receipts rr
+	          JOIN users u ON u.id = rr.user_id
+	          WHERE rr.user_id = $1 AND rr.chatroom_id = $2`
+
+	var receipt models.ReadReceipt
+	err := r.db.QueryRow(query, userID, chatroomID).Scan(
+		&receipt.UserID,
+		&receipt.Username,
+		&receipt.ChatroomID,
+		&receipt.MessageID,
+		&receipt.MessageCreatedAt,
+		&receipt.ReadAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &receipt, nil
+}
+
+// GetByChatroomID retrieves the read positions of every user in a chatroom
+func (r *ReadReceiptRepository) GetByChatroomID(chatroomID string) ([]*models.ReadReceipt, error) {
+	query := `SELECT rr.user_id, u.username, rr.chatroom_id, rr.message_id, rr.read_at
+	          FROM read_receipts rr
+	          JOIN users u ON u.id = rr.user_id
+	          WHERE rr.chatroom_id = $1
+	          ORDER BY u.username`
+
+	rows, err := r.db.Query(query, chatroomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var receipts []*models.ReadReceipt
+	for rows.Next() {
+		var receipt models.ReadReceipt
+		err := rows.Scan(
+			&receipt.UserID,
+			&receipt.Username,
+			&receipt.ChatroomID,
+			&receipt.MessageID,
+			&receipt.ReadAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, &receipt)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return receipts, nil
+}
+
+// GetUnreadCounts returns the number of unread messages in the given chatrooms
+// for a user, not counting the user's own messages. Rooms without unread
+// messages are omitted.
+func (r *ReadReceiptRepository) GetUnreadCounts(userID string, chatroomIDs []string) (map[string]int, error) {
+	query := `SELECT m.chatroom_id, COUNT(*)
+	          FROM messages m
+	          LEFT JOIN read_receipts rr ON rr.chatroom_id = m.chatroom_id AND rr.user_id = $1
+	          WHERE m.chatroom_id = ANY($2)
+	            AND m.user_id <> $1
+	            AND (rr.message_created_at IS NULL OR m.created_at > rr.message_created_at)
+	          GROUP BY m.chatroom_id`
+
+	rows, err := r.db.Query(query, userID, pq.Array(chatroomIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var chatroomID string
+		var count int
+		if err := rows.Scan(&chatroomID, &count); err != nil {
+			return nil, err
+		}
+		counts[chatroomID] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
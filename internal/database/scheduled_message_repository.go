@@ -0,0 +1,187 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// ScheduledMessageRepository handles scheduled message database operations
+type ScheduledMessageRepository struct {
+	db *sql.DB
+}
+
+// NewScheduledMessageRepository creates a new scheduled message repository
+func NewScheduledMessageRepository(db *sql.DB) *ScheduledMessageRepository {
+	return &ScheduledMessageRepository{db: db}
+}
+
+// Create adds a new scheduled message to the database
+func (r *ScheduledMessageRepository) Create(schedule *models.ScheduledMessage) error {
+	query := `INSERT INTO scheduled_messages (user_id, chatroom_id, kind, content, send_at, created_at) 
+	          VALUES ($1, $2, $3, $4, $5, $6) 
+	          RETURNING id`
+
+	err := r.db.QueryRow(
+		query,
+		schedule.UserID,
+		schedule.ChatroomID,
+		schedule.Kind,
+		schedule.Content,
+		schedule.SendAt,
+		schedule.CreatedAt,
+	).Scan(&schedule.ID)
+
+	return err
+}
+
+// GetByUserID retrieves the pending scheduled messages of a user, soonest first
+func (r *ScheduledMessageRepository) GetByUserID(userID string) ([]*models.ScheduledMessage, error) {
+	query := `SELECT id, user_id, chatroom_id, kind, content, send_at, created_at 
+	          FROM scheduled_messages 
+	          WHERE user_id = $1 
+	          ORDER BY send_at`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanScheduledMessages(rows)
+}
+
+// CountByUserID counts the pending scheduled messages of a user
+func (r *ScheduledMessageRepository) CountByUserID(userID string) (int, error) {
+	query := `SELECT COUNT(*) FROM scheduled_messages WHERE user_id = $1`
+
+	var count int
+	err := r.db.QueryRow(query, userID).Scan(&count)
+	return count, err
+}
+
+// Delete cancels a scheduled message of a user. It reports false if the user
+// has no such pending message.
+func (r *ScheduledMessageRepository) Delete(id, userID string) (bool, error) {
+	query := `DELETE FROM scheduled_messages WHERE id = $1 AND user_id = $2`
+
+	result, err := r.db.Exec(query, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// GetDue retrieves up to limit messages due at now, ordered by send time and
+// ID. Passing the last message of a page as after continues from it.
+func (r *ScheduledMessageRepository) GetDue(now time.Time, after *models.ScheduledMessage, limit int) ([]*models.ScheduledMessage, error) {
+	// send_at has no time zone and holds UTC
+	args := []interface{}{now.UTC(), limit}
+	where := ""
+	if after != nil {
+		args = append(args, after.SendAt.UTC(), after.ID)
+		where = "AND (send_at, id) > ($3, $4)"
+	}
+
+	query := fmt.Sprintf(`SELECT id, user_id, chatroom_id, kind, content, send_at, created_at 
+	          FROM scheduled_messages 
+	          WHERE send_at <= $1 %s 
+	          ORDER BY send_at, id 
+	          LIMIT $2`, where)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanScheduledMessages(rows)
+}
+
+// ScheduleClaim is a due scheduled message locked for delivery. The message
+// stays locked until Complete deletes it or Release leaves it pending, so
+// it's never delivered twice at the same time nor lost if delivery fails.
+type ScheduleClaim struct {
+	tx       *sql.Tx
+	Schedule *models.ScheduledMessage
+}
+
+// Claim locks a scheduled message that is due at now. It returns nil if the
+// message was cancelled, isn't due or is locked by another delivery.
+func (r *ScheduledMessageRepository) Claim(id string, now time.Time) (*ScheduleClaim, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, user_id, chatroom_id, kind, content, send_at, created_at 
+	          FROM scheduled_messages 
+	          WHERE id = $1 AND send_at <= $2 
+	          FOR UPDATE SKIP LOCKED`
+
+	var schedule models.ScheduledMessage
+	err = tx.QueryRow(query, id, now.UTC()).Scan(
+		&schedule.ID,
+		&schedule.UserID,
+		&schedule.ChatroomID,
+		&schedule.Kind,
+		&schedule.Content,
+		&schedule.SendAt,
+		&schedule.CreatedAt,
+	)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &ScheduleClaim{tx: tx, Schedule: &schedule}, nil
+}
+
+// Complete deletes the claimed message, once it's delivered or can't ever be
+func (c *ScheduleClaim) Complete() error {
+	if _, err := c.tx.Exec(`DELETE FROM scheduled_messages WHERE id = $1`, c.Schedule.ID); err != nil {
+		c.tx.Rollback()
+		return err
+	}
+	return c.tx.Commit()
+}
+
+// Release unlocks the claimed message, leaving it pending. It does nothing
+// after Complete.
+func (c *ScheduleClaim) Release() {
+	c.tx.Rollback()
+}
+
+// scanScheduledMessages reads the scheduled messages of a query
+func scanScheduledMessages(rows *sql.Rows) ([]*models.ScheduledMessage, error) {
+	var schedules []*models.ScheduledMessage
+	for rows.Next() {
+		var schedule models.ScheduledMessage
+		err := rows.Scan(
+			&schedule.ID,
+			&schedule.UserID,
+			&schedule.ChatroomID,
+			&schedule.Kind,
+			&schedule.Content,
+			&schedule.SendAt,
+			&schedule.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, &schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// SessionRepository handles session database operations
+type SessionRepository struct {
+	db *sql.DB
+}
+
+// NewSessionRepository creates a new session repository
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// Create saves a new session and fills in its ID
+func (r *SessionRepository) Create(session *models.Session) error {
+	query := `INSERT INTO sessions (user_id, user_agent, ip_address, created_at, last_seen_at, expires_at, pending)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7)
+	          RETURNING id`
+
+	return r.db.QueryRow(
+		query,
+		session.UserID,
+		session.UserAgent,
+		session.IPAddress,
+		session.CreatedAt,
+		session.LastSeenAt,
+		session.ExpiresAt,
+		session.Pending,
+	).Scan(&session.ID)
+}
+
+// GetByID retrieves a session by ID, including revoked and expired ones
+func (r *SessionRepository) GetByID(id string) (*models.Session, error) {
+	query := `SELECT id, user_id, user_agent, ip_address, created_at, last_seen_at, expires_at, revoked_at, pending
+	          FROM sessions
+	          WHERE id = $1`
+
+	return scanSession(r.db.QueryRow(query, id))
+}
+
+// GetActiveByUserID retrieves the sessions of a user that are neither revoked,
+// expired nor pending, most recently used first
+func (r *SessionRepository) GetActiveByUserID(userID string) ([]*models.Session, error) {
+	query := `SELECT id, user_id, user_agent, ip_address, created_at, last_seen_at, expires_at, revoked_at, pending
+	          FROM sessions
+	          WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2 AND NOT pending
+	          ORDER BY last_seen_at DESC`
+
+	rows, err := r.db.Query(query, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*models.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Touch records that a session was just used
+func (r *SessionRepository) Touch(id string, lastSeenAt time.Time) error {
+	query := `UPDATE sessions SET last_seen_at = $1 WHERE id = $2`
+	_, err := r.db.Exec(query, lastSeenAt, id)
+	return err
+}
+
+// Revoke ends a session of a user. It reports whether an active session was revoked.
+func (r *SessionRepository) Revoke(userID, id string) (bool, error) {
+	query := `UPDATE sessions
+	          SET revoked_at = $1
+	          WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL`
+
+	result, err := r.db.Exec(query, time.Now(), id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RevokeAllByUserID ends every session of a user except exceptID, which may be
+// empty to revoke them all
+func (r *SessionRepository) RevokeAllByUserID(userID, exceptID string) (int64, error) {
+	query := `UPDATE sessions
+	          SET revoked_at = $1
+	          WHERE user_id = $2 AND revoked_at IS NULL AND id::text <> $3`
+
+	result, err := r.db.Exec(query, time.Now(), userID, exceptID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// DeleteExpired removes sessions that expired or were revoked before the cutoff
+func (r *SessionRepository) DeleteExpired(before time.Time) (int64, error) {
+	query := `DELETE FROM sessions WHERE expires_at < $1 OR revoked_at < $1`
+
+	result, err := r.db.Exec(query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// scanSession reads a session from a row of the session queries
+func scanSession(row interface{ Scan(...interface{}) error }) (*models.Session, error) {
+	var session models.Session
+	var revokedAt sql.NullTime
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.UserAgent,
+		&session.IPAddress,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.ExpiresAt,
+		&revokedAt,
+		&session.Pending,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+
+	return &session, nil
+}
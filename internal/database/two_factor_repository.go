@@ -0,0 +1,164 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// TwoFactorRepository handles two-factor authentication database operations
+type TwoFactorRepository struct {
+	db *sql.DB
+}
+
+// NewTwoFactorRepository creates a new two-factor repository
+func NewTwoFactorRepository(db *sql.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{db: db}
+}
+
+// Save stores a new, not yet enabled secret for a user, replacing any
+// unfinished enrollment
+func (r *TwoFactorRepository) Save(twoFactor *models.TwoFactor) error {
+	query := `INSERT INTO two_factor (user_id, secret, last_used_step, created_at)
+	          VALUES ($1, $2, 0, $3)
+	          ON CONFLICT (user_id) DO UPDATE
+	          SET secret = EXCLUDED.secret, last_used_step = 0, created_at = EXCLUDED.created_at, enabled_at = NULL
+	          WHERE two_factor.enabled_at IS NULL`
+
+	_, err := r.db.Exec(query, twoFactor.UserID, twoFactor.Secret, twoFactor.CreatedAt)
+	return err
+}
+
+// GetByUserID retrieves the two-factor secret of a user
+func (r *TwoFactorRepository) GetByUserID(userID string) (*models.TwoFactor, error) {
+	query := `SELECT user_id, secret, last_used_step, created_at, enabled_at
+	          FROM two_factor
+	          WHERE user_id = $1`
+
+	var twoFactor models.TwoFactor
+	var enabledAt sql.NullTime
+	err := r.db.QueryRow(query, userID).Scan(
+		&twoFactor.UserID,
+		&twoFactor.Secret,
+		&twoFactor.LastUsedStep,
+		&twoFactor.CreatedAt,
+		&enabledAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if enabledAt.Valid {
+		twoFactor.EnabledAt = &enabledAt.Time
+	}
+
+	return &twoFactor, nil
+}
+
+// Enable turns on two-factor authentication for a user, recording the time
+// step of the code that confirmed it. It reports whether it was still disabled.
+func (r *TwoFactorRepository) Enable(userID string, step int64, now time.Time) (bool, error) {
+	query := `UPDATE two_factor
+	          SET enabled_at = $1, last_used_step = $2
+	          WHERE user_id = $3 AND enabled_at IS NULL`
+
+	result, err := r.db.Exec(query, now, step, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// UseStep records that the code of a time step was used. It reports false if
+// a code of that step or a later one was already used.
+func (r *TwoFactorRepository) UseStep(userID string, step int64) (bool, error) {
+	query := `UPDATE two_factor SET last_used_step = $1 WHERE user_id = $2 AND last_used_step < $1`
+
+	result, err := r.db.Exec(query, step, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// Delete removes the two-factor secret and recovery codes of a user. It
+// reports whether the user had a secret.
+func (r *TwoFactorRepository) Delete(userID string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return false, err
+	}
+
+	result, err := tx.Exec(`DELETE FROM two_factor WHERE user_id = $1`, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, tx.Commit()
+}
+
+// ReplaceRecoveryCodes replaces the recovery codes of a user with new ones,
+// given by their hashes
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(userID string, hashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		if _, err := tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode consumes a recovery code of a user. It reports whether an
+// unused code with that hash existed.
+func (r *TwoFactorRepository) UseRecoveryCode(userID, hash string, now time.Time) (bool, error) {
+	query := `UPDATE recovery_codes
+	          SET used_at = $1
+	          WHERE id = (
+	              SELECT id FROM recovery_codes
+	              WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL
+	              LIMIT 1
+	          ) AND used_at IS NULL`
+
+	result, err := r.db.Exec(query, now, userID, hash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// CountRecoveryCodes counts the unused recovery codes of a user
+func (r *TwoFactorRepository) CountRecoveryCodes(userID string) (int, error) {
+	query := `SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+
+	var count int
+	err := r.db.QueryRow(query, userID).Scan(&count)
+	return count, err
+}
@@ -2,11 +2,18 @@ package database
 
 import (
 	"database/sql"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/lib/pq"
 )
 
+// ErrLastActiveAdmin is returned when deleting the only administrator whose
+// account isn't disabled
+var ErrLastActiveAdmin = errors.New("last active administrator")
+
 type UserRepository struct {
 	db *sql.DB
 }
@@ -16,14 +23,19 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 }
 
 func (r *UserRepository) Create(user *models.User) error {
-	query := `INSERT INTO users (username, password, created_at, updated_at) 
-	          VALUES ($1, $2, $3, $4) 
+	query := `INSERT INTO users (username, password, role, created_at, updated_at) 
+	          VALUES ($1, $2, $3, $4, $5) 
 	          RETURNING id`
 
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+
 	err := r.db.QueryRow(
 		query,
 		user.Username,
 		user.Password,
+		user.Role,
 		user.CreatedAt,
 		user.UpdatedAt,
 	).Scan(&user.ID)
@@ -32,45 +44,80 @@ func (r *UserRepository) Create(user *models.User) error {
 }
 
 func (r *UserRepository) GetByUsername(username string) (*models.User, error) {
-	query := `SELECT id, username, password, created_at, updated_at 
+	query := `SELECT id, username, password, role, display_name, avatar_key, status_text, time_zone, disabled_at, created_at, updated_at 
 	          FROM users 
 	          WHERE username = $1`
 
-	var user models.User
-	err := r.db.QueryRow(query, username).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Password,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	return scanUser(r.db.QueryRow(query, username))
+}
+
+func (r *UserRepository) GetByUsernames(usernames []string) ([]*models.User, error) {
+	query := `SELECT id, username, password, role, display_name, avatar_key, status_text, time_zone, disabled_at, created_at, updated_at 
+	          FROM users 
+	          WHERE LOWER(username) = ANY($1)`
 
+	lowered := make([]string, len(usernames))
+	for i, username := range usernames {
+		lowered[i] = strings.ToLower(username)
+	}
+
+	rows, err := r.db.Query(query, pq.Array(lowered))
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return &user, nil
+	var users []*models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
 }
 
-func (r *UserRepository) GetByID(id string) (*models.User, error) {
-	query := `SELECT id, username, password, created_at, updated_at 
+// GetAll retrieves users ordered by username, for administration
+func (r *UserRepository) GetAll(limit, offset int) ([]*models.User, error) {
+	query := `SELECT id, username, password, role, display_name, avatar_key, status_text, time_zone, disabled_at, created_at, updated_at 
 	          FROM users 
-	          WHERE id = $1`
-
-	var user models.User
-	err := r.db.QueryRow(query, id).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Password,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	          ORDER BY LOWER(username) 
+	          LIMIT $1 OFFSET $2`
 
+	rows, err := r.db.Query(query, limit, offset)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return &user, nil
+	var users []*models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r *UserRepository) GetByID(id string) (*models.User, error) {
+	query := `SELECT id, username, password, role, display_name, avatar_key, status_text, time_zone, disabled_at, created_at, updated_at 
+	          FROM users 
+	          WHERE id = $1`
+
+	return scanUser(r.db.QueryRow(query, id))
 }
 
 func (r *UserRepository) Update(user *models.User) error {
@@ -89,8 +136,171 @@ func (r *UserRepository) Update(user *models.User) error {
 	return err
 }
 
-func (r *UserRepository) Delete(id string) error {
-	query := `DELETE FROM users WHERE id = $1`
-	_, err := r.db.Exec(query, id)
+func (r *UserRepository) UpdateRole(id, role string) error {
+	query := `UPDATE users SET role = $1, updated_at = $2 WHERE id = $3`
+	_, err := r.db.Exec(query, role, time.Now(), id)
+	return err
+}
+
+// SetDisabled disables a user's account at disabledAt, or enables it again
+// when disabledAt is nil
+func (r *UserRepository) SetDisabled(id string, disabledAt *time.Time) error {
+	query := `UPDATE users SET disabled_at = $1, updated_at = $2 WHERE id = $3`
+	_, err := r.db.Exec(query, disabledAt, time.Now(), id)
 	return err
 }
+
+// UpdateProfile saves the display name, status text and time zone of a user
+func (r *UserRepository) UpdateProfile(user *models.User) error {
+	query := `UPDATE users 
+	          SET display_name = $1, status_text = $2, time_zone = $3, updated_at = $4 
+	          WHERE id = $5`
+
+	_, err := r.db.Exec(query, user.DisplayName, user.StatusText, user.TimeZone, time.Now(), user.ID)
+	return err
+}
+
+// UpdateAvatar replaces the storage key of a user's avatar and returns the
+// previous one, so the old image can be deleted
+func (r *UserRepository) UpdateAvatar(id, avatarKey string) (string, error) {
+	query := `UPDATE users u 
+	          SET avatar_key = $1, updated_at = $2 
+	          FROM (SELECT avatar_key FROM users WHERE id = $3 FOR UPDATE) previous 
+	          WHERE u.id = $3 
+	          RETURNING previous.avatar_key`
+
+	var previous string
+	err := r.db.QueryRow(query, avatarKey, time.Now(), id).Scan(&previous)
+	return previous, err
+}
+
+// Delete removes a user in one transaction. Their messages and sent
+// attachments stay in the chatrooms, attributed to the DeletedUserID
+// placeholder; everything else belonging to the user is deleted. It returns
+// the storage keys of the files the caller should delete: the avatar and the
+// attachments that were never sent. Deleting the last active administrator
+// fails with ErrLastActiveAdmin.
+func (r *UserRepository) Delete(id string) ([]string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var role string
+	var active bool
+	err = tx.QueryRow(`SELECT role, disabled_at IS NULL FROM users WHERE id = $1`, id).Scan(&role, &active)
+	if err != nil {
+		return nil, err
+	}
+
+	// Someone has to be left to administer the server. The active admins are
+	// locked in a fixed order, so concurrent deletions can't both pass.
+	if role == models.RoleAdmin && active {
+		var admins int
+		err = tx.QueryRow(
+			`SELECT COUNT(*) FROM (SELECT id FROM users WHERE role = $1 AND disabled_at IS NULL ORDER BY id FOR UPDATE) admins`,
+			models.RoleAdmin,
+		).Scan(&admins)
+		if err != nil {
+			return nil, err
+		}
+		if admins <= 1 {
+			return nil, ErrLastActiveAdmin
+		}
+	}
+
+	var avatarKey string
+	err = tx.QueryRow(`SELECT avatar_key FROM users WHERE id = $1 FOR UPDATE`, id).Scan(&avatarKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var storageKeys []string
+	if avatarKey != "" {
+		storageKeys = append(storageKeys, avatarKey)
+	}
+
+	rows, err := tx.Query(`DELETE FROM attachments WHERE user_id = $1 AND message_id IS NULL RETURNING storage_key`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		storageKeys = append(storageKeys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Anonymize what stays visible to other users
+	_, err = tx.Exec(`UPDATE messages SET user_id = $2, username = $3 WHERE user_id = $1`, id, DeletedUserID, DeletedUsername)
+	if err != nil {
+		return nil, err
+	}
+	anonymize := []string{
+		`UPDATE attachments SET user_id = $2 WHERE user_id = $1`,
+		`UPDATE notifications SET actor_id = $2 WHERE actor_id = $1`,
+		`UPDATE pins SET pinned_by = $2 WHERE pinned_by = $1`,
+	}
+	for _, query := range anonymize {
+		if _, err := tx.Exec(query, id, DeletedUserID); err != nil {
+			return nil, err
+		}
+	}
+
+	queries := []string{
+		`UPDATE chatrooms SET created_by = NULL WHERE created_by = $1`,
+		`DELETE FROM mentions WHERE user_id = $1`,
+		`DELETE FROM notifications WHERE user_id = $1`,
+		`DELETE FROM read_receipts WHERE user_id = $1`,
+		`DELETE FROM scheduled_messages WHERE user_id = $1`,
+		`DELETE FROM sessions WHERE user_id = $1`,
+		`DELETE FROM access_tokens WHERE user_id = $1`,
+		`DELETE FROM password_resets WHERE user_id = $1`,
+		`DELETE FROM recovery_codes WHERE user_id = $1`,
+		`DELETE FROM two_factor WHERE user_id = $1`,
+		`DELETE FROM user_identities WHERE user_id = $1`,
+		`DELETE FROM users WHERE id = $1`,
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query, id); err != nil {
+			return nil, err
+		}
+	}
+
+	return storageKeys, tx.Commit()
+}
+
+// scanUser reads a user from a row of the user queries
+func scanUser(row interface{ Scan(...interface{}) error }) (*models.User, error) {
+	var user models.User
+	var disabledAt sql.NullTime
+	err := row.Scan(
+		&user.ID,
+		&user.Username,
+		&user.Password,
+		&user.Role,
+		&user.DisplayName,
+		&user.AvatarKey,
+		&user.StatusText,
+		&user.TimeZone,
+		&disabledAt,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if disabledAt.Valid {
+		user.DisabledAt = &disabledAt.Time
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/dbvitor/chat-go/internal/services"
+	"github.com/dbvitor/chat-go/pkg/auth"
+)
+
+// AccountHandler handles exporting and deleting the authenticated user's account
+type AccountHandler struct {
+	accountService *services.AccountService
+	loginGuard     *loginGuard
+	onDelete       func(userID string) // disconnects the WebSockets of a deleted user
+}
+
+// NewAccountHandler creates a new account handler. Wrong passwords count
+// towards loginGuard, like wrong passwords at login.
+func NewAccountHandler(accountService *services.AccountService, loginGuard *loginGuard, onDelete func(userID string)) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+		loginGuard:     loginGuard,
+		onDelete:       onDelete,
+	}
+}
+
+// DeleteAccountRequest represents the request body for deleting an account.
+// Users with a password send it; single sign-on users send their username.
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+	Confirm  string `json:"confirm"`
+}
+
+// Export handles downloading everything stored about the authenticated user.
+// The format query parameter selects "zip" (the default), an archive that
+// also holds the avatar and attachment files, or "json".
+func (h *AccountHandler) Export(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	user := auth.GetPrincipal(r).User
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "zip"
+	}
+	if format != "zip" && format != "json" {
+		http.Error(w, "Format must be zip or json", http.StatusBadRequest)
+		return
+	}
+
+	export, err := h.accountService.Export(user)
+	if err != nil {
+		log.Printf("Error exporting account data: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("chat-export-%s-%s.%s", user.Username, export.ExportedAt.Format("20060102"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Cache-Control", "no-store")
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(export)
+		return
+	}
+
+	// The archive is streamed, so errors after this point can only be logged
+	w.Header().Set("Content-Type", "application/zip")
+	if err := h.accountService.WriteArchive(w, user, export); err != nil {
+		log.Printf("Error writing data export of user %s: %v", user.Username, err)
+	}
+}
+
+// Delete handles the authenticated user deleting their account
+func (h *AccountHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	user := auth.GetPrincipal(r).User
+
+	var req DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	lockoutKey := h.loginGuard.key(r, user.Username)
+	if h.loginGuard.refuse(w, lockoutKey) {
+		return
+	}
+
+	if err := h.accountService.Delete(user, req.Password, req.Confirm); err != nil {
+		switch err {
+		case services.ErrWrongPassword:
+			h.loginGuard.fail(lockoutKey, user.Username)
+			http.Error(w, "Password is incorrect", http.StatusUnauthorized)
+		case services.ErrDeleteNotConfirmed:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case services.ErrLastAdmin, services.ErrAccountProtected:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("Error deleting account: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.onDelete(user.ID)
+
+	// The sessions are gone; clear the cookie too
+	if err := auth.Logout(w, r); err != nil {
+		log.Printf("Error clearing session of deleted user: %v", err)
+	}
+
+	log.Printf("User %s deleted their account", user.Username)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/internal/services"
+	"github.com/dbvitor/chat-go/pkg/auth"
+	"github.com/gorilla/mux"
+)
+
+// AdminHandler handles user administration and the audit log
+type AdminHandler struct {
+	adminService *services.AdminService
+	auditService *services.AuditService
+	onDisable    func(userID string) // disconnects the WebSockets of a disabled user
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(adminService *services.AdminService, auditService *services.AuditService, onDisable func(userID string)) *AdminHandler {
+	return &AdminHandler{
+		adminService: adminService,
+		auditService: auditService,
+		onDisable:    onDisable,
+	}
+}
+
+// SetRoleRequest represents the request body for changing a user's role
+type SetRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// UsersResponse represents a page of users
+type UsersResponse struct {
+	Users      []*models.User `json:"users"`
+	NextOffset *int           `json:"next_offset,omitempty"`
+}
+
+// AuditLogResponse represents a page of the audit log; pass next_before as
+// the before parameter to get the next page
+type AuditLogResponse struct {
+	Entries    []*models.AuditEntry `json:"entries"`
+	NextBefore *time.Time           `json:"next_before,omitempty"`
+}
+
+// ListUsers handles listing users. Query parameters: limit and offset.
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated administrator, resolved by the auth middleware
+	admin := auth.GetPrincipal(r).User
+
+	limit, offset, ok := parsePage(w, r, services.MaxUserPageSize)
+	if !ok {
+		return
+	}
+	if limit == 0 {
+		limit = services.DefaultUserPageSize
+	}
+
+	users, err := h.adminService.ListUsers(admin, limit, offset)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	response := UsersResponse{Users: users}
+	if len(users) == limit {
+		next := offset + len(users)
+		response.NextOffset = &next
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SetRole handles changing the global role of a user
+func (h *AdminHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated administrator, resolved by the auth middleware
+	admin := auth.GetPrincipal(r).User
+
+	var req SetRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.adminService.SetRole(admin, mux.Vars(r)["id"], req.Role)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	log.Printf("Administrator %s set the role of user %s to %s", admin.Username, user.Username, user.Role)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// Disable handles disabling a user's account, logging them out everywhere
+func (h *AdminHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated administrator, resolved by the auth middleware
+	admin := auth.GetPrincipal(r).User
+
+	user, err := h.adminService.DisableUser(admin, mux.Vars(r)["id"])
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	h.onDisable(user.ID)
+
+	log.Printf("Administrator %s disabled user %s", admin.Username, user.Username)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// Enable handles enabling a disabled user's account again
+func (h *AdminHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated administrator, resolved by the auth middleware
+	admin := auth.GetPrincipal(r).User
+
+	user, err := h.adminService.EnableUser(admin, mux.Vars(r)["id"])
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	log.Printf("Administrator %s enabled user %s", admin.Username, user.Username)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// AuditLog handles listing audit entries, newest first.
+// Query parameters: before (RFC 3339 or YYYY-MM-DD) and limit.
+func (h *AdminHandler) AuditLog(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated administrator, resolved by the auth middleware
+	admin := auth.GetPrincipal(r).User
+
+	var before time.Time
+	var err error
+	params := r.URL.Query()
+	if value := params.Get("before"); value != "" {
+		before, err = parseSearchTime(value)
+		if err != nil {
+			http.Error(w, "Invalid before parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := services.DefaultAuditPageSize
+	if value := params.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		if limit > services.MaxAuditPageSize {
+			limit = services.MaxAuditPageSize
+		}
+	}
+
+	entries, err := h.auditService.List(admin, before, limit)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	response := AuditLogResponse{Entries: entries}
+	if len(entries) == limit {
+		response.NextBefore = &entries[len(entries)-1].CreatedAt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// parsePage reads the limit and offset query parameters, writing an error
+// response if they're invalid. Limits are capped at maxLimit, and a missing
+// limit is returned as zero.
+func parsePage(w http.ResponseWriter, r *http.Request, maxLimit int) (limit, offset int, ok bool) {
+	var err error
+	params := r.URL.Query()
+
+	if value := params.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return 0, 0, false
+		}
+		if limit > maxLimit {
+			limit = maxLimit
+		}
+	}
+
+	if value := params.Get("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			http.Error(w, "Invalid offset parameter", http.StatusBadRequest)
+			return 0, 0, false
+		}
+	}
+
+	return limit, offset, true
+}
+
+// writeAdminError maps errors of the admin and audit services to responses
+func writeAdminError(w http.ResponseWriter, err error) {
+	switch err {
+	case services.ErrUserNotFound:
+		http.Error(w, "User not found", http.StatusNotFound)
+	case services.ErrInvalidRole:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case services.ErrCannotModifySelf:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case services.ErrPermissionDenied:
+		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+	default:
+		log.Printf("Admin error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
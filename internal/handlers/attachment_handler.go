@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/dbvitor/chat-go/internal/services"
+	"github.com/dbvitor/chat-go/pkg/auth"
+	"github.com/gorilla/mux"
+)
+
+// AttachmentHandler handles attachment upload and download HTTP requests
+type AttachmentHandler struct {
+	attachmentService *services.AttachmentService
+}
+
+// NewAttachmentHandler creates a new attachment handler
+func NewAttachmentHandler(attachmentService *services.AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{
+		attachmentService: attachmentService,
+	}
+}
+
+// Upload handles a multipart file upload to a chatroom. The returned
+// attachment ID is then sent along with a message over the WebSocket.
+func (h *AttachmentHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	userID := auth.GetPrincipal(r).UserID()
+
+	// Get chatroom ID from URL
+	vars := mux.Vars(r)
+	chatroomID := vars["id"]
+
+	// Limit the request body, leaving some room for the multipart framing
+	r.Body = http.MaxBytesReader(w, r.Body, h.attachmentService.MaxSize()+64*1024)
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		if _, ok := err.(*http.MaxBytesError); ok {
+			http.Error(w, "File is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "A file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	// Store attachment
+	attachment, err := h.attachmentService.Upload(userID, chatroomID, header.Filename, file, header.Size)
+	if err != nil {
+		switch err {
+		case services.ErrChatroomNotFound:
+			http.Error(w, "Chatroom not found", http.StatusNotFound)
+		case services.ErrChatroomArchived:
+			http.Error(w, "This chatroom is archived and read-only", http.StatusConflict)
+		case services.ErrAttachmentTooLarge:
+			http.Error(w, "File is too large", http.StatusRequestEntityTooLarge)
+		case services.ErrAttachmentTypeNotAllowed:
+			http.Error(w, "File type is not allowed", http.StatusUnsupportedMediaType)
+		default:
+			log.Printf("Error uploading attachment: %v", err)
+			http.Error(w, "Failed to upload file", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attachment)
+}
+
+// Download streams an attachment to users who can read its chatroom
+func (h *AttachmentHandler) Download(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	userID := auth.GetPrincipal(r).UserID()
+
+	// Get attachment ID from URL
+	vars := mux.Vars(r)
+	attachmentID := vars["id"]
+
+	attachment, content, err := h.attachmentService.Open(userID, attachmentID)
+	if err != nil {
+		if err == services.ErrAttachmentNotFound {
+			http.Error(w, "Attachment not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error opening attachment: %v", err)
+		http.Error(w, "Failed to load attachment", http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	// Images are shown inline, everything else is downloaded
+	disposition := "attachment"
+	if attachment.IsImage() {
+		disposition = "inline"
+	}
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+
+	if _, err := io.Copy(w, content); err != nil {
+		log.Printf("Error streaming attachment: %v", err)
+	}
+}
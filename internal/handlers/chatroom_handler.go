@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 
+	"github.com/dbvitor/chat-go/internal/models"
 	"github.com/dbvitor/chat-go/internal/services"
 	"github.com/dbvitor/chat-go/pkg/auth"
 	"github.com/gorilla/mux"
@@ -11,16 +13,55 @@ import (
 
 // ChatroomHandler handles chatroom-related HTTP requests
 type ChatroomHandler struct {
-	chatroomService *services.ChatroomService
+	chatroomService    *services.ChatroomService
+	presenceService    *services.PresenceService
+	readReceiptService *services.ReadReceiptService
+	pinService         *services.PinService
+	onUpdate           func(chatroom *models.Chatroom) // pushes changed chatrooms to connected clients
+	onDelete           func(chatroomID string)         // disconnects the clients of a deleted chatroom
 }
 
 // NewChatroomHandler creates a new chatroom handler
-func NewChatroomHandler(chatroomService *services.ChatroomService) *ChatroomHandler {
+func NewChatroomHandler(
+	chatroomService *services.ChatroomService,
+	presenceService *services.PresenceService,
+	readReceiptService *services.ReadReceiptService,
+	pinService *services.PinService,
+	onUpdate func(chatroom *models.Chatroom),
+	onDelete func(chatroomID string),
+) *ChatroomHandler {
 	return &ChatroomHandler{
-		chatroomService: chatroomService,
+		chatroomService:    chatroomService,
+		presenceService:    presenceService,
+		readReceiptService: readReceiptService,
+		pinService:         pinService,
+		onUpdate:           onUpdate,
+		onDelete:           onDelete,
 	}
 }
 
+// ChatroomResponse is a chatroom summary along with the requesting user's
+// unread count and whether they may manage it
+type ChatroomResponse struct {
+	*models.ChatroomSummary
+	UnreadCount int  `json:"unread_count"`
+	CanManage   bool `json:"can_manage"`
+}
+
+// ChatroomsResponse represents a page of chatrooms
+type ChatroomsResponse struct {
+	Chatrooms  []ChatroomResponse `json:"chatrooms"`
+	NextOffset *int               `json:"next_offset,omitempty"`
+}
+
+// ChatroomDetailResponse is a chatroom along with its pinned messages and
+// whether the requesting user may manage it
+type ChatroomDetailResponse struct {
+	*models.Chatroom
+	CanManage bool          `json:"can_manage"`
+	Pins      []*models.Pin `json:"pins"`
+}
+
 // CreateChatroomRequest represents the request body for creating a chatroom
 type CreateChatroomRequest struct {
 	Name string `json:"name"`
@@ -28,12 +69,6 @@ type CreateChatroomRequest struct {
 
 // Create handles chatroom creation
 func (h *ChatroomHandler) Create(w http.ResponseWriter, r *http.Request) {
-	// Check if authenticated
-	if !auth.IsAuthenticated(r) {
-		http.Error(w, "Not authenticated", http.StatusUnauthorized)
-		return
-	}
-
 	var req CreateChatroomRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -45,9 +80,16 @@ func (h *ChatroomHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chatroom, err := h.chatroomService.Create(req.Name)
+	// Get authenticated user, resolved by the auth middleware
+	user := auth.GetPrincipal(r).Actor()
+
+	chatroom, err := h.chatroomService.Create(user, req.Name)
 	if err != nil {
-		http.Error(w, "Failed to create chatroom", http.StatusInternalServerError)
+		if err == services.ErrChatroomLimitReached {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		writeChatroomError(w, err, "Failed to create chatroom")
 		return
 	}
 
@@ -56,34 +98,145 @@ func (h *ChatroomHandler) Create(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(chatroom)
 }
 
-// GetAll handles retrieving all chatrooms
+// Update handles renaming a chatroom or changing its topic or description.
+// Only the fields present in the body are changed.
+func (h *ChatroomHandler) Update(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	user := auth.GetPrincipal(r).Actor()
+
+	var update models.ChatroomUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	chatroom, err := h.chatroomService.Update(user, mux.Vars(r)["id"], &update)
+	if err != nil {
+		writeChatroomError(w, err, "Failed to update chatroom")
+		return
+	}
+
+	h.respondUpdated(w, chatroom)
+}
+
+// Archive handles making a chatroom read-only
+func (h *ChatroomHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	user := auth.GetPrincipal(r).Actor()
+
+	chatroom, err := h.chatroomService.Archive(user, mux.Vars(r)["id"])
+	if err != nil {
+		writeChatroomError(w, err, "Failed to archive chatroom")
+		return
+	}
+
+	log.Printf("User %s archived chatroom %s", user.Username, chatroom.Name)
+	h.respondUpdated(w, chatroom)
+}
+
+// Unarchive handles letting users post in an archived chatroom again
+func (h *ChatroomHandler) Unarchive(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	user := auth.GetPrincipal(r).Actor()
+
+	chatroom, err := h.chatroomService.Unarchive(user, mux.Vars(r)["id"])
+	if err != nil {
+		writeChatroomError(w, err, "Failed to unarchive chatroom")
+		return
+	}
+
+	log.Printf("User %s unarchived chatroom %s", user.Username, chatroom.Name)
+	h.respondUpdated(w, chatroom)
+}
+
+// respondUpdated notifies connected clients of a changed chatroom and returns it
+func (h *ChatroomHandler) respondUpdated(w http.ResponseWriter, chatroom *models.Chatroom) {
+	h.onUpdate(chatroom)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chatroom)
+}
+
+// Delete handles deleting a chatroom with all of its messages
+func (h *ChatroomHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	user := auth.GetPrincipal(r).Actor()
+	chatroomID := mux.Vars(r)["id"]
+
+	chatroom, err := h.chatroomService.Delete(user, chatroomID)
+	if err != nil {
+		writeChatroomError(w, err, "Failed to delete chatroom")
+		return
+	}
+
+	h.onDelete(chatroom.ID)
+
+	log.Printf("User %s deleted chatroom %s", user.Username, chatroom.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAll handles listing chatrooms.
+// Query parameters: q (part of the name), sort (name, activity or members),
+// limit and offset.
 func (h *ChatroomHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	// Check if authenticated
-	if !auth.IsAuthenticated(r) {
-		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+	// Get authenticated user, resolved by the auth middleware
+	principal := auth.GetPrincipal(r)
+	userID := principal.UserID()
+
+	limit, offset, ok := parsePage(w, r, services.MaxChatroomPageSize)
+	if !ok {
 		return
 	}
+	params := r.URL.Query()
+	query := models.ChatroomQuery{
+		Name:   params.Get("q"),
+		Sort:   params.Get("sort"),
+		Limit:  limit,
+		Offset: offset,
+	}
 
-	// Get all chatrooms
-	chatrooms, err := h.chatroomService.GetAll()
+	// Get a page of chatrooms
+	chatrooms, hasMore, err := h.chatroomService.List(query)
 	if err != nil {
+		if err == services.ErrInvalidChatroomSort {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Error listing chatrooms: %v", err)
 		http.Error(w, "Failed to retrieve chatrooms", http.StatusInternalServerError)
 		return
 	}
 
+	// Get unread counts; the list is still useful without them
+	chatroomIDs := make([]string, 0, len(chatrooms))
+	for _, chatroom := range chatrooms {
+		chatroomIDs = append(chatroomIDs, chatroom.ID)
+	}
+	unreadCounts, err := h.readReceiptService.GetUnreadCounts(userID, chatroomIDs)
+	if err != nil {
+		log.Printf("Error fetching unread counts: %v", err)
+	}
+
+	response := ChatroomsResponse{Chatrooms: make([]ChatroomResponse, 0, len(chatrooms))}
+	for _, chatroom := range chatrooms {
+		response.Chatrooms = append(response.Chatrooms, ChatroomResponse{
+			ChatroomSummary: chatroom,
+			UnreadCount:     unreadCounts[chatroom.ID],
+			CanManage:       services.CanManage(principal.Actor(), chatroom.Chatroom),
+		})
+	}
+	if hasMore {
+		next := offset + len(chatrooms)
+		response.NextOffset = &next
+	}
+
 	// Return chatrooms
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(chatrooms)
+	json.NewEncoder(w).Encode(response)
 }
 
 // GetByID handles retrieving a chatroom by ID
 func (h *ChatroomHandler) GetByID(w http.ResponseWriter, r *http.Request) {
-	// Check if authenticated
-	if !auth.IsAuthenticated(r) {
-		http.Error(w, "Not authenticated", http.StatusUnauthorized)
-		return
-	}
-
 	// Get chatroom ID from URL
 	vars := mux.Vars(r)
 	chatroomID := vars["id"]
@@ -95,7 +248,56 @@ func (h *ChatroomHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	pins, err := h.pinService.List(chatroom.ID)
+	if err != nil {
+		writeChatroomError(w, err, "Failed to retrieve pinned messages")
+		return
+	}
+
 	// Return chatroom info
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(chatroom)
+	json.NewEncoder(w).Encode(ChatroomDetailResponse{
+		Chatroom:  chatroom,
+		CanManage: services.CanManage(auth.GetPrincipal(r).Actor(), chatroom),
+		Pins:      pins,
+	})
+}
+
+// GetPresence handles retrieving the users currently online in a chatroom
+func (h *ChatroomHandler) GetPresence(w http.ResponseWriter, r *http.Request) {
+	// Get chatroom ID from URL
+	vars := mux.Vars(r)
+	chatroomID := vars["id"]
+
+	// Check if chatroom exists
+	_, err := h.chatroomService.GetByID(chatroomID)
+	if err != nil {
+		http.Error(w, "Chatroom not found", http.StatusNotFound)
+		return
+	}
+
+	// Return online users
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"chatroom_id": chatroomID,
+		"users":       h.presenceService.GetByChatroomID(chatroomID),
+	})
+}
+
+// writeChatroomError maps errors of the chatroom service to responses,
+// logging unexpected ones under a generic failure message
+func writeChatroomError(w http.ResponseWriter, err error, failure string) {
+	switch err {
+	case services.ErrChatroomNotFound:
+		http.Error(w, "Chatroom not found", http.StatusNotFound)
+	case services.ErrPermissionDenied:
+		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+	case services.ErrInvalidChatroomName, services.ErrInvalidTopic, services.ErrInvalidDescription:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case services.ErrChatroomNameTaken:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("%s: %v", failure, err)
+		http.Error(w, failure, http.StatusInternalServerError)
+	}
 }
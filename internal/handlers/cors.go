@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OriginPolicy decides which web origins may call the API from a browser and
+// open WebSocket connections. The origin serving the web client is always allowed.
+type OriginPolicy struct {
+	allowed map[string]bool
+}
+
+// NewOriginPolicy creates an origin policy allowing the given origins, such
+// as "https://chat.example.com", in addition to the server's own
+func NewOriginPolicy(origins []string) *OriginPolicy {
+	policy := &OriginPolicy{allowed: make(map[string]bool)}
+	for _, origin := range origins {
+		if origin == "*" {
+			// Credentialed requests can't use a wildcard, and allowing every
+			// origin would let any site act as the logged-in user
+			log.Println("Warning: ignoring \"*\" in CORS_ALLOWED_ORIGINS; list the allowed origins instead")
+			continue
+		}
+		policy.allowed[normalizeOrigin(origin)] = true
+	}
+	return policy
+}
+
+// Allowed reports whether a request may come from the origin
+func (p *OriginPolicy) Allowed(r *http.Request, origin string) bool {
+	if p.allowed[normalizeOrigin(origin)] {
+		return true
+	}
+
+	// Same origin: the page was served by this server
+	parsed, err := url.Parse(origin)
+	return err == nil && parsed.Host != "" && strings.EqualFold(parsed.Host, r.Host)
+}
+
+// CheckWebSocketOrigin is the upgrader's origin check. Browsers always send
+// the Origin header, so requests without one come from other clients, which
+// can't be used by another site to act as the user.
+func (p *OriginPolicy) CheckWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if !p.Allowed(r, origin) {
+		log.Printf("Rejected WebSocket connection from origin %s", origin)
+		return false
+	}
+	return true
+}
+
+// Middleware sets the CORS headers for allowed cross-origin requests and
+// answers preflight requests
+func (p *OriginPolicy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		if origin != "" && p.Allowed(r, origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, PATCH, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization, "+csrfHeader)
+		}
+
+		// Handle preflight requests; without the headers above, the browser
+		// refuses the actual request
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		// Call the next handler
+		next.ServeHTTP(w, r)
+	})
+}
+
+// normalizeOrigin lowercases an origin and drops a trailing slash, so
+// configured origins match the Origin header
+func normalizeOrigin(origin string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(origin), "/"))
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOriginPolicy(t *testing.T) {
+	policy := NewOriginPolicy([]string{"https://app.example.com/", "*"})
+
+	testCases := []struct {
+		name    string
+		host    string
+		origin  string
+		allowed bool
+	}{
+		{"Same origin", "chat.example.com", "https://chat.example.com", true},
+		{"Same origin with port", "localhost:8080", "http://localhost:8080", true},
+		{"Configured origin", "chat.example.com", "https://APP.example.com", true},
+		{"Other origin", "chat.example.com", "https://evil.example.com", false},
+		{"Wildcard is ignored", "chat.example.com", "https://anything.example.com", false},
+		{"Null origin", "chat.example.com", "null", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest("GET", "http://"+tc.host+"/api/ws/room", nil)
+			request.Header.Set("Origin", tc.origin)
+
+			if allowed := policy.CheckWebSocketOrigin(request); allowed != tc.allowed {
+				t.Errorf("Expected WebSocket origin allowed to be %t, got: %t", tc.allowed, allowed)
+			}
+
+			recorder := httptest.NewRecorder()
+			policy.Middleware(okHandler).ServeHTTP(recorder, request)
+			header := recorder.Header().Get("Access-Control-Allow-Origin")
+			if tc.allowed && header != tc.origin {
+				t.Errorf("Expected CORS header for %s, got: %q", tc.origin, header)
+			}
+			if !tc.allowed && header != "" {
+				t.Errorf("Expected no CORS header, got: %q", header)
+			}
+		})
+	}
+
+	// Clients other than browsers don't send an Origin header
+	request := httptest.NewRequest("GET", "http://chat.example.com/api/ws/room", nil)
+	if !policy.CheckWebSocketOrigin(request) {
+		t.Error("Expected WebSocket connection without Origin header to be allowed")
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/dbvitor/chat-go/pkg/auth"
+)
+
+// CSRF protection uses double-submit cookies: a random token is set in a
+// cookie that scripts of the page can read, and state-changing requests must
+// repeat it in a header. Other sites can make the browser send the cookie,
+// but can't read it to set the header.
+const (
+	csrfCookie = "csrf_token"
+	csrfHeader = "X-CSRF-Token"
+)
+
+// CSRFTokenResponse carries the CSRF token, for clients on allowed origins
+// that can't read the cookie
+type CSRFTokenResponse struct {
+	Token string `json:"token"`
+}
+
+// csrfMiddleware makes sure every browser has a CSRF token and checks it on
+// requests with unsafe methods. Requests with an Authorization header are
+// exempt: they don't use the cookie session, so a forged one carries no credentials.
+func csrfMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := ensureCSRFCookie(w, r)
+
+		if !isSafeMethod(r.Method) && !auth.HasAuthorizationHeader(r) {
+			sent := r.Header.Get(csrfHeader)
+			if sent == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				writeJSONError(w, http.StatusForbidden, "Invalid or missing CSRF token")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GetCSRFToken handles returning the CSRF token of the browser
+func GetCSRFToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CSRFTokenResponse{Token: ensureCSRFCookie(w, r)})
+}
+
+// ensureCSRFCookie returns the CSRF token of the request, setting a new one
+// if the browser doesn't have one yet
+func ensureCSRFCookie(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(csrfCookie); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     "/",
+		Secure:   auth.SecureCookies(r),
+		HttpOnly: false, // read by the web client to send it back in the header
+		SameSite: http.SameSiteLaxMode,
+	})
+	return token
+}
+
+// isSafeMethod reports whether a method doesn't change state
+func isSafeMethod(method string) bool {
+	return method == "GET" || method == "HEAD" || method == "OPTIONS"
+}
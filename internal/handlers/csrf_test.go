@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRFMiddleware(t *testing.T) {
+	handler := csrfMiddleware(okHandler)
+
+	// A first visit gets a token cookie
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	cookies := recorder.Result().Cookies()
+	if recorder.Code != http.StatusOK || len(cookies) != 1 || cookies[0].Name != csrfCookie || cookies[0].HttpOnly {
+		t.Fatalf("Expected a readable CSRF cookie on a safe request, got: %d %v", recorder.Code, cookies)
+	}
+	token := cookies[0]
+
+	testCases := []struct {
+		name          string
+		method        string
+		cookie        bool
+		header        string
+		authorization string
+		status        int
+	}{
+		{"Safe method without token", "GET", true, "", "", http.StatusOK},
+		{"Matching token", "POST", true, token.Value, "", http.StatusOK},
+		{"Missing header", "POST", true, "", "", http.StatusForbidden},
+		{"Wrong header", "DELETE", true, "forged", "", http.StatusForbidden},
+		{"Header without cookie", "POST", false, token.Value, "", http.StatusForbidden},
+		{"Bearer token is exempt", "POST", false, "", "Bearer cgp_token", http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(tc.method, "/api/chatrooms", nil)
+			if tc.cookie {
+				request.AddCookie(token)
+			}
+			if tc.header != "" {
+				request.Header.Set(csrfHeader, tc.header)
+			}
+			if tc.authorization != "" {
+				request.Header.Set("Authorization", tc.authorization)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			if recorder.Code != tc.status {
+				t.Errorf("Expected status %d, got: %d", tc.status, recorder.Code)
+			}
+		})
+	}
+}
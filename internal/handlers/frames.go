@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// Types of the non-message frames sent over the WebSocket connection.
+// Chat messages are sent as models.Message and use the message types instead.
+const (
+	FrameTypeError          = "error"
+	FrameTypePresence       = "presence"
+	FrameTypeTyping         = "typing"
+	FrameTypeRead           = "read"
+	FrameTypeReceipts       = "receipts"
+	FrameTypeMention        = "mention"
+	FrameTypeMessageUpdated = "message_updated"
+	FrameTypeProfile        = "profile"
+	FrameTypeRoomUpdated    = "room_updated"
+	FrameTypeRoomDeleted    = "room_deleted"
+	FrameTypeRoomInfo       = "room_info"
+	FrameTypePins           = "pins"
+	FrameTypeScheduled      = "scheduled"
+	FrameTypeReminder       = "reminder"
+)
+
+// Types of the frames a client can send. Frames without a type are chat messages.
+const (
+	ClientFrameMessage     = "message"
+	ClientFrameTypingStart = "typing_start"
+	ClientFrameTypingStop  = "typing_stop"
+	ClientFrameMarkRead    = "mark_read"
+)
+
+// ErrorFrame is sent to a client when one of its frames is rejected
+type ErrorFrame struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// PresenceFrame lists the users currently connected to a chatroom
+type PresenceFrame struct {
+	Type       string             `json:"type"`
+	ChatroomID string             `json:"chatroom_id"`
+	Users      []*models.Presence `json:"users"`
+}
+
+// TypingFrame tells a chatroom that a user started or stopped typing
+type TypingFrame struct {
+	Type       string `json:"type"`
+	ChatroomID string `json:"chatroom_id"`
+	UserID     string `json:"user_id"`
+	Username   string `json:"username"`
+	Typing     bool   `json:"typing"`
+}
+
+// ReadFrame tells a chatroom that a user has read up to a message
+type ReadFrame struct {
+	Type string `json:"type"`
+	*models.ReadReceipt
+}
+
+// ReceiptsFrame lists the read positions of every user in a chatroom
+type ReceiptsFrame struct {
+	Type       string                `json:"type"`
+	ChatroomID string                `json:"chatroom_id"`
+	Receipts   []*models.ReadReceipt `json:"receipts"`
+}
+
+// MentionFrame tells a user, in whichever room they are connected to, that
+// they were mentioned in a message
+type MentionFrame struct {
+	Type    string          `json:"type"`
+	Message *models.Message `json:"message"`
+}
+
+// MessageUpdatedFrame replaces a message clients have already rendered, e.g.
+// once its link previews are ready
+type MessageUpdatedFrame struct {
+	Type    string          `json:"type"`
+	Message *models.Message `json:"message"`
+}
+
+// ProfileFrame tells clients that a user changed their profile, so their
+// name and avatar can be updated on messages already rendered
+type ProfileFrame struct {
+	Type    string          `json:"type"`
+	Profile *models.Profile `json:"profile"`
+}
+
+// RoomUpdatedFrame tells clients that a chatroom was renamed, changed its
+// topic or description, or was archived or restored
+type RoomUpdatedFrame struct {
+	Type     string           `json:"type"`
+	Chatroom *models.Chatroom `json:"chatroom"`
+}
+
+// RoomDeletedFrame tells clients that a chatroom was deleted; connections to
+// the chatroom itself are closed right after
+type RoomDeletedFrame struct {
+	Type       string `json:"type"`
+	ChatroomID string `json:"chatroom_id"`
+}
+
+// RoomInfoFrame is sent to a client when it joins a chatroom, with the room's
+// topic, description and pinned messages
+type RoomInfoFrame struct {
+	Type      string           `json:"type"`
+	Chatroom  *models.Chatroom `json:"chatroom"`
+	CanManage bool             `json:"can_manage"`
+	Pins      []*models.Pin    `json:"pins"`
+}
+
+// PinsFrame tells a chatroom that a message was pinned or unpinned, listing
+// the room's pins
+type PinsFrame struct {
+	Type       string        `json:"type"`
+	ChatroomID string        `json:"chatroom_id"`
+	Pins       []*models.Pin `json:"pins"`
+}
+
+// ScheduledFrame confirms to a client that its reminder command was scheduled
+type ScheduledFrame struct {
+	Type     string                   `json:"type"`
+	Schedule *models.ScheduledMessage `json:"schedule"`
+}
+
+// ReminderFrame delivers a due reminder to its author, in whichever room
+// they are connected to
+type ReminderFrame struct {
+	Type     string                   `json:"type"`
+	Reminder *models.ScheduledMessage `json:"reminder"`
+}
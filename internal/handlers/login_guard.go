@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/dbvitor/chat-go/pkg/auth"
+	"github.com/dbvitor/chat-go/pkg/ratelimit"
+)
+
+// loginGuard locks out a username on one client IP after repeated wrong
+// passwords or two-factor codes. Every handler that checks one shares the
+// guard and its keys, so failures anywhere count towards the same lockout,
+// and nobody can lock an account out for others.
+type loginGuard struct {
+	lockout    *ratelimit.Lockout
+	trustProxy bool
+}
+
+// newLoginGuard creates a login guard. trustProxy tells whether client IPs
+// are read from the forwarding headers of a reverse proxy.
+func newLoginGuard(lockout *ratelimit.Lockout, trustProxy bool) *loginGuard {
+	return &loginGuard{
+		lockout:    lockout,
+		trustProxy: trustProxy,
+	}
+}
+
+// key identifies the attempts of a username from the request's client IP
+func (g *loginGuard) key(r *http.Request, username string) string {
+	return strings.ToLower(username) + "@" + auth.ClientIP(r, g.trustProxy)
+}
+
+// refuse writes a Too Many Requests response if the key is locked out
+func (g *loginGuard) refuse(w http.ResponseWriter, key string) bool {
+	locked, remaining := g.lockout.Locked(key)
+	if locked {
+		writeRetryAfter(w, remaining)
+		http.Error(w, "Too many failed attempts, try again later", http.StatusTooManyRequests)
+	}
+	return locked
+}
+
+// fail counts a wrong password or code of a user
+func (g *loginGuard) fail(key, username string) {
+	if g.lockout.Fail(key) {
+		log.Printf("Login locked out for user %s after repeated failures", username)
+	}
+}
+
+// reset clears the failures of a key after a correct password or code
+func (g *loginGuard) reset(key string) {
+	g.lockout.Reset(key)
+}
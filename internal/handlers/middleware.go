@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/database"
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/internal/services"
+	"github.com/dbvitor/chat-go/pkg/auth"
+	"github.com/dbvitor/chat-go/pkg/ratelimit"
+)
+
+// rateLimitMiddleware throttles requests per client IP and, when logged in, per user
+func rateLimitMiddleware(limiter *ratelimit.KeyedLimiter, trustProxy bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Let preflight requests through
+			if r.Method == "OPTIONS" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			keys := []string{"ip:" + auth.ClientIP(r, trustProxy)}
+			if principal := auth.GetPrincipal(r); principal != nil {
+				keys = append(keys, "user:"+principal.UserID())
+			}
+
+			for _, key := range keys {
+				if !limiter.Allow(key) {
+					writeRetryAfter(w, limiter.RetryAfter(key))
+					http.Error(w, "Too many requests", http.StatusTooManyRequests)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeRetryAfter sets the Retry-After header, rounded up to whole seconds
+func writeRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+}
+
+// ErrorResponse is the JSON body of authentication and authorization errors
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeJSONError writes an error as a JSON ErrorResponse
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+}
+
+// authMiddleware resolves the principal of a request once, from its bearer
+// token or session cookie, and stores it in the request context. Requests
+// without valid credentials continue unauthenticated; protected routes
+// reject them with requireAuth.
+func authMiddleware(userService *services.UserService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session, token, err := auth.ResolveCredentials(r)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal := &auth.Principal{Session: session, Token: token}
+			var userID string
+			if token != nil {
+				userID = token.UserID
+			} else {
+				userID = session.UserID
+			}
+
+			principal.User, err = userService.GetByID(userID)
+			if err != nil {
+				if !database.IsNotFound(err) {
+					log.Printf("Error loading authenticated user: %v", err)
+					writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+					return
+				}
+				// The user no longer exists, so the credentials are stale
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Credentials of disabled accounts, including access tokens, stop
+			// working until an administrator enables the account again
+			if principal.User.Disabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// requireAuth rejects requests without a principal
+func requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Let preflight requests through
+		if r.Method == "OPTIONS" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if auth.GetPrincipal(r) == nil {
+			writeJSONError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireRole rejects requests whose principal doesn't have one of the roles
+func requireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return requireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "OPTIONS" && !auth.GetPrincipal(r).HasRole(roles...) {
+				writeJSONError(w, http.StatusForbidden, "Insufficient permissions")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// requirePermission rejects requests whose principal's role doesn't grant a
+// permission. Requests made with an access token also need the scope the
+// permission requires.
+func requirePermission(permission models.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return requireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "OPTIONS" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal := auth.GetPrincipal(r)
+			if !principal.Can(permission) {
+				writeJSONError(w, http.StatusForbidden, "Insufficient permissions")
+				return
+			}
+			if principal.Token != nil && !principal.Token.HasScope(permission.RequiredScope()) {
+				writeJSONError(w, http.StatusForbidden, "Token does not have the required scope")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// requireSession rejects requests authenticated with a bearer token, for
+// account management that should only be done from the browser
+func requireSession(next http.Handler) http.Handler {
+	return requireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "OPTIONS" && auth.GetPrincipal(r).Session == nil {
+			writeJSONError(w, http.StatusForbidden, "This endpoint requires a browser session")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// tokenScopeMiddleware rejects requests made with a bearer token that lacks
+// the scope the request method needs. Cookie sessions aren't restricted.
+func tokenScopeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal := auth.GetPrincipal(r)
+		if principal != nil && principal.Token != nil && !principal.Token.HasScope(auth.RequiredScope(r.Method)) {
+			writeJSONError(w, http.StatusForbidden, "Token does not have the required scope")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
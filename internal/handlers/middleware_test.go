@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/pkg/auth"
+)
+
+// serve runs a request through a handler wrapped in middleware, with an optional principal
+func serve(handler http.Handler, method string, principal *auth.Principal) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, "/api/test", nil)
+	if principal != nil {
+		request = request.WithContext(auth.WithPrincipal(request.Context(), principal))
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestAuthorizationMiddleware(t *testing.T) {
+	user := &models.User{ID: "user-1", Username: "alice"}
+	sessionPrincipal := &auth.Principal{User: user, Session: &models.Session{ID: "session-1"}}
+	readToken := &auth.Principal{User: user, Token: &models.AccessToken{Scopes: []string{models.ScopeRead}}}
+	writeToken := &auth.Principal{User: user, Token: &models.AccessToken{Scopes: []string{models.ScopeWrite}}}
+	admin := &models.User{ID: "user-2", Username: "root", Role: models.RoleAdmin}
+	adminSession := &auth.Principal{User: admin, Session: &models.Session{ID: "session-2"}}
+	adminWriteToken := &auth.Principal{User: admin, Token: &models.AccessToken{Scopes: []string{models.ScopeWrite}}}
+	adminToken := &auth.Principal{User: admin, Token: &models.AccessToken{Scopes: []string{models.ScopeAdmin}}}
+	bot := &auth.Principal{User: &models.User{ID: "bot", Role: models.RoleBot}, Session: &models.Session{ID: "session-3"}}
+	manageUsers := requirePermission(models.PermissionManageUsers)(okHandler)
+	createRooms := requirePermission(models.PermissionCreateRooms)(okHandler)
+
+	testCases := []struct {
+		name      string
+		handler   http.Handler
+		method    string
+		principal *auth.Principal
+		status    int
+	}{
+		{"Anonymous request to protected route", requireAuth(okHandler), "GET", nil, http.StatusUnauthorized},
+		{"Preflight request to protected route", requireAuth(okHandler), "OPTIONS", nil, http.StatusOK},
+		{"Authenticated request", requireAuth(okHandler), "GET", sessionPrincipal, http.StatusOK},
+		{"Missing role", requireRole(models.RoleAdmin)(okHandler), "GET", sessionPrincipal, http.StatusForbidden},
+		{"Anonymous request to role route", requireRole(models.RoleAdmin)(okHandler), "GET", nil, http.StatusUnauthorized},
+		{"Matching role", requireRole(models.RoleAdmin, models.RoleUser)(okHandler), "GET", sessionPrincipal, http.StatusOK},
+		{"Anonymous request to permission route", manageUsers, "GET", nil, http.StatusUnauthorized},
+		{"User without permission", manageUsers, "GET", sessionPrincipal, http.StatusForbidden},
+		{"Admin with session", manageUsers, "GET", adminSession, http.StatusOK},
+		{"Admin token without admin scope", manageUsers, "GET", adminWriteToken, http.StatusForbidden},
+		{"Admin token with admin scope", manageUsers, "GET", adminToken, http.StatusOK},
+		{"User permission with write token", createRooms, "POST", writeToken, http.StatusOK},
+		{"Bot without permission", createRooms, "POST", bot, http.StatusForbidden},
+		{"Session route with a session", requireSession(okHandler), "POST", sessionPrincipal, http.StatusOK},
+		{"Session route with a token", requireSession(okHandler), "POST", writeToken, http.StatusForbidden},
+		{"Read token reading", tokenScopeMiddleware(okHandler), "GET", readToken, http.StatusOK},
+		{"Read token writing", tokenScopeMiddleware(okHandler), "POST", readToken, http.StatusForbidden},
+		{"Write token writing", tokenScopeMiddleware(okHandler), "DELETE", writeToken, http.StatusOK},
+		{"Session writing", tokenScopeMiddleware(okHandler), "POST", sessionPrincipal, http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := serve(tc.handler, tc.method, tc.principal)
+			if recorder.Code != tc.status {
+				t.Fatalf("Expected status %d, got: %d", tc.status, recorder.Code)
+			}
+
+			// Authentication and authorization errors are JSON
+			if tc.status == http.StatusUnauthorized || tc.status == http.StatusForbidden {
+				if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+					t.Errorf("Expected JSON error, got content type: %s", contentType)
+				}
+				var response ErrorResponse
+				if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil || response.Error == "" {
+					t.Errorf("Expected error message in body, got: %v", err)
+				}
+			}
+		})
+	}
+}
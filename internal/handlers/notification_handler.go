@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/internal/services"
+	"github.com/dbvitor/chat-go/pkg/auth"
+	"github.com/gorilla/mux"
+)
+
+// NotificationHandler handles notification feed HTTP requests
+type NotificationHandler struct {
+	notificationService *services.NotificationService
+}
+
+// NewNotificationHandler creates a new notification handler
+func NewNotificationHandler(notificationService *services.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+	}
+}
+
+// NotificationsResponse represents a page of the notification feed
+type NotificationsResponse struct {
+	Notifications []*models.Notification `json:"notifications"`
+	UnreadCount   int                    `json:"unread_count"`
+}
+
+// GetAll handles retrieving the user's notifications.
+// Query parameters: unread=true, before (RFC 3339) and limit.
+func (h *NotificationHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	userID := auth.GetPrincipal(r).UserID()
+
+	// Parse query parameters
+	var err error
+	params := r.URL.Query()
+	unreadOnly := params.Get("unread") == "true"
+
+	var before time.Time
+	if value := params.Get("before"); value != "" {
+		before, err = time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			http.Error(w, "Invalid before parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := 0
+	if value := params.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Get notifications
+	notifications, err := h.notificationService.GetFeed(userID, unreadOnly, before, limit)
+	if err != nil {
+		log.Printf("Error fetching notifications: %v", err)
+		http.Error(w, "Failed to retrieve notifications", http.StatusInternalServerError)
+		return
+	}
+	if notifications == nil {
+		notifications = []*models.Notification{}
+	}
+
+	unreadCount, err := h.notificationService.CountUnread(userID)
+	if err != nil {
+		log.Printf("Error counting unread notifications: %v", err)
+		http.Error(w, "Failed to retrieve notifications", http.StatusInternalServerError)
+		return
+	}
+
+	// Return notifications
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(NotificationsResponse{
+		Notifications: notifications,
+		UnreadCount:   unreadCount,
+	})
+}
+
+// MarkRead handles marking a single notification as read
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	userID := auth.GetPrincipal(r).UserID()
+
+	// Get notification ID from URL
+	vars := mux.Vars(r)
+	notificationID := vars["id"]
+
+	err := h.notificationService.MarkRead(userID, notificationID)
+	if err != nil {
+		if err == services.ErrNotificationNotFound {
+			http.Error(w, "Notification not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error marking notification as read: %v", err)
+		http.Error(w, "Failed to update notification", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkAllRead handles marking all of the user's notifications as read
+func (h *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	userID := auth.GetPrincipal(r).UserID()
+
+	if err := h.notificationService.MarkAllRead(userID); err != nil {
+		log.Printf("Error marking notifications as read: %v", err)
+		http.Error(w, "Failed to update notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/internal/services"
+	"github.com/dbvitor/chat-go/pkg/auth"
+	"github.com/gorilla/mux"
+)
+
+// PinHandler handles pinned message HTTP requests
+type PinHandler struct {
+	pinService *services.PinService
+	onChange   func(chatroomID string, pins []*models.Pin) // pushes changed pins to the room's clients
+}
+
+// NewPinHandler creates a new pin handler
+func NewPinHandler(pinService *services.PinService, onChange func(chatroomID string, pins []*models.Pin)) *PinHandler {
+	return &PinHandler{
+		pinService: pinService,
+		onChange:   onChange,
+	}
+}
+
+// List handles retrieving the pinned messages of a chatroom
+func (h *PinHandler) List(w http.ResponseWriter, r *http.Request) {
+	pins, err := h.pinService.List(mux.Vars(r)["id"])
+	if err != nil {
+		writePinError(w, err, "Failed to retrieve pinned messages")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pins)
+}
+
+// Pin handles pinning a message to its chatroom
+func (h *PinHandler) Pin(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	user := auth.GetPrincipal(r).Actor()
+	vars := mux.Vars(r)
+
+	pins, err := h.pinService.Pin(user, vars["id"], vars["messageId"])
+	if err != nil {
+		writePinError(w, err, "Failed to pin message")
+		return
+	}
+
+	h.respondChanged(w, vars["id"], pins)
+}
+
+// Unpin handles removing a pinned message from its chatroom
+func (h *PinHandler) Unpin(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	user := auth.GetPrincipal(r).Actor()
+	vars := mux.Vars(r)
+
+	pins, err := h.pinService.Unpin(user, vars["id"], vars["messageId"])
+	if err != nil {
+		writePinError(w, err, "Failed to unpin message")
+		return
+	}
+
+	h.respondChanged(w, vars["id"], pins)
+}
+
+// respondChanged notifies the room's clients of its changed pins and returns them
+func (h *PinHandler) respondChanged(w http.ResponseWriter, chatroomID string, pins []*models.Pin) {
+	h.onChange(chatroomID, pins)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pins)
+}
+
+// writePinError maps errors of the pin service to responses
+func writePinError(w http.ResponseWriter, err error, failure string) {
+	switch err {
+	case services.ErrMessageNotFound, services.ErrNotPinned:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case services.ErrPinLimitReached, services.ErrChatroomArchived:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		writeChatroomError(w, err, failure)
+	}
+}
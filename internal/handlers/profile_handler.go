@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/internal/services"
+	"github.com/dbvitor/chat-go/pkg/auth"
+	"github.com/gorilla/mux"
+)
+
+// ProfileHandler handles HTTP requests to view and edit user profiles
+type ProfileHandler struct {
+	profileService *services.ProfileService
+	onUpdate       func(*models.Profile) // pushes changes to connected clients
+}
+
+// NewProfileHandler creates a new profile handler. onUpdate is called with
+// the new profile after every change.
+func NewProfileHandler(profileService *services.ProfileService, onUpdate func(*models.Profile)) *ProfileHandler {
+	return &ProfileHandler{
+		profileService: profileService,
+		onUpdate:       onUpdate,
+	}
+}
+
+// GetMine handles fetching the profile of the authenticated user
+func (h *ProfileHandler) GetMine(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	user := auth.GetPrincipal(r).User
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user.Profile())
+}
+
+// Get handles fetching the profile of any user
+func (h *ProfileHandler) Get(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from URL
+	vars := mux.Vars(r)
+	userID := vars["id"]
+
+	profile, err := h.profileService.Get(userID)
+	if err != nil {
+		if err == services.ErrUserNotFound {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error fetching profile: %v", err)
+		http.Error(w, "Failed to retrieve profile", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// Update handles changing the display name, status text or time zone of the
+// authenticated user. Fields missing from the request are left as they are.
+func (h *ProfileHandler) Update(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	userID := auth.GetPrincipal(r).UserID()
+
+	// Parse request body
+	var update models.ProfileUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.profileService.Update(userID, &update)
+	if err != nil {
+		switch err {
+		case services.ErrInvalidDisplayName, services.ErrInvalidStatusText, services.ErrInvalidTimeZone:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			log.Printf("Error updating profile: %v", err)
+			http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.respondUpdated(w, user)
+}
+
+// UploadAvatar handles a multipart image upload replacing the avatar of the
+// authenticated user. The image is cropped to a square and resized.
+func (h *ProfileHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	userID := auth.GetPrincipal(r).UserID()
+
+	// Limit the request body, leaving some room for the multipart framing
+	r.Body = http.MaxBytesReader(w, r.Body, h.profileService.MaxAvatarSize()+64*1024)
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		if _, ok := err.(*http.MaxBytesError); ok {
+			http.Error(w, "Avatar is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "A file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	user, err := h.profileService.SetAvatar(userID, file)
+	if err != nil {
+		switch err {
+		case services.ErrAvatarTooLarge:
+			http.Error(w, "Avatar is too large", http.StatusRequestEntityTooLarge)
+		case services.ErrInvalidAvatar:
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		default:
+			log.Printf("Error uploading avatar: %v", err)
+			http.Error(w, "Failed to upload avatar", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.respondUpdated(w, user)
+}
+
+// DeleteAvatar handles removing the avatar of the authenticated user
+func (h *ProfileHandler) DeleteAvatar(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	userID := auth.GetPrincipal(r).UserID()
+
+	user, err := h.profileService.RemoveAvatar(userID)
+	if err != nil {
+		log.Printf("Error removing avatar: %v", err)
+		http.Error(w, "Failed to remove avatar", http.StatusInternalServerError)
+		return
+	}
+
+	h.respondUpdated(w, user)
+}
+
+// Avatar handles streaming the avatar image of a user
+func (h *ProfileHandler) Avatar(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from URL
+	vars := mux.Vars(r)
+	userID := vars["id"]
+
+	content, err := h.profileService.OpenAvatar(userID)
+	if err != nil {
+		if err == services.ErrAvatarNotFound {
+			http.Error(w, "Avatar not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error opening avatar: %v", err)
+		http.Error(w, "Failed to load avatar", http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	// Avatar URLs change with every upload, so each version can be cached for good
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+
+	if _, err := io.Copy(w, content); err != nil {
+		log.Printf("Error streaming avatar: %v", err)
+	}
+}
+
+// respondUpdated announces a changed profile and returns it
+func (h *ProfileHandler) respondUpdated(w http.ResponseWriter, user *models.User) {
+	profile := user.Profile()
+	if h.onUpdate != nil {
+		h.onUpdate(profile)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/internal/services"
+	"github.com/dbvitor/chat-go/pkg/auth"
+	"github.com/gorilla/mux"
+)
+
+// ScheduleHandler handles scheduled message HTTP requests
+type ScheduleHandler struct {
+	scheduleService *services.ScheduleService
+}
+
+// NewScheduleHandler creates a new schedule handler
+func NewScheduleHandler(scheduleService *services.ScheduleService) *ScheduleHandler {
+	return &ScheduleHandler{
+		scheduleService: scheduleService,
+	}
+}
+
+// CreateScheduleRequest represents the request body for scheduling a message
+type CreateScheduleRequest struct {
+	Content string    `json:"content"`
+	SendAt  time.Time `json:"send_at"`        // RFC 3339
+	Kind    string    `json:"kind,omitempty"` // message (default) or reminder
+}
+
+// Create handles scheduling a message to a chatroom
+func (h *ScheduleHandler) Create(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	userID := auth.GetPrincipal(r).UserID()
+
+	var req CreateScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Kind == "" {
+		req.Kind = models.ScheduleKindMessage
+	}
+
+	schedule, err := h.scheduleService.Create(userID, mux.Vars(r)["id"], req.Kind, req.Content, req.SendAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidScheduleKind),
+			errors.Is(err, services.ErrInvalidSendTime),
+			errors.Is(err, services.ErrMessageEmpty),
+			errors.Is(err, services.ErrMessageTooLong),
+			errors.Is(err, services.ErrMessageBlocked):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, services.ErrChatroomNotFound):
+			http.Error(w, "Chatroom not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrChatroomArchived), errors.Is(err, services.ErrScheduleLimitReached):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("Error scheduling message: %v", err)
+			http.Error(w, "Failed to schedule message", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// GetAll handles listing the user's pending scheduled messages
+func (h *ScheduleHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	userID := auth.GetPrincipal(r).UserID()
+
+	schedules, err := h.scheduleService.List(userID)
+	if err != nil {
+		log.Printf("Error listing scheduled messages: %v", err)
+		http.Error(w, "Failed to retrieve scheduled messages", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedules)
+}
+
+// Cancel handles cancelling one of the user's scheduled messages
+func (h *ScheduleHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	userID := auth.GetPrincipal(r).UserID()
+
+	if err := h.scheduleService.Cancel(userID, mux.Vars(r)["id"]); err != nil {
+		if err == services.ErrScheduleNotFound {
+			http.Error(w, "Scheduled message not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error cancelling scheduled message: %v", err)
+		http.Error(w, "Failed to cancel scheduled message", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
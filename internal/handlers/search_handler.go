@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/internal/services"
+	"github.com/dbvitor/chat-go/pkg/auth"
+)
+
+// SearchHandler handles message search HTTP requests
+type SearchHandler struct {
+	searchService *services.SearchService
+}
+
+// NewSearchHandler creates a new search handler
+func NewSearchHandler(searchService *services.SearchService) *SearchHandler {
+	return &SearchHandler{
+		searchService: searchService,
+	}
+}
+
+// SearchResponse represents a page of search results
+type SearchResponse struct {
+	Results    []*models.SearchResult `json:"results"`
+	NextOffset *int                   `json:"next_offset,omitempty"`
+}
+
+// Search handles full-text message search.
+// Query parameters: q (required), room, from (username), before (RFC 3339 or
+// YYYY-MM-DD), limit and offset.
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	userID := auth.GetPrincipal(r).UserID()
+
+	// Parse query parameters
+	var err error
+	params := r.URL.Query()
+	query := models.SearchQuery{
+		Text:       params.Get("q"),
+		ChatroomID: params.Get("room"),
+		Username:   params.Get("from"),
+	}
+
+	if before := params.Get("before"); before != "" {
+		query.Before, err = parseSearchTime(before)
+		if err != nil {
+			http.Error(w, "Invalid before parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if offset := params.Get("offset"); offset != "" {
+		query.Offset, err = strconv.Atoi(offset)
+		if err != nil || query.Offset < 0 {
+			http.Error(w, "Invalid offset parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Search messages
+	results, hasMore, err := h.searchService.Search(userID, query)
+	if err != nil {
+		switch err {
+		case services.ErrEmptySearchQuery:
+			http.Error(w, "Search query is required", http.StatusBadRequest)
+		case services.ErrChatroomNotFound:
+			http.Error(w, "Chatroom not found", http.StatusNotFound)
+		default:
+			log.Printf("Error searching messages: %v", err)
+			http.Error(w, "Failed to search messages", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := SearchResponse{Results: results}
+	if response.Results == nil {
+		response.Results = []*models.SearchResult{}
+	}
+	if hasMore {
+		nextOffset := query.Offset + len(results)
+		response.NextOffset = &nextOffset
+	}
+
+	// Return results
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// parseSearchTime accepts either a full RFC 3339 timestamp or a plain date
+func parseSearchTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
@@ -6,74 +6,269 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/dbvitor/chat-go/internal/config"
+	"github.com/dbvitor/chat-go/internal/models"
 	"github.com/dbvitor/chat-go/internal/services"
 	"github.com/dbvitor/chat-go/pkg/broker"
+	"github.com/dbvitor/chat-go/pkg/notify"
+	"github.com/dbvitor/chat-go/pkg/oidc"
+	"github.com/dbvitor/chat-go/pkg/ratelimit"
+	"github.com/dbvitor/chat-go/pkg/storage"
 	"github.com/gorilla/mux"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-// CORS middleware
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Set CORS headers
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization")
-
-		// Handle preflight requests
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		// Call the next handler
-		next.ServeHTTP(w, r)
-	})
-}
-
 // Server represents the HTTP server
 type Server struct {
-	router          *mux.Router
-	userHandler     *UserHandler
-	chatroomHandler *ChatroomHandler
-	wsHandler       *WebSocketHandler
+	router              *mux.Router
+	userHandler         *UserHandler
+	chatroomHandler     *ChatroomHandler
+	searchHandler       *SearchHandler
+	attachmentHandler   *AttachmentHandler
+	notificationHandler *NotificationHandler
+	sessionHandler      *SessionHandler
+	tokenHandler        *TokenHandler
+	profileHandler      *ProfileHandler
+	adminHandler        *AdminHandler
+	accountHandler      *AccountHandler
+	twoFactorHandler    *TwoFactorHandler
+	ssoHandler          *SSOHandler
+	scheduleHandler     *ScheduleHandler
+	wsHandler           *WebSocketHandler
+	scheduleService     *services.ScheduleService
 }
 
 // NewServer creates a new HTTP server
-func NewServer(db *sql.DB, rabbitMQ *broker.RabbitMQ, stockResults <-chan amqp.Delivery) *Server {
+func NewServer(db *sql.DB, rabbitMQ *broker.RabbitMQ, stockResults, unfurlResults <-chan amqp.Delivery, store storage.Storage, notifier notify.Notifier) *Server {
 	// Create services
-	userService := services.NewUserService(db)
-	chatroomService := services.NewChatroomService(db)
-	messageService := services.NewMessageService(db, rabbitMQ)
+	userService := services.NewUserService(db, &services.PasswordPolicy{
+		MinLength:     config.Int("PASSWORD_MIN_LENGTH", services.DefaultMinPasswordLength),
+		RequireUpper:  config.Bool("PASSWORD_REQUIRE_UPPER", false),
+		RequireLower:  config.Bool("PASSWORD_REQUIRE_LOWER", false),
+		RequireDigit:  config.Bool("PASSWORD_REQUIRE_DIGIT", false),
+		RequireSymbol: config.Bool("PASSWORD_REQUIRE_SYMBOL", false),
+	})
+	passwordResetService := services.NewPasswordResetService(
+		db,
+		userService,
+		notifier,
+		config.Duration("PASSWORD_RESET_TTL", services.DefaultPasswordResetTTL),
+		config.String("APP_BASE_URL", "http://localhost:8080"),
+	)
+	passwordResetService.StartCleanup(time.Hour)
+	auditService := services.NewAuditService(db)
+	adminService := services.NewAdminService(db, auditService)
+	twoFactorService := services.NewTwoFactorService(db, auditService, config.String("TOTP_ISSUER", "Chat Go"))
+	ssoService := newSSOService(db)
+	chatroomService := services.NewChatroomService(
+		db,
+		store,
+		auditService,
+		config.Int("ROOM_CREATE_LIMIT", services.DefaultChatroomCreateLimit),
+	)
+	contentPolicy := services.NewContentPolicy(
+		config.Int("MESSAGE_MAX_LENGTH", services.DefaultMaxMessageLength),
+		services.NewBlocklistFilter(
+			config.List("MESSAGE_BLOCKED_WORDS", nil),
+			config.String("MESSAGE_FILTER_MODE", services.FilterModeMask),
+		),
+	)
+	messageService := services.NewMessageService(db, rabbitMQ, contentPolicy)
+	pinService := services.NewPinService(db, chatroomService, messageService, auditService)
+	scheduleService := services.NewScheduleService(db, chatroomService, messageService, contentPolicy)
+	presenceService := services.NewPresenceService()
+	readReceiptService := services.NewReadReceiptService(db)
+	searchService := services.NewSearchService(db, chatroomService)
+	notificationService := services.NewNotificationService(db)
+	sessionService := services.NewSessionService(db)
+	sessionService.StartCleanup(time.Hour)
+	tokenService := services.NewTokenService(db)
+	attachmentService := services.NewAttachmentService(
+		db,
+		chatroomService,
+		store,
+		int64(config.Int("ATTACHMENT_MAX_SIZE", 10*1024*1024)),
+		config.List("ATTACHMENT_ALLOWED_TYPES", []string{
+			"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain",
+		}),
+		config.Duration("ATTACHMENT_UNSENT_TTL", services.DefaultUnsentAttachmentTTL),
+	)
+	attachmentService.StartCleanup(time.Hour)
+	accountService := services.NewAccountService(db, store, auditService)
+	profileService := services.NewProfileService(db, store, int64(config.Int("AVATAR_MAX_SIZE", 5*1024*1024)))
+	typingService := services.NewTypingService(config.Duration("TYPING_TIMEOUT", 6*time.Second))
+
+	// Create rate limiters
+	trustProxy := config.Bool("TRUST_PROXY_HEADERS", false)
+	apiLimiter := ratelimit.NewKeyedLimiter(
+		config.Float("RATE_LIMIT_API_RPS", 10),
+		config.Int("RATE_LIMIT_API_BURST", 30),
+	)
+	apiLimiter.StartCleanup(time.Minute)
+	authLimiter := ratelimit.NewKeyedLimiter(
+		config.Float("RATE_LIMIT_AUTH_RPS", 0.2),
+		config.Int("RATE_LIMIT_AUTH_BURST", 5),
+	)
+	authLimiter.StartCleanup(time.Minute)
+	loginLockout := ratelimit.NewLockout(
+		config.Int("LOGIN_MAX_FAILURES", 5),
+		config.Duration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		config.Duration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+	)
+	authRateLimit := rateLimitMiddleware(authLimiter, trustProxy)
+	loginGuard := newLoginGuard(loginLockout, trustProxy)
 
 	// Create handlers
-	userHandler := NewUserHandler(userService)
-	chatroomHandler := NewChatroomHandler(chatroomService)
-	wsHandler := NewWebSocketHandler(messageService, userService, chatroomService, stockResults)
+	twoFactorHandler := NewTwoFactorHandler(twoFactorService, loginGuard)
+	localLogin := config.Bool("LOCAL_LOGIN_ENABLED", true)
+	ssoHandler := NewSSOHandler(ssoService, config.String("OIDC_PROVIDER_NAME", "Single sign-on"), localLogin)
+	searchHandler := NewSearchHandler(searchService)
+	attachmentHandler := NewAttachmentHandler(attachmentService)
+	notificationHandler := NewNotificationHandler(notificationService)
+	tokenHandler := NewTokenHandler(tokenService)
+	originPolicy := NewOriginPolicy(config.List("CORS_ALLOWED_ORIGINS", nil))
+	wsHandler := NewWebSocketHandler(
+		originPolicy,
+		messageService,
+		chatroomService,
+		presenceService,
+		typingService,
+		readReceiptService,
+		pinService,
+		scheduleService,
+		stockResults,
+		unfurlResults,
+		config.Float("WS_MESSAGE_RATE", 5),
+		config.Int("WS_MESSAGE_BURST", 10),
+		int64(config.Int("WS_MAX_FRAME_SIZE", 32*1024)),
+	)
+	userHandler := NewUserHandler(userService, passwordResetService, twoFactorService, loginGuard, wsHandler.DisconnectSessions)
+	sessionHandler := NewSessionHandler(sessionService, wsHandler.DisconnectSession, wsHandler.DisconnectSessions)
+	profileHandler := NewProfileHandler(profileService, wsHandler.ProfileUpdated)
+	chatroomHandler := NewChatroomHandler(
+		chatroomService,
+		presenceService,
+		readReceiptService,
+		pinService,
+		wsHandler.ChatroomUpdated,
+		wsHandler.ChatroomDeleted,
+	)
+	pinHandler := NewPinHandler(pinService, wsHandler.PinsUpdated)
+	scheduleHandler := NewScheduleHandler(scheduleService)
+	adminHandler := NewAdminHandler(adminService, auditService, wsHandler.DisconnectUser)
+	accountHandler := NewAccountHandler(accountService, loginGuard, wsHandler.DisconnectUser)
 
 	// Create router
 	router := mux.NewRouter()
 
 	// Apply middleware
-	router.Use(corsMiddleware)
+	router.Use(originPolicy.Middleware)
+	router.Use(csrfMiddleware)
 
 	// Register API routes
 	apiRouter := router.PathPrefix("/api").Subrouter()
+	apiRouter.Use(authMiddleware(userService))
+	apiRouter.Use(rateLimitMiddleware(apiLimiter, trustProxy))
+	apiRouter.Use(tokenScopeMiddleware)
 
-	// User routes
-	apiRouter.HandleFunc("/auth/register", userHandler.Register).Methods("POST", "OPTIONS")
-	apiRouter.HandleFunc("/auth/login", userHandler.Login).Methods("POST", "OPTIONS")
+	// User routes; the password routes are turned off when only single sign-on is allowed
+	register, login := http.HandlerFunc(userHandler.Register), http.HandlerFunc(userHandler.Login)
+	changePassword := http.HandlerFunc(userHandler.ChangePassword)
+	requestReset, resetPassword := http.HandlerFunc(userHandler.RequestPasswordReset), http.HandlerFunc(userHandler.ResetPassword)
+	if !localLogin {
+		register, login, changePassword = localLoginDisabled, localLoginDisabled, localLoginDisabled
+		requestReset, resetPassword = localLoginDisabled, localLoginDisabled
+	}
+	apiRouter.Handle("/auth/register", authRateLimit(register)).Methods("POST", "OPTIONS")
+	apiRouter.Handle("/auth/login", authRateLimit(login)).Methods("POST", "OPTIONS")
 	apiRouter.HandleFunc("/auth/logout", userHandler.Logout).Methods("POST", "OPTIONS")
-	apiRouter.HandleFunc("/auth/check", userHandler.CheckAuth).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/auth/methods", ssoHandler.LoginMethods).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/auth/csrf", GetCSRFToken).Methods("GET", "OPTIONS")
+	apiRouter.Handle("/auth/password", requireSession(changePassword)).Methods("POST", "OPTIONS")
+	apiRouter.Handle("/auth/password/reset-request", authRateLimit(requestReset)).Methods("POST", "OPTIONS")
+	apiRouter.Handle("/auth/password/reset", authRateLimit(resetPassword)).Methods("POST", "OPTIONS")
+
+	// Single sign-on routes
+	if ssoService != nil {
+		apiRouter.Handle("/auth/oidc/login", authRateLimit(http.HandlerFunc(ssoHandler.Login))).Methods("GET")
+		apiRouter.Handle("/auth/oidc/callback", authRateLimit(http.HandlerFunc(ssoHandler.Callback))).Methods("GET")
+	}
+	apiRouter.Handle("/auth/check", requireAuth(http.HandlerFunc(userHandler.CheckAuth))).Methods("GET", "OPTIONS")
+
+	// Two-factor authentication routes; verify completes a login, so it takes
+	// the pending session instead of requiring authentication
+	apiRouter.Handle("/auth/2fa/verify", authRateLimit(http.HandlerFunc(userHandler.VerifyTwoFactor))).Methods("POST", "OPTIONS")
+	apiRouter.Handle("/auth/2fa", requireSession(http.HandlerFunc(twoFactorHandler.Status))).Methods("GET", "OPTIONS")
+	apiRouter.Handle("/auth/2fa/enroll", requireSession(http.HandlerFunc(twoFactorHandler.Enroll))).Methods("POST", "OPTIONS")
+	apiRouter.Handle("/auth/2fa/enable", requireSession(http.HandlerFunc(twoFactorHandler.Enable))).Methods("POST", "OPTIONS")
+	apiRouter.Handle("/auth/2fa/disable", requireSession(http.HandlerFunc(twoFactorHandler.Disable))).Methods("POST", "OPTIONS")
+	apiRouter.Handle("/auth/2fa/recovery-codes", requireSession(http.HandlerFunc(twoFactorHandler.RegenerateRecoveryCodes))).Methods("POST", "OPTIONS")
+
+	// Admin routes
+	manageUsers := requirePermission(models.PermissionManageUsers)
+	apiRouter.Handle("/admin/users", manageUsers(http.HandlerFunc(adminHandler.ListUsers))).Methods("GET", "OPTIONS")
+	apiRouter.Handle("/admin/users/{id}/role", manageUsers(http.HandlerFunc(adminHandler.SetRole))).Methods("PUT", "OPTIONS")
+	apiRouter.Handle("/admin/users/{id}/disable", manageUsers(http.HandlerFunc(adminHandler.Disable))).Methods("POST", "OPTIONS")
+	apiRouter.Handle("/admin/users/{id}/enable", manageUsers(http.HandlerFunc(adminHandler.Enable))).Methods("POST", "OPTIONS")
+	apiRouter.Handle("/admin/users/{id}/2fa", manageUsers(http.HandlerFunc(twoFactorHandler.ForceDisable))).Methods("DELETE", "OPTIONS")
+	apiRouter.Handle("/admin/audit-log", requirePermission(models.PermissionViewAuditLog)(http.HandlerFunc(adminHandler.AuditLog))).Methods("GET", "OPTIONS")
+
+	// Session routes
+	apiRouter.Handle("/sessions", requireSession(http.HandlerFunc(sessionHandler.GetAll))).Methods("GET", "OPTIONS")
+	apiRouter.Handle("/sessions/revoke-others", requireSession(http.HandlerFunc(sessionHandler.RevokeOthers))).Methods("POST", "OPTIONS")
+	apiRouter.Handle("/sessions/{id}", requireSession(http.HandlerFunc(sessionHandler.Revoke))).Methods("DELETE", "OPTIONS")
+
+	// Access token routes
+	apiRouter.Handle("/tokens", requireSession(http.HandlerFunc(tokenHandler.GetAll))).Methods("GET", "OPTIONS")
+	apiRouter.Handle("/tokens", requireSession(http.HandlerFunc(tokenHandler.Create))).Methods("POST", "OPTIONS")
+	apiRouter.Handle("/tokens/{id}", requireSession(http.HandlerFunc(tokenHandler.Revoke))).Methods("DELETE", "OPTIONS")
+
+	// Profile routes
+	apiRouter.Handle("/profile", requireAuth(http.HandlerFunc(profileHandler.GetMine))).Methods("GET", "OPTIONS")
+	apiRouter.Handle("/profile", requireAuth(http.HandlerFunc(profileHandler.Update))).Methods("PATCH", "OPTIONS")
+	apiRouter.Handle("/profile/avatar", requireAuth(http.HandlerFunc(profileHandler.UploadAvatar))).Methods("PUT", "OPTIONS")
+	apiRouter.Handle("/profile/avatar", requireAuth(http.HandlerFunc(profileHandler.DeleteAvatar))).Methods("DELETE", "OPTIONS")
+	apiRouter.Handle("/users/{id}/profile", requireAuth(http.HandlerFunc(profileHandler.Get))).Methods("GET", "OPTIONS")
+	apiRouter.Handle("/users/{id}/avatar", requireAuth(http.HandlerFunc(profileHandler.Avatar))).Methods("GET", "OPTIONS")
+
+	// Account routes
+	apiRouter.Handle("/account/export", requireSession(http.HandlerFunc(accountHandler.Export))).Methods("GET", "OPTIONS")
+	apiRouter.Handle("/account", requireSession(http.HandlerFunc(accountHandler.Delete))).Methods("DELETE", "OPTIONS")
 
 	// Chatroom routes
-	apiRouter.HandleFunc("/chatrooms", chatroomHandler.GetAll).Methods("GET", "OPTIONS")
-	apiRouter.HandleFunc("/chatrooms", chatroomHandler.Create).Methods("POST", "OPTIONS")
-	apiRouter.HandleFunc("/chatrooms/{id}", chatroomHandler.GetByID).Methods("GET", "OPTIONS")
+	apiRouter.Handle("/chatrooms", requireAuth(http.HandlerFunc(chatroomHandler.GetAll))).Methods("GET", "OPTIONS")
+	apiRouter.Handle("/chatrooms", requirePermission(models.PermissionCreateRooms)(http.HandlerFunc(chatroomHandler.Create))).Methods("POST", "OPTIONS")
+	apiRouter.Handle("/chatrooms/{id}", requireAuth(http.HandlerFunc(chatroomHandler.GetByID))).Methods("GET", "OPTIONS")
+	apiRouter.Handle("/chatrooms/{id}", requireAuth(http.HandlerFunc(chatroomHandler.Update))).Methods("PATCH", "OPTIONS")
+	apiRouter.Handle("/chatrooms/{id}", requireAuth(http.HandlerFunc(chatroomHandler.Delete))).Methods("DELETE", "OPTIONS")
+	apiRouter.Handle("/chatrooms/{id}/archive", requireAuth(http.HandlerFunc(chatroomHandler.Archive))).Methods("POST", "OPTIONS")
+	apiRouter.Handle("/chatrooms/{id}/unarchive", requireAuth(http.HandlerFunc(chatroomHandler.Unarchive))).Methods("POST", "OPTIONS")
+	apiRouter.Handle("/chatrooms/{id}/presence", requireAuth(http.HandlerFunc(chatroomHandler.GetPresence))).Methods("GET", "OPTIONS")
+	apiRouter.Handle("/chatrooms/{id}/pins", requireAuth(http.HandlerFunc(pinHandler.List))).Methods("GET", "OPTIONS")
+	apiRouter.Handle("/chatrooms/{id}/pins/{messageId}", requireAuth(http.HandlerFunc(pinHandler.Pin))).Methods("PUT", "OPTIONS")
+	apiRouter.Handle("/chatrooms/{id}/pins/{messageId}", requireAuth(http.HandlerFunc(pinHandler.Unpin))).Methods("DELETE", "OPTIONS")
+	apiRouter.Handle("/chatrooms/{id}/schedules", requireAuth(http.HandlerFunc(scheduleHandler.Create))).Methods("POST", "OPTIONS")
+
+	// Scheduled message routes
+	apiRouter.Handle("/schedules", requireAuth(http.HandlerFunc(scheduleHandler.GetAll))).Methods("GET", "OPTIONS")
+	apiRouter.Handle("/schedules/{id}", requireAuth(http.HandlerFunc(scheduleHandler.Cancel))).Methods("DELETE", "OPTIONS")
+
+	// Attachment routes
+	apiRouter.Handle("/chatrooms/{id}/attachments", requireAuth(http.HandlerFunc(attachmentHandler.Upload))).Methods("POST", "OPTIONS")
+	apiRouter.Handle("/attachments/{id}", requireAuth(http.HandlerFunc(attachmentHandler.Download))).Methods("GET", "OPTIONS")
+
+	// Notification routes
+	apiRouter.Handle("/notifications", requireAuth(http.HandlerFunc(notificationHandler.GetAll))).Methods("GET", "OPTIONS")
+	apiRouter.Handle("/notifications/read", requireAuth(http.HandlerFunc(notificationHandler.MarkAllRead))).Methods("POST", "OPTIONS")
+	apiRouter.Handle("/notifications/{id}/read", requireAuth(http.HandlerFunc(notificationHandler.MarkRead))).Methods("POST", "OPTIONS")
+
+	// Search routes
+	apiRouter.Handle("/search", requireAuth(http.HandlerFunc(searchHandler.Search))).Methods("GET", "OPTIONS")
 
 	// WebSocket route
-	apiRouter.HandleFunc("/ws/{id}", wsHandler.Handle)
+	apiRouter.Handle("/ws/{id}", requireAuth(http.HandlerFunc(wsHandler.Handle)))
 
 	// Static files
 	fs := http.FileServer(http.Dir("./web/static"))
@@ -85,11 +280,58 @@ func NewServer(db *sql.DB, rabbitMQ *broker.RabbitMQ, stockResults <-chan amqp.D
 	})
 
 	return &Server{
-		router:          router,
-		userHandler:     userHandler,
-		chatroomHandler: chatroomHandler,
-		wsHandler:       wsHandler,
+		router:              router,
+		userHandler:         userHandler,
+		chatroomHandler:     chatroomHandler,
+		searchHandler:       searchHandler,
+		attachmentHandler:   attachmentHandler,
+		notificationHandler: notificationHandler,
+		sessionHandler:      sessionHandler,
+		tokenHandler:        tokenHandler,
+		profileHandler:      profileHandler,
+		adminHandler:        adminHandler,
+		accountHandler:      accountHandler,
+		twoFactorHandler:    twoFactorHandler,
+		ssoHandler:          ssoHandler,
+		scheduleHandler:     scheduleHandler,
+		wsHandler:           wsHandler,
+		scheduleService:     scheduleService,
+	}
+}
+
+// DeliverScheduledMessages posts the scheduled messages due at now and sends
+// them and due reminders to connected clients, returning how many were delivered
+func (s *Server) DeliverScheduledMessages(now time.Time) (int, error) {
+	return s.scheduleService.DeliverDue(now, s.wsHandler.DeliverScheduled, s.wsHandler.Remind)
+}
+
+// newSSOService creates the single sign-on service from the OIDC_* settings,
+// or returns nil when no identity provider is configured
+func newSSOService(db *sql.DB) *services.SSOService {
+	issuer := config.String("OIDC_ISSUER_URL", "")
+	if issuer == "" {
+		return nil
+	}
+
+	provider, err := oidc.NewProvider(oidc.Config{
+		IssuerURL:    issuer,
+		ClientID:     config.String("OIDC_CLIENT_ID", ""),
+		ClientSecret: config.String("OIDC_CLIENT_SECRET", ""),
+		RedirectURL:  config.String("OIDC_REDIRECT_URL", config.String("APP_BASE_URL", "http://localhost:8080")+"/api/auth/oidc/callback"),
+		Scopes:       config.List("OIDC_SCOPES", []string{"profile", "email"}),
+		GroupsClaim:  config.String("OIDC_GROUPS_CLAIM", "groups"),
+	})
+	if err != nil {
+		log.Fatalf("Invalid single sign-on configuration: %v", err)
 	}
+
+	groupRoles, err := services.ParseRoleMapping(config.List("OIDC_ROLE_MAPPING", nil))
+	if err != nil {
+		log.Fatalf("Invalid single sign-on configuration: %v", err)
+	}
+
+	log.Printf("Single sign-on enabled with %s", issuer)
+	return services.NewSSOService(db, provider, groupRoles)
 }
 
 // Start starts the HTTP server
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/internal/services"
+	"github.com/dbvitor/chat-go/pkg/auth"
+	"github.com/gorilla/mux"
+)
+
+// SessionHandler handles HTTP requests to manage the user's login sessions
+type SessionHandler struct {
+	sessionService     *services.SessionService
+	disconnectSession  func(sessionID string)             // closes the WebSockets of a revoked session
+	disconnectSessions func(userID, keepSessionID string) // closes the WebSockets of every other session
+}
+
+// NewSessionHandler creates a new session handler
+func NewSessionHandler(sessionService *services.SessionService, disconnectSession func(sessionID string), disconnectSessions func(userID, keepSessionID string)) *SessionHandler {
+	return &SessionHandler{
+		sessionService:     sessionService,
+		disconnectSession:  disconnectSession,
+		disconnectSessions: disconnectSessions,
+	}
+}
+
+// RevokeSessionsResponse reports how many sessions were revoked
+type RevokeSessionsResponse struct {
+	Revoked int64 `json:"revoked"`
+}
+
+// GetAll handles listing the user's active sessions
+func (h *SessionHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	// Get current session, required by the route
+	current := auth.GetPrincipal(r).Session
+
+	sessions, err := h.sessionService.GetByUserID(current.UserID, current.ID)
+	if err != nil {
+		log.Printf("Error fetching sessions: %v", err)
+		http.Error(w, "Failed to retrieve sessions", http.StatusInternalServerError)
+		return
+	}
+	if sessions == nil {
+		sessions = []*models.Session{}
+	}
+
+	// Return sessions
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// Revoke handles ending one of the user's sessions, e.g. on a lost device
+func (h *SessionHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	// Get current session, required by the route
+	current := auth.GetPrincipal(r).Session
+
+	// Get session ID from URL
+	vars := mux.Vars(r)
+	sessionID := vars["id"]
+
+	// Revoking the current session is a logout
+	if sessionID == current.ID {
+		if err := auth.Logout(w, r); err != nil {
+			log.Printf("Error logging out: %v", err)
+			http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+			return
+		}
+		h.disconnectSession(current.ID)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	err := h.sessionService.Revoke(current.UserID, sessionID)
+	if err != nil {
+		if err == services.ErrSessionNotFound {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error revoking session: %v", err)
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+	h.disconnectSession(sessionID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeOthers handles logging out every device except the current one
+func (h *SessionHandler) RevokeOthers(w http.ResponseWriter, r *http.Request) {
+	// Get current session, required by the route
+	current := auth.GetPrincipal(r).Session
+
+	revoked, err := h.sessionService.RevokeOthers(current.UserID, current.ID)
+	if err != nil {
+		log.Printf("Error revoking sessions: %v", err)
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	h.disconnectSessions(current.UserID, current.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RevokeSessionsResponse{Revoked: revoked})
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/dbvitor/chat-go/internal/services"
+	"github.com/dbvitor/chat-go/pkg/auth"
+)
+
+// Cookie holding the state of a single sign-on login until the identity
+// provider redirects back
+const (
+	ssoFlowCookie = "chat-sso"
+	ssoFlowMaxAge = 10 * 60 // seconds
+)
+
+// SSOHandler handles logging in through an OpenID Connect identity provider
+type SSOHandler struct {
+	ssoService   *services.SSOService // nil when single sign-on isn't configured
+	providerName string
+	localLogin   bool
+}
+
+// NewSSOHandler creates a new single sign-on handler
+func NewSSOHandler(ssoService *services.SSOService, providerName string, localLogin bool) *SSOHandler {
+	return &SSOHandler{
+		ssoService:   ssoService,
+		providerName: providerName,
+		localLogin:   localLogin,
+	}
+}
+
+// LoginMethodsResponse tells the login page which ways of logging in are available
+type LoginMethodsResponse struct {
+	Password bool   `json:"password"`
+	SSO      bool   `json:"sso"`
+	SSOName  string `json:"sso_name,omitempty"`
+}
+
+// LoginMethods handles listing the available ways of logging in
+func (h *SSOHandler) LoginMethods(w http.ResponseWriter, r *http.Request) {
+	response := LoginMethodsResponse{Password: h.localLogin}
+	if h.ssoService != nil {
+		response.SSO = true
+		response.SSOName = h.providerName
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Login handles starting a login by redirecting to the identity provider
+func (h *SSOHandler) Login(w http.ResponseWriter, r *http.Request) {
+	flow, err := h.ssoService.Start(r.Context())
+	if err != nil {
+		log.Printf("Error starting single sign-on: %v", err)
+		redirectWithError(w, r, "The identity provider is unavailable")
+		return
+	}
+
+	// Keep the flow in a short-lived signed cookie; SameSite=Lax still sends
+	// it on the top-level redirect back from the provider
+	session, _ := auth.Store.Get(r, ssoFlowCookie)
+	session.Options.MaxAge = ssoFlowMaxAge
+	session.Options.Path = "/api/auth/oidc"
+	session.Values["state"] = flow.State
+	session.Values["nonce"] = flow.Nonce
+	session.Values["verifier"] = flow.Verifier
+	if err := auth.SaveSession(w, r, session); err != nil {
+		log.Printf("Error saving single sign-on state: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, flow.URL, http.StatusFound)
+}
+
+// Callback handles the identity provider redirecting back after the user
+// logged in, creating a session for them
+func (h *SSOHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	session, _ := auth.Store.Get(r, ssoFlowCookie)
+	state, _ := session.Values["state"].(string)
+	nonce, _ := session.Values["nonce"].(string)
+	verifier, _ := session.Values["verifier"].(string)
+
+	// The flow can only be completed once
+	session.Options.MaxAge = -1
+	session.Options.Path = "/api/auth/oidc"
+	auth.SaveSession(w, r, session)
+
+	query := r.URL.Query()
+	if providerError := query.Get("error"); providerError != "" {
+		log.Printf("Identity provider returned error: %s: %s", providerError, query.Get("error_description"))
+		redirectWithError(w, r, "Login was cancelled or refused by the identity provider")
+		return
+	}
+
+	// A callback that doesn't match the login started in this browser may
+	// be an attempt to log the user into someone else's account
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(query.Get("state"))) != 1 {
+		redirectWithError(w, r, "Login expired, please try again")
+		return
+	}
+
+	flow := &services.SSOFlow{State: state, Nonce: nonce, Verifier: verifier}
+	user, err := h.ssoService.Finish(r.Context(), flow, query.Get("code"))
+	if err == auth.ErrAccountDisabled {
+		redirectWithError(w, r, "This account has been disabled")
+		return
+	}
+	if err != nil {
+		log.Printf("Error completing single sign-on: %v", err)
+		redirectWithError(w, r, "Login failed")
+		return
+	}
+
+	// Two-factor authentication is left to the identity provider
+	if err := auth.Authenticate(w, r, user); err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("User %s logged in with single sign-on", user.Username)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// redirectWithError sends the browser back to the login page with an error to show
+func redirectWithError(w http.ResponseWriter, r *http.Request, message string) {
+	http.Redirect(w, r, "/?sso_error="+url.QueryEscape(message), http.StatusFound)
+}
+
+// localLoginDisabled replaces the password routes when only single sign-on is allowed
+func localLoginDisabled(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "Password login is disabled, use single sign-on", http.StatusForbidden)
+}
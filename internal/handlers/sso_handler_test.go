@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dbvitor/chat-go/internal/services"
+	"github.com/dbvitor/chat-go/pkg/auth"
+	"github.com/dbvitor/chat-go/pkg/oidc"
+	"github.com/dbvitor/chat-go/pkg/oidc/oidctest"
+)
+
+func newTestSSOHandler(t *testing.T) (*SSOHandler, *oidctest.Provider) {
+	t.Helper()
+	auth.Initialize(nil, nil)
+
+	fake := oidctest.NewProvider(t)
+	provider, err := oidc.NewProvider(oidc.Config{
+		IssuerURL:    fake.Issuer(),
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  "http://chat.test/api/auth/oidc/callback",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewSSOHandler(services.NewSSOService(nil, provider, nil), "Test IdP", true), fake
+}
+
+// startLogin runs the login handler and returns the provider URL and the flow cookies
+func startLogin(t *testing.T, handler *SSOHandler) (string, []*http.Cookie) {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	handler.Login(recorder, httptest.NewRequest("GET", "/api/auth/oidc/login", nil))
+	if recorder.Code != http.StatusFound {
+		t.Fatalf("Expected redirect to the provider, got: %d", recorder.Code)
+	}
+	return recorder.Header().Get("Location"), recorder.Result().Cookies()
+}
+
+// callback runs the callback handler and returns the error shown on the login page
+func callback(handler *SSOHandler, callbackURL *url.URL, cookies []*http.Cookie) string {
+	request := httptest.NewRequest("GET", callbackURL.RequestURI(), nil)
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.Callback(recorder, request)
+
+	location, _ := url.Parse(recorder.Header().Get("Location"))
+	return location.Query().Get("sso_error")
+}
+
+func TestSSOLogin_RedirectsToProvider(t *testing.T) {
+	handler, fake := newTestSSOHandler(t)
+
+	location, cookies := startLogin(t, handler)
+	if !strings.HasPrefix(location, fake.Issuer()+"/authorize?") {
+		t.Errorf("Expected redirect to the authorization endpoint, got: %s", location)
+	}
+	if len(cookies) != 1 || cookies[0].Name != ssoFlowCookie || !cookies[0].HttpOnly {
+		t.Errorf("Expected an HttpOnly flow cookie, got: %v", cookies)
+	}
+}
+
+func TestSSOCallback_Rejected(t *testing.T) {
+	handler, fake := newTestSSOHandler(t)
+
+	// A callback for a login started in another browser
+	location, _ := startLogin(t, handler)
+	forged := fake.Authorize(t, location, map[string]interface{}{"sub": "attacker"})
+	_, cookies := startLogin(t, handler)
+	if message := callback(handler, forged, cookies); message == "" {
+		t.Error("Expected callback with another login's state to be rejected")
+	}
+
+	// A callback without the flow cookie
+	if message := callback(handler, forged, nil); message == "" {
+		t.Error("Expected callback without flow cookie to be rejected")
+	}
+
+	// The user refused the login at the provider
+	location, cookies = startLogin(t, handler)
+	state := mustParse(t, location).Query().Get("state")
+	refused := mustParse(t, "http://chat.test/api/auth/oidc/callback?error=access_denied&state="+state)
+	if message := callback(handler, refused, cookies); !strings.Contains(message, "refused") {
+		t.Errorf("Expected refusal message, got: %q", message)
+	}
+}
+
+func TestLoginMethods(t *testing.T) {
+	handler, _ := newTestSSOHandler(t)
+
+	recorder := httptest.NewRecorder()
+	handler.LoginMethods(recorder, httptest.NewRequest("GET", "/api/auth/methods", nil))
+	if body := recorder.Body.String(); !strings.Contains(body, `"sso":true`) || !strings.Contains(body, `"sso_name":"Test IdP"`) {
+		t.Errorf("Expected single sign-on to be listed, got: %s", body)
+	}
+
+	recorder = httptest.NewRecorder()
+	NewSSOHandler(nil, "", true).LoginMethods(recorder, httptest.NewRequest("GET", "/api/auth/methods", nil))
+	if body := recorder.Body.String(); !strings.Contains(body, `"sso":false`) {
+		t.Errorf("Expected single sign-on to be unavailable, got: %s", body)
+	}
+}
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
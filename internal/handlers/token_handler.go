@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/internal/services"
+	"github.com/dbvitor/chat-go/pkg/auth"
+	"github.com/gorilla/mux"
+)
+
+// TokenHandler handles personal access token HTTP requests. Tokens can only
+// be managed from a browser session, so a leaked token can't mint new ones.
+type TokenHandler struct {
+	tokenService *services.TokenService
+}
+
+// NewTokenHandler creates a new token handler
+func NewTokenHandler(tokenService *services.TokenService) *TokenHandler {
+	return &TokenHandler{
+		tokenService: tokenService,
+	}
+}
+
+// CreateTokenRequest represents the request body for creating a token
+type CreateTokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"` // 0 for a token that never expires
+}
+
+// Create handles issuing a new token. The plaintext token is only returned here.
+func (h *TokenHandler) Create(w http.ResponseWriter, r *http.Request) {
+	// Get current session, required by the route
+	session := auth.GetPrincipal(r).Session
+
+	// Parse request body
+	var req CreateTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	lifetime := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	token, err := h.tokenService.Create(session.UserID, req.Name, req.Scopes, lifetime)
+	if err != nil {
+		switch err {
+		case services.ErrInvalidTokenName, services.ErrInvalidScopes, services.ErrInvalidExpiration:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case services.ErrTooManyTokens:
+			http.Error(w, "Too many active tokens, revoke one first", http.StatusConflict)
+		default:
+			log.Printf("Error creating token: %v", err)
+			http.Error(w, "Failed to create token", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Return token
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(token)
+}
+
+// GetAll handles listing the user's tokens, without their values
+func (h *TokenHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	// Get current session, required by the route
+	session := auth.GetPrincipal(r).Session
+
+	tokens, err := h.tokenService.GetByUserID(session.UserID)
+	if err != nil {
+		log.Printf("Error fetching tokens: %v", err)
+		http.Error(w, "Failed to retrieve tokens", http.StatusInternalServerError)
+		return
+	}
+	if tokens == nil {
+		tokens = []*models.AccessToken{}
+	}
+
+	// Return tokens
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// Revoke handles disabling one of the user's tokens
+func (h *TokenHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	// Get current session, required by the route
+	session := auth.GetPrincipal(r).Session
+
+	// Get token ID from URL
+	vars := mux.Vars(r)
+	tokenID := vars["id"]
+
+	err := h.tokenService.Revoke(session.UserID, tokenID)
+	if err != nil {
+		if err == services.ErrTokenNotFound {
+			http.Error(w, "Token not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error revoking token: %v", err)
+		http.Error(w, "Failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/dbvitor/chat-go/internal/services"
+	"github.com/dbvitor/chat-go/pkg/auth"
+	"github.com/gorilla/mux"
+)
+
+// TwoFactorHandler handles HTTP requests to set up two-factor authentication
+type TwoFactorHandler struct {
+	twoFactorService *services.TwoFactorService
+	loginGuard       *loginGuard
+}
+
+// NewTwoFactorHandler creates a new two-factor handler. Wrong codes count
+// towards loginGuard, like wrong codes at login.
+func NewTwoFactorHandler(twoFactorService *services.TwoFactorService, loginGuard *loginGuard) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: twoFactorService,
+		loginGuard:       loginGuard,
+	}
+}
+
+// TwoFactorCodeRequest represents a request body carrying a two-factor code
+// or a recovery code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// RecoveryCodesResponse lists newly issued recovery codes
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// Status handles reporting whether two-factor authentication is enabled
+func (h *TwoFactorHandler) Status(w http.ResponseWriter, r *http.Request) {
+	status, err := h.twoFactorService.Status(auth.GetPrincipal(r).UserID())
+	if err != nil {
+		log.Printf("Error fetching two-factor status: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// Enroll handles generating a secret for the user's authenticator app
+func (h *TwoFactorHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	enrollment, err := h.twoFactorService.Enroll(auth.GetPrincipal(r).User)
+	if err != nil {
+		if err == services.ErrTwoFactorAlreadyEnabled {
+			http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+			return
+		}
+		log.Printf("Error enrolling two-factor authentication: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+// Enable handles confirming enrollment with a first code. The response holds
+// the recovery codes, which are shown only once.
+func (h *TwoFactorHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	code, ok := decodeTwoFactorCode(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.twoFactorService.Enable(auth.GetPrincipal(r).UserID(), code)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	log.Printf("User %s enabled two-factor authentication", auth.GetPrincipal(r).User.Username)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable handles turning off two-factor authentication, which takes a current code
+func (h *TwoFactorHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	code, ok := decodeTwoFactorCode(w, r)
+	if !ok {
+		return
+	}
+
+	lockoutKey := h.loginGuard.key(r, auth.GetPrincipal(r).User.Username)
+	if h.loginGuard.refuse(w, lockoutKey) {
+		return
+	}
+
+	if err := h.twoFactorService.Disable(auth.GetPrincipal(r).UserID(), code); err != nil {
+		h.recordFailure(lockoutKey, auth.GetPrincipal(r).User.Username, err)
+		writeTwoFactorError(w, err)
+		return
+	}
+	h.loginGuard.reset(lockoutKey)
+
+	log.Printf("User %s disabled two-factor authentication", auth.GetPrincipal(r).User.Username)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes handles replacing the user's recovery codes
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	code, ok := decodeTwoFactorCode(w, r)
+	if !ok {
+		return
+	}
+
+	lockoutKey := h.loginGuard.key(r, auth.GetPrincipal(r).User.Username)
+	if h.loginGuard.refuse(w, lockoutKey) {
+		return
+	}
+
+	codes, err := h.twoFactorService.RegenerateRecoveryCodes(auth.GetPrincipal(r).UserID(), code)
+	if err != nil {
+		h.recordFailure(lockoutKey, auth.GetPrincipal(r).User.Username, err)
+		writeTwoFactorError(w, err)
+		return
+	}
+	h.loginGuard.reset(lockoutKey)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// ForceDisable handles an administrator turning off two-factor authentication
+// for a user who lost both their authenticator and their recovery codes
+func (h *TwoFactorHandler) ForceDisable(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated administrator, resolved by the auth middleware
+	admin := auth.GetPrincipal(r).User
+	userID := mux.Vars(r)["id"]
+
+	if err := h.twoFactorService.ForceDisable(admin, userID); err != nil {
+		switch err {
+		case services.ErrTwoFactorNotEnabled:
+			http.Error(w, "Two-factor authentication is not enabled for this user", http.StatusNotFound)
+			return
+		case services.ErrPermissionDenied:
+			http.Error(w, "Insufficient permissions", http.StatusForbidden)
+			return
+		}
+		log.Printf("Error disabling two-factor authentication: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Administrator %s disabled two-factor authentication of user %s", admin.Username, userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// recordFailure counts a wrong code against the user's lockout
+func (h *TwoFactorHandler) recordFailure(lockoutKey, username string, err error) {
+	if err == services.ErrInvalidTwoFactorCode {
+		h.loginGuard.fail(lockoutKey, username)
+	}
+}
+
+// decodeTwoFactorCode reads the code of a TwoFactorCodeRequest, writing an
+// error response if it's missing
+func decodeTwoFactorCode(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return "", false
+	}
+
+	if req.Code == "" {
+		http.Error(w, "Code is required", http.StatusBadRequest)
+		return "", false
+	}
+
+	return req.Code, true
+}
+
+// writeTwoFactorError writes the response for an error of the two-factor service
+func writeTwoFactorError(w http.ResponseWriter, err error) {
+	switch err {
+	case services.ErrInvalidTwoFactorCode:
+		http.Error(w, "Invalid code", http.StatusBadRequest)
+	case services.ErrTwoFactorNotEnrolled:
+		http.Error(w, "Start two-factor enrollment first", http.StatusBadRequest)
+	case services.ErrTwoFactorAlreadyEnabled:
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+	case services.ErrTwoFactorNotEnabled:
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusBadRequest)
+	default:
+		log.Printf("Two-factor error: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/dbvitor/chat-go/internal/services"
 	"github.com/dbvitor/chat-go/pkg/auth"
@@ -11,13 +13,25 @@ import (
 
 // UserHandler handles user-related HTTP requests
 type UserHandler struct {
-	userService *services.UserService
+	userService      *services.UserService
+	resetService     *services.PasswordResetService
+	twoFactorService *services.TwoFactorService
+	loginGuard       *loginGuard
+
+	// disconnectSessions closes the WebSockets of a user's login sessions
+	// except keepSessionID, once they are revoked
+	disconnectSessions func(userID, keepSessionID string)
 }
 
-// NewUserHandler creates a new user handler
-func NewUserHandler(userService *services.UserService) *UserHandler {
+// NewUserHandler creates a new user handler. Wrong passwords and codes count
+// towards loginGuard.
+func NewUserHandler(userService *services.UserService, resetService *services.PasswordResetService, twoFactorService *services.TwoFactorService, loginGuard *loginGuard, disconnectSessions func(userID, keepSessionID string)) *UserHandler {
 	return &UserHandler{
-		userService: userService,
+		userService:        userService,
+		resetService:       resetService,
+		twoFactorService:   twoFactorService,
+		loginGuard:         loginGuard,
+		disconnectSessions: disconnectSessions,
 	}
 }
 
@@ -33,6 +47,29 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// LoginResponse tells the client that the login needs a second factor,
+// sent to /api/auth/2fa/verify
+type LoginResponse struct {
+	TwoFactorRequired bool `json:"two_factor_required"`
+}
+
+// ChangePasswordRequest represents the request body for changing a password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// PasswordResetRequest represents the request body for requesting a reset link
+type PasswordResetRequest struct {
+	Username string `json:"username"`
+}
+
+// ResetPasswordRequest represents the request body for redeeming a reset link
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
 // Register handles user registration
 func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	// Parse request body
@@ -54,9 +91,11 @@ func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	user, err := h.userService.Register(req.Username, req.Password)
 	if err != nil {
 		log.Printf("Failed to register user: %v", err)
-		switch err {
-		case auth.ErrUserAlreadyExists:
+		switch {
+		case errors.Is(err, auth.ErrUserAlreadyExists):
 			http.Error(w, "User already exists", http.StatusConflict)
+		case errors.Is(err, services.ErrWeakPassword):
+			http.Error(w, passwordErrorMessage(err), http.StatusBadRequest)
 		default:
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
@@ -92,18 +131,47 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuse attempts while the account is locked out
+	lockoutKey := h.loginGuard.key(r, req.Username)
+	if h.loginGuard.refuse(w, lockoutKey) {
+		return
+	}
+
 	// Login user
 	user, err := h.userService.Login(req.Username, req.Password)
 	if err != nil {
 		switch err {
 		case auth.ErrInvalidCredentials:
+			h.loginGuard.fail(lockoutKey, req.Username)
 			http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+		case auth.ErrAccountDisabled:
+			http.Error(w, "This account has been disabled", http.StatusForbidden)
 		default:
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
 		return
 	}
 
+	// With two-factor authentication, the password only starts a pending
+	// session. Failed attempts keep counting until the code is checked too.
+	twoFactor, err := h.twoFactorService.IsEnabled(user.ID)
+	if err != nil {
+		log.Printf("Error checking two-factor authentication: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if twoFactor {
+		if err := auth.StartPending(w, r, user); err != nil {
+			http.Error(w, "Failed to create session", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(LoginResponse{TwoFactorRequired: true})
+		return
+	}
+	h.loginGuard.reset(lockoutKey)
+
 	// Create session
 	err = auth.Authenticate(w, r, user)
 	if err != nil {
@@ -115,6 +183,60 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// VerifyTwoFactor completes a login waiting for its second factor, replacing
+// the pending session with a full one
+func (h *UserHandler) VerifyTwoFactor(w http.ResponseWriter, r *http.Request) {
+	pending, err := auth.GetPendingSession(r)
+	if err != nil {
+		writeJSONError(w, http.StatusUnauthorized, "No login is waiting for a two-factor code")
+		return
+	}
+
+	code, ok := decodeTwoFactorCode(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.userService.GetByID(pending.UserID)
+	if err != nil {
+		log.Printf("Error loading user of pending session: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// The account may have been disabled after the password was checked
+	if user.Disabled() {
+		http.Error(w, "This account has been disabled", http.StatusForbidden)
+		return
+	}
+
+	// Wrong codes count towards the same lockout as wrong passwords
+	lockoutKey := h.loginGuard.key(r, user.Username)
+	if h.loginGuard.refuse(w, lockoutKey) {
+		return
+	}
+
+	if err := h.twoFactorService.Verify(user.ID, code); err != nil {
+		switch err {
+		case services.ErrInvalidTwoFactorCode:
+			h.loginGuard.fail(lockoutKey, user.Username)
+			http.Error(w, "Invalid code", http.StatusUnauthorized)
+		default:
+			writeTwoFactorError(w, err)
+		}
+		return
+	}
+	h.loginGuard.reset(lockoutKey)
+
+	// Create session
+	if err := auth.Authenticate(w, r, user); err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
 // Logout handles user logout
 func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	// Logout user
@@ -127,32 +249,121 @@ func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-// CheckAuth checks if the user is authenticated
-func (h *UserHandler) CheckAuth(w http.ResponseWriter, r *http.Request) {
-	// Check if authenticated
-	if !auth.IsAuthenticated(r) {
-		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+// ChangePassword replaces the password of the authenticated user. Other
+// sessions are logged out; the current one stays logged in.
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	principal := auth.GetPrincipal(r)
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Get user ID
-	userID, err := auth.GetAuthenticatedUser(r)
-	if err != nil {
-		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		http.Error(w, "Current and new password are required", http.StatusBadRequest)
+		return
+	}
+
+	// Wrong current passwords count towards the same lockout as at login
+	lockoutKey := h.loginGuard.key(r, principal.User.Username)
+	if h.loginGuard.refuse(w, lockoutKey) {
 		return
 	}
 
-	// Get user
-	user, err := h.userService.GetByID(userID)
+	err := h.userService.ChangePassword(principal.UserID(), req.CurrentPassword, req.NewPassword, principal.Session.ID)
 	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWrongPassword):
+			h.loginGuard.fail(lockoutKey, principal.User.Username)
+			http.Error(w, "Current password is incorrect", http.StatusForbidden)
+		case errors.Is(err, services.ErrWeakPassword):
+			http.Error(w, passwordErrorMessage(err), http.StatusBadRequest)
+		default:
+			log.Printf("Error changing password: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	h.loginGuard.reset(lockoutKey)
+
+	h.disconnectSessions(principal.UserID(), principal.Session.ID)
+
+	log.Printf("User %s changed their password", principal.User.Username)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RequestPasswordReset sends a password reset link to a user. It answers the
+// same whether or not the user exists.
+func (h *UserHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Username == "" {
+		http.Error(w, "Username is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.resetService.RequestReset(req.Username); err != nil {
+		log.Printf("Error requesting password reset: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword sets a new password using the token of a reset link. Every
+// session of the user is logged out.
+func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Token == "" || req.Password == "" {
+		http.Error(w, "Token and password are required", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := h.resetService.ResetPassword(req.Token, req.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidResetToken):
+			http.Error(w, "Invalid or expired reset link", http.StatusBadRequest)
+		case errors.Is(err, services.ErrWeakPassword):
+			http.Error(w, passwordErrorMessage(err), http.StatusBadRequest)
+		default:
+			log.Printf("Error resetting password: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	h.disconnectSessions(userID, "")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// passwordErrorMessage turns a password policy error into a response message
+func passwordErrorMessage(err error) string {
+	message := err.Error()
+	return strings.ToUpper(message[:1]) + message[1:]
+}
+
+// CheckAuth checks if the user is authenticated
+func (h *UserHandler) CheckAuth(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user, resolved by the auth middleware
+	user := auth.GetPrincipal(r).User
+
 	// Return user info
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"id":       user.ID,
 		"username": user.Username,
+		"role":     auth.GetPrincipal(r).Role(),
 	})
 }
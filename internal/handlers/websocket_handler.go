@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	"github.com/dbvitor/chat-go/internal/models"
 	"github.com/dbvitor/chat-go/internal/services"
 	"github.com/dbvitor/chat-go/pkg/auth"
+	"github.com/dbvitor/chat-go/pkg/ratelimit"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -18,74 +20,132 @@ import (
 
 // WebSocketHandler handles WebSocket connections for real-time chat
 type WebSocketHandler struct {
-	messageService  *services.MessageService
-	userService     *services.UserService
-	chatroomService *services.ChatroomService
-	clients         map[string]map[*websocket.Conn]bool // Map of chatroom ID to client connections
-	clientsMutex    sync.RWMutex
-	upgrader        websocket.Upgrader
-	stockResults    <-chan amqp.Delivery
+	messageService     *services.MessageService
+	chatroomService    *services.ChatroomService
+	presenceService    *services.PresenceService
+	typingService      *services.TypingService
+	readReceiptService *services.ReadReceiptService
+	pinService         *services.PinService
+	scheduleService    *services.ScheduleService
+	clients            map[string]map[*client]bool // Map of chatroom ID to client connections
+	clientsMutex       sync.RWMutex
+	upgrader           websocket.Upgrader
+	stockResults       <-chan amqp.Delivery
+	unfurlResults      <-chan amqp.Delivery
+	messageRate        float64 // messages per second allowed on each connection
+	messageBurst       int
+	maxFrameSize       int64 // bytes; larger frames close the connection
+}
+
+// client represents a single WebSocket connection to a chatroom
+type client struct {
+	conn       *websocket.Conn
+	userID     string
+	username   string
+	chatroomID string
+	sessionID  string // login session the connection was opened with, empty for access tokens
+	limiter    *ratelimit.Limiter
+	readOnly   bool       // connected with a token that lacks the write scope
+	writeMutex sync.Mutex // gorilla/websocket allows only one concurrent writer
+}
+
+// send writes a JSON frame to the client
+func (c *client) send(v interface{}) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	return c.conn.WriteJSON(v)
+}
+
+// sendError notifies the client that one of its frames was rejected
+func (c *client) sendError(message string) {
+	if err := c.send(ErrorFrame{Type: FrameTypeError, Error: message}); err != nil {
+		log.Printf("Error sending error frame: %v", err)
+	}
 }
 
 // NewWebSocketHandler creates a new WebSocket handler
 func NewWebSocketHandler(
+	originPolicy *OriginPolicy,
 	messageService *services.MessageService,
-	userService *services.UserService,
 	chatroomService *services.ChatroomService,
+	presenceService *services.PresenceService,
+	typingService *services.TypingService,
+	readReceiptService *services.ReadReceiptService,
+	pinService *services.PinService,
+	scheduleService *services.ScheduleService,
 	stockResults <-chan amqp.Delivery,
+	unfurlResults <-chan amqp.Delivery,
+	messageRate float64,
+	messageBurst int,
+	maxFrameSize int64,
 ) *WebSocketHandler {
 	handler := &WebSocketHandler{
-		messageService:  messageService,
-		userService:     userService,
-		chatroomService: chatroomService,
-		clients:         make(map[string]map[*websocket.Conn]bool),
+		messageService:     messageService,
+		chatroomService:    chatroomService,
+		presenceService:    presenceService,
+		typingService:      typingService,
+		readReceiptService: readReceiptService,
+		pinService:         pinService,
+		scheduleService:    scheduleService,
+		clients:            make(map[string]map[*client]bool),
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
-			CheckOrigin: func(r *http.Request) bool {
-				return true // Allow all origins for this example
-			},
+			// Cookies are sent with cross-site WebSocket handshakes, so only
+			// allowed origins may connect as the user
+			CheckOrigin: originPolicy.CheckWebSocketOrigin,
 		},
-		stockResults: stockResults,
+		stockResults:  stockResults,
+		unfurlResults: unfurlResults,
+		messageRate:   messageRate,
+		messageBurst:  messageBurst,
+		maxFrameSize:  maxFrameSize,
 	}
 
 	// Start processing stock results
 	go handler.processStockResults()
 
+	// Start processing link previews
+	go handler.processUnfurlResults()
+
 	return handler
 }
 
-// MessagePayload represents a message sent over the WebSocket connection
+// MessagePayload represents a frame sent by the client over the WebSocket connection
 type MessagePayload struct {
-	Content string `json:"content"`
+	Type      string `json:"type,omitempty"`
+	Content   string `json:"content"`
+	MessageID string `json:"message_id,omitempty"`
+
+	AttachmentIDs []string `json:"attachment_ids,omitempty"`
 }
 
 // Handle upgrades HTTP connection to WebSocket and manages communication
 func (h *WebSocketHandler) Handle(w http.ResponseWriter, r *http.Request) {
-	// Check if authenticated
-	if !auth.IsAuthenticated(r) {
-		http.Error(w, "Not authenticated", http.StatusUnauthorized)
-		return
-	}
-
-	// Get user ID
-	userID, err := auth.GetAuthenticatedUser(r)
-	if err != nil {
-		http.Error(w, "Not authenticated", http.StatusUnauthorized)
-		return
-	}
+	// Get authenticated user, resolved by the auth middleware
+	principal := auth.GetPrincipal(r)
+	userID, user := principal.UserID(), principal.User
 
 	// Get chatroom ID from URL
 	vars := mux.Vars(r)
 	chatroomID := vars["id"]
 
 	// Check if chatroom exists
-	_, err = h.chatroomService.GetByID(chatroomID)
+	chatroom, err := h.chatroomService.GetByID(chatroomID)
 	if err != nil {
 		http.Error(w, "Chatroom not found", http.StatusNotFound)
 		return
 	}
 
+	// Read-only tokens can follow a chatroom but not post to it
+	readOnly := principal.Token != nil && !principal.Token.HasScope(models.ScopeWrite)
+
+	// Connections are closed when their login session is revoked
+	var sessionID string
+	if principal.Session != nil {
+		sessionID = principal.Session.ID
+	}
+
 	// Upgrade HTTP connection to WebSocket
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -93,13 +153,42 @@ func (h *WebSocketHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuse oversized frames before they are buffered and decoded
+	conn.SetReadLimit(h.maxFrameSize)
+
 	// Register client
+	c := &client{
+		conn:       conn,
+		userID:     userID,
+		username:   user.Username,
+		chatroomID: chatroomID,
+		sessionID:  sessionID,
+		limiter:    ratelimit.NewLimiter(h.messageRate, h.messageBurst),
+		readOnly:   readOnly,
+	}
 	h.clientsMutex.Lock()
 	if _, ok := h.clients[chatroomID]; !ok {
-		h.clients[chatroomID] = make(map[*websocket.Conn]bool)
+		h.clients[chatroomID] = make(map[*client]bool)
 	}
-	h.clients[chatroomID][conn] = true
+	h.clients[chatroomID][c] = true
 	h.clientsMutex.Unlock()
+	firstConnection := h.presenceService.Connect(chatroomID, userID, user.Username)
+	h.presenceService.UpdateProfile(user.Profile())
+
+	// Send the room's topic, description and pins before its history
+	pins, err := h.pinService.List(chatroomID)
+	if err != nil {
+		log.Printf("Error fetching pins: %v", err)
+	}
+	roomInfo := RoomInfoFrame{
+		Type:      FrameTypeRoomInfo,
+		Chatroom:  chatroom,
+		CanManage: services.CanManage(principal.Actor(), chatroom),
+		Pins:      pins,
+	}
+	if err := c.send(roomInfo); err != nil {
+		log.Printf("Error sending room info: %v", err)
+	}
 
 	// Get messages for chatroom
 	messages, err := h.messageService.GetMessagesByChatroomID(chatroomID)
@@ -108,35 +197,48 @@ func (h *WebSocketHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	} else {
 		// Send messages to client
 		for _, message := range messages {
-			if err := conn.WriteJSON(message); err != nil {
+			if err := c.send(message); err != nil {
 				log.Printf("Error sending message: %v", err)
 			}
 		}
 	}
 
-	// Send system message
-	user, _ := h.userService.GetByID(userID)
-	systemMessage := models.NewMessage(database.BotUserID, "System", chatroomID, user.Username+" joined the chat", models.MessageTypeSystem)
-	h.broadcastMessage(systemMessage, chatroomID)
+	// Send read positions so the client can render "seen by"
+	receipts, err := h.readReceiptService.GetByChatroomID(chatroomID)
+	if err != nil {
+		log.Printf("Error fetching read receipts: %v", err)
+	} else if err := c.send(ReceiptsFrame{Type: FrameTypeReceipts, ChatroomID: chatroomID, Receipts: receipts}); err != nil {
+		log.Printf("Error sending read receipts: %v", err)
+	}
+
+	// Announce the user only on their first connection to the room,
+	// otherwise just tell the new client who is online
+	if firstConnection {
+		systemMessage := models.NewMessage(database.BotUserID, "System", chatroomID, user.Username+" joined the chat", models.MessageTypeSystem)
+		h.broadcastMessage(systemMessage, chatroomID)
+		h.broadcastPresence(chatroomID)
+	} else if err := c.send(h.presenceFrame(chatroomID)); err != nil {
+		log.Printf("Error sending presence: %v", err)
+	}
 
 	// Handle incoming messages
-	go h.handleClient(conn, userID, chatroomID)
+	go h.handleClient(c)
 }
 
 // handleClient processes messages from a WebSocket client
-func (h *WebSocketHandler) handleClient(conn *websocket.Conn, userID, chatroomID string) {
+func (h *WebSocketHandler) handleClient(c *client) {
+	conn, userID, chatroomID := c.conn, c.userID, c.chatroomID
+
 	defer func() {
 		// Unregister client
-		h.clientsMutex.Lock()
-		delete(h.clients[chatroomID], conn)
-		h.clientsMutex.Unlock()
-		conn.Close()
-
-		// Send system message
-		user, err := h.userService.GetByID(userID)
-		if err == nil {
-			systemMessage := models.NewMessage(database.BotUserID, "System", chatroomID, user.Username+" left the chat", models.MessageTypeSystem)
+		h.removeClient(c)
+		h.stopTyping(c)
+
+		// Announce the user only when their last connection to the room closes
+		if h.presenceService.Disconnect(chatroomID, userID) {
+			systemMessage := models.NewMessage(database.BotUserID, "System", chatroomID, c.username+" left the chat", models.MessageTypeSystem)
 			h.broadcastMessage(systemMessage, chatroomID)
+			h.broadcastPresence(chatroomID)
 		}
 	}()
 
@@ -145,49 +247,369 @@ func (h *WebSocketHandler) handleClient(conn *websocket.Conn, userID, chatroomID
 		var payload MessagePayload
 		err := conn.ReadJSON(&payload)
 		if err != nil {
+			if errors.Is(err, websocket.ErrReadLimit) {
+				log.Printf("Closing connection of user %s: frame larger than %d bytes", userID, h.maxFrameSize)
+				break
+			}
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
 
-		log.Printf("Received message from user %s in chatroom %s: %s", userID, chatroomID, payload.Content)
+		if c.readOnly {
+			c.sendError("Token does not have the write scope")
+			continue
+		}
 
-		// Check if it's a stock command
-		if strings.HasPrefix(payload.Content, "/stock=") {
-			log.Printf("Detected stock command: %s", payload.Content)
+		switch payload.Type {
+		case "", ClientFrameMessage:
+			h.handleChatMessage(c, payload)
+		case ClientFrameTypingStart, ClientFrameTypingStop:
+			// Typing updates share the connection's rate limit. Excess ones
+			// are dropped quietly, stale indicators expire on their own.
+			if !c.limiter.Allow() {
+				continue
+			}
+			if payload.Type == ClientFrameTypingStart {
+				h.startTyping(c)
+			} else {
+				h.stopTyping(c)
+			}
+		case ClientFrameMarkRead:
+			// Dropped marks are harmless, the client marks again on the next message
+			if !c.limiter.Allow() {
+				continue
+			}
+			h.markRead(c, payload.MessageID)
+		default:
+			c.sendError("Unknown frame type: " + payload.Type)
 		}
+	}
+}
 
-		// Create and save message
-		message, err := h.messageService.CreateMessage(userID, chatroomID, payload.Content)
-		if err != nil {
+// handleChatMessage saves a chat message and broadcasts it to the chatroom
+func (h *WebSocketHandler) handleChatMessage(c *client, payload MessagePayload) {
+	userID, chatroomID := c.userID, c.chatroomID
+
+	// Drop messages sent faster than the connection's rate limit
+	if !c.limiter.Allow() {
+		c.sendError("You are sending messages too fast, please slow down")
+		return
+	}
+
+	log.Printf("Received message from user %s in chatroom %s: %s", userID, chatroomID, payload.Content)
+
+	// Sending a message ends the typing indicator
+	h.stopTyping(c)
+
+	// Reminders are scheduled instead of posted
+	if services.IsRemindCommand(payload.Content) {
+		h.scheduleReminder(c, payload.Content)
+		return
+	}
+
+	// Check if it's a stock command
+	if strings.HasPrefix(payload.Content, "/stock=") {
+		log.Printf("Detected stock command: %s", payload.Content)
+	}
+
+	// Create and save message
+	message, err := h.messageService.CreateMessage(userID, chatroomID, payload.Content, payload.AttachmentIDs...)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidAttachment):
+			c.sendError("One or more attachments are invalid")
+		case errors.Is(err, services.ErrMessageEmpty):
+			c.sendError("Message cannot be empty")
+		case errors.Is(err, services.ErrMessageTooLong):
+			// Keep the details, e.g. "(2500 characters, maximum is 2000)"
+			c.sendError("Message is too long" + strings.TrimPrefix(err.Error(), services.ErrMessageTooLong.Error()))
+		case errors.Is(err, services.ErrMessageBlocked):
+			c.sendError("Message contains blocked words")
+		case errors.Is(err, services.ErrChatroomArchived):
+			c.sendError("This chatroom is archived and read-only")
+		default:
 			log.Printf("Error creating message: %v", err)
-			continue
 		}
+		return
+	}
+
+	// If message is nil, it's a stock command and doesn't need to be broadcast
+	if message != nil {
+		h.deliverMessage(message)
+	} else {
+		log.Printf("Message is nil - likely a stock command that was processed")
+	}
+}
+
+// deliverMessage broadcasts a new chat message to its chatroom and alerts
+// mentioned users wherever they are connected
+func (h *WebSocketHandler) deliverMessage(message *models.Message) {
+	h.broadcastMessage(message, message.ChatroomID)
+
+	for _, mention := range message.Mentions {
+		h.sendToUser(mention.UserID, MentionFrame{Type: FrameTypeMention, Message: message})
+	}
+}
+
+// scheduleReminder schedules the reminder of a "/remind me in ... to ..."
+// command and confirms it to the client
+func (h *WebSocketHandler) scheduleReminder(c *client, command string) {
+	schedule, err := h.scheduleService.Remind(c.userID, c.chatroomID, command)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidRemindCommand),
+			errors.Is(err, services.ErrInvalidSendTime),
+			errors.Is(err, services.ErrScheduleLimitReached),
+			errors.Is(err, services.ErrMessageTooLong),
+			errors.Is(err, services.ErrMessageBlocked):
+			c.sendError(err.Error())
+		case errors.Is(err, services.ErrChatroomArchived):
+			c.sendError("This chatroom is archived and read-only")
+		default:
+			log.Printf("Error scheduling reminder: %v", err)
+		}
+		return
+	}
+
+	if err := c.send(ScheduledFrame{Type: FrameTypeScheduled, Schedule: schedule}); err != nil {
+		log.Printf("Error sending scheduled confirmation: %v", err)
+	}
+}
 
-		// If message is nil, it's a stock command and doesn't need to be broadcast
-		if message != nil {
-			// Broadcast message to all clients in the chatroom
-			h.broadcastMessage(message, chatroomID)
+// DeliverScheduled sends a posted scheduled message to connected clients like
+// any other chat message
+func (h *WebSocketHandler) DeliverScheduled(message *models.Message) {
+	h.deliverMessage(message)
+}
+
+// Remind sends a due reminder to its author only, reporting whether they are connected
+func (h *WebSocketHandler) Remind(schedule *models.ScheduledMessage) bool {
+	return h.sendToUser(schedule.UserID, ReminderFrame{Type: FrameTypeReminder, Reminder: schedule})
+}
+
+// markRead moves the user's read pointer and broadcasts it to the chatroom
+func (h *WebSocketHandler) markRead(c *client, messageID string) {
+	if messageID == "" {
+		c.sendError("message_id is required")
+		return
+	}
+
+	receipt, err := h.readReceiptService.MarkRead(c.userID, c.username, c.chatroomID, messageID)
+	if err != nil {
+		if errors.Is(err, services.ErrMessageNotInChatroom) {
+			c.sendError("Message not found in this chatroom")
 		} else {
-			log.Printf("Message is nil - likely a stock command that was processed")
+			log.Printf("Error marking message as read: %v", err)
 		}
+		return
+	}
+
+	// Nothing to broadcast if the user had already read past this message
+	if receipt != nil {
+		h.broadcast(ReadFrame{Type: FrameTypeRead, ReadReceipt: receipt}, c.chatroomID)
+	}
+}
+
+// startTyping broadcasts that a user started typing, unless they already were
+func (h *WebSocketHandler) startTyping(c *client) {
+	started := h.typingService.Start(c.chatroomID, c.userID, func() {
+		// Clear stale indicators, e.g. when the client stopped sending updates
+		h.broadcastTyping(c, false)
+	})
+	if started {
+		h.broadcastTyping(c, true)
 	}
 }
 
+// stopTyping broadcasts that a user stopped typing, if they were
+func (h *WebSocketHandler) stopTyping(c *client) {
+	if h.typingService.Stop(c.chatroomID, c.userID) {
+		h.broadcastTyping(c, false)
+	}
+}
+
+// broadcastTyping sends a typing indicator update to everyone in the client's chatroom
+func (h *WebSocketHandler) broadcastTyping(c *client, typing bool) {
+	h.broadcast(TypingFrame{
+		Type:       FrameTypeTyping,
+		ChatroomID: c.chatroomID,
+		UserID:     c.userID,
+		Username:   c.username,
+		Typing:     typing,
+	}, c.chatroomID)
+}
+
+// removeClient unregisters a client and closes its connection
+func (h *WebSocketHandler) removeClient(c *client) {
+	h.clientsMutex.Lock()
+	delete(h.clients[c.chatroomID], c)
+	if len(h.clients[c.chatroomID]) == 0 {
+		delete(h.clients, c.chatroomID)
+	}
+	h.clientsMutex.Unlock()
+	c.conn.Close()
+}
+
 // broadcastMessage sends a message to all clients in a chatroom
 func (h *WebSocketHandler) broadcastMessage(message *models.Message, chatroomID string) {
-	h.clientsMutex.RLock()
-	defer h.clientsMutex.RUnlock()
+	h.broadcast(message, chatroomID)
+}
 
-	for client := range h.clients[chatroomID] {
-		err := client.WriteJSON(message)
-		if err != nil {
+// broadcast sends any JSON frame to all clients in a chatroom
+func (h *WebSocketHandler) broadcast(frame interface{}, chatroomID string) {
+	h.clientsMutex.RLock()
+	var failed []*client
+	for c := range h.clients[chatroomID] {
+		if err := c.send(frame); err != nil {
 			log.Printf("Error broadcasting message: %v", err)
-			client.Close()
-			delete(h.clients[chatroomID], client)
+			failed = append(failed, c)
 		}
 	}
+	h.clientsMutex.RUnlock()
+
+	// Closing the connection makes its read loop exit and unregister it
+	for _, c := range failed {
+		c.conn.Close()
+	}
+}
+
+// broadcastAll sends a frame to every connected client, in all chatrooms
+func (h *WebSocketHandler) broadcastAll(frame interface{}) {
+	h.clientsMutex.RLock()
+	chatroomIDs := make([]string, 0, len(h.clients))
+	for chatroomID := range h.clients {
+		chatroomIDs = append(chatroomIDs, chatroomID)
+	}
+	h.clientsMutex.RUnlock()
+
+	for _, chatroomID := range chatroomIDs {
+		h.broadcast(frame, chatroomID)
+	}
+}
+
+// sendToUser sends a frame to every connection of a user, across all
+// chatrooms, and reports whether any connection received it
+func (h *WebSocketHandler) sendToUser(userID string, frame interface{}) bool {
+	h.clientsMutex.RLock()
+	sent := false
+	var failed []*client
+	for _, clients := range h.clients {
+		for c := range clients {
+			if c.userID != userID {
+				continue
+			}
+			if err := c.send(frame); err != nil {
+				log.Printf("Error sending frame to user %s: %v", userID, err)
+				failed = append(failed, c)
+				continue
+			}
+			sent = true
+		}
+	}
+	h.clientsMutex.RUnlock()
+
+	for _, c := range failed {
+		c.conn.Close()
+	}
+	return sent
+}
+
+// ProfileUpdated pushes a changed profile and the new presence snapshot to
+// the chatrooms the user is in
+func (h *WebSocketHandler) ProfileUpdated(profile *models.Profile) {
+	for _, chatroomID := range h.presenceService.UpdateProfile(profile) {
+		h.broadcast(ProfileFrame{Type: FrameTypeProfile, Profile: profile}, chatroomID)
+		h.broadcastPresence(chatroomID)
+	}
+}
+
+// ChatroomUpdated pushes a changed chatroom to every connected client, so
+// room lists and headers follow renames and archiving
+func (h *WebSocketHandler) ChatroomUpdated(chatroom *models.Chatroom) {
+	h.broadcastAll(RoomUpdatedFrame{Type: FrameTypeRoomUpdated, Chatroom: chatroom})
+}
+
+// PinsUpdated pushes the pinned messages of a chatroom to its clients
+func (h *WebSocketHandler) PinsUpdated(chatroomID string, pins []*models.Pin) {
+	h.broadcast(PinsFrame{Type: FrameTypePins, ChatroomID: chatroomID, Pins: pins}, chatroomID)
+}
+
+// ChatroomDeleted tells every connected client about a deleted chatroom and
+// closes the connections to it
+func (h *WebSocketHandler) ChatroomDeleted(chatroomID string) {
+	h.broadcastAll(RoomDeletedFrame{Type: FrameTypeRoomDeleted, ChatroomID: chatroomID})
+
+	h.clientsMutex.RLock()
+	clients := make([]*client, 0, len(h.clients[chatroomID]))
+	for c := range h.clients[chatroomID] {
+		clients = append(clients, c)
+	}
+	h.clientsMutex.RUnlock()
+
+	// Closing the connections makes their read loops exit and unregister them
+	for _, c := range clients {
+		c.conn.Close()
+	}
+}
+
+// DisconnectUser closes every connection of a user, e.g. once their account
+// is disabled
+func (h *WebSocketHandler) DisconnectUser(userID string) {
+	h.disconnect(func(c *client) bool {
+		return c.userID == userID
+	})
+}
+
+// DisconnectSession closes the WebSocket connections opened with a revoked
+// login session
+func (h *WebSocketHandler) DisconnectSession(sessionID string) {
+	h.disconnect(func(c *client) bool {
+		return c.sessionID == sessionID
+	})
+}
+
+// DisconnectSessions closes the WebSocket connections a user opened with any
+// login session except keepSessionID, after those sessions were revoked.
+// Connections authenticated with access tokens stay open.
+func (h *WebSocketHandler) DisconnectSessions(userID, keepSessionID string) {
+	h.disconnect(func(c *client) bool {
+		return c.userID == userID && c.sessionID != "" && c.sessionID != keepSessionID
+	})
+}
+
+// disconnect closes the connections of every client matching the filter;
+// their handleClient loops then unregister them
+func (h *WebSocketHandler) disconnect(filter func(c *client) bool) {
+	h.clientsMutex.RLock()
+	var clients []*client
+	for _, chatroomClients := range h.clients {
+		for c := range chatroomClients {
+			if filter(c) {
+				clients = append(clients, c)
+			}
+		}
+	}
+	h.clientsMutex.RUnlock()
+
+	for _, c := range clients {
+		c.conn.Close()
+	}
+}
+
+// presenceFrame builds a snapshot of the users online in a chatroom
+func (h *WebSocketHandler) presenceFrame(chatroomID string) PresenceFrame {
+	return PresenceFrame{
+		Type:       FrameTypePresence,
+		ChatroomID: chatroomID,
+		Users:      h.presenceService.GetByChatroomID(chatroomID),
+	}
+}
+
+// broadcastPresence sends the current presence snapshot to everyone in a chatroom
+func (h *WebSocketHandler) broadcastPresence(chatroomID string) {
+	h.broadcast(h.presenceFrame(chatroomID), chatroomID)
 }
 
 // processStockResults listens for stock results and broadcasts them
@@ -251,3 +673,28 @@ func (h *WebSocketHandler) processStockResults() {
 
 	log.Println("Stock results processing loop finished")
 }
+
+// processUnfurlResults listens for link previews and pushes the updated messages
+func (h *WebSocketHandler) processUnfurlResults() {
+	for delivery := range h.unfurlResults {
+		var result models.UnfurlResult
+		if err := json.Unmarshal(delivery.Body, &result); err != nil {
+			log.Printf("Error parsing unfurl result: %v", err)
+			continue
+		}
+
+		if result.Error != "" || result.Preview == nil {
+			// Pages without a preview are simply left as plain links
+			continue
+		}
+
+		result.Preview.MessageID = result.MessageID
+		message, err := h.messageService.AddLinkPreview(result.Preview)
+		if err != nil {
+			log.Printf("Error saving link preview: %v", err)
+			continue
+		}
+
+		h.broadcast(MessageUpdatedFrame{Type: FrameTypeMessageUpdated, Message: message}, message.ChatroomID)
+	}
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+// Scopes of personal access tokens. Each scope includes the ones below it:
+// admin can do everything write can, and write everything read can.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+var scopeLevels = map[string]int{
+	ScopeRead:  1,
+	ScopeWrite: 2,
+	ScopeAdmin: 3,
+}
+
+// AccessToken is a personal access token used by scripts and integrations
+// through the Authorization: Bearer header. Only a hash of the token is stored.
+type AccessToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	Prefix     string     `json:"prefix"`          // first characters of the token, to recognize it
+	Token      string     `json:"token,omitempty"` // plaintext, only returned when the token is created
+	TokenHash  string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"-"`
+}
+
+// IsValidScope reports whether scope is a known token scope
+func IsValidScope(scope string) bool {
+	_, ok := scopeLevels[scope]
+	return ok
+}
+
+// HasScope reports whether the token grants scope, directly or through a broader scope
+func (t *AccessToken) HasScope(scope string) bool {
+	required, ok := scopeLevels[scope]
+	if !ok {
+		return false
+	}
+	for _, granted := range t.Scopes {
+		if scopeLevels[granted] >= required {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether the token can still be used
+func (t *AccessToken) Active(now time.Time) bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
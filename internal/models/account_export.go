@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// Membership summarizes a user's involvement in a chatroom: rooms they
+// created, posted in or read
+type Membership struct {
+	ChatroomID     string     `json:"chatroom_id"`
+	ChatroomName   string     `json:"chatroom_name"`
+	Creator        bool       `json:"creator"`
+	MessageCount   int        `json:"message_count"`
+	FirstMessageAt *time.Time `json:"first_message_at,omitempty"`
+	LastMessageAt  *time.Time `json:"last_message_at,omitempty"`
+	LastReadAt     *time.Time `json:"last_read_at,omitempty"`
+}
+
+// AccountExport is everything stored about a user, as handed to them when
+// they export their data
+type AccountExport struct {
+	ExportedAt        time.Time           `json:"exported_at"`
+	Account           *User               `json:"account"`
+	Profile           *Profile            `json:"profile"`
+	Memberships       []*Membership       `json:"memberships"`
+	Messages          []*Message          `json:"messages"`
+	Attachments       []*Attachment       `json:"attachments"`
+	Sessions          []*Session          `json:"sessions"`
+	AccessTokens      []*AccessToken      `json:"access_tokens"`
+	ScheduledMessages []*ScheduledMessage `json:"scheduled_messages"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Attachment represents a file uploaded to a chat room and attached to a message
+type Attachment struct {
+	ID          string    `json:"id"`
+	MessageID   string    `json:"message_id,omitempty"`
+	UserID      string    `json:"user_id"`
+	ChatroomID  string    `json:"chatroom_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	StorageKey  string    `json:"-"`
+	URL         string    `json:"url"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// NewAttachment creates a new attachment that isn't linked to a message yet
+func NewAttachment(userID, chatroomID, filename, contentType string, size int64, storageKey string) *Attachment {
+	return &Attachment{
+		ID:          "",
+		UserID:      userID,
+		ChatroomID:  chatroomID,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        size,
+		StorageKey:  storageKey,
+		CreatedAt:   time.Now(),
+	}
+}
+
+// IsImage reports whether the attachment can be rendered inline as an image
+func (a *Attachment) IsImage() bool {
+	return strings.HasPrefix(a.ContentType, "image/")
+}
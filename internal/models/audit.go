@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+)
+
+// Actions recorded in the audit log
+const (
+	AuditUserRoleChanged  = "user.role_changed"
+	AuditUserDisabled     = "user.disabled"
+	AuditUserEnabled      = "user.enabled"
+	AuditUserTwoFactorOff = "user.two_factor_disabled"
+	AuditUserDeleted      = "user.deleted"
+	AuditChatroomCreated  = "chatroom.created"
+	AuditChatroomUpdated  = "chatroom.updated"
+	AuditChatroomArchived = "chatroom.archived"
+	AuditChatroomRestored = "chatroom.unarchived"
+	AuditChatroomDeleted  = "chatroom.deleted"
+	AuditMessagePinned    = "message.pinned"
+	AuditMessageUnpinned  = "message.unpinned"
+)
+
+// Types of the objects an audit entry refers to
+const (
+	AuditTargetUser     = "user"
+	AuditTargetChatroom = "chatroom"
+	AuditTargetMessage  = "message"
+)
+
+// AuditEntry records an administrative action: who did what to which object
+type AuditEntry struct {
+	ID            string            `json:"id"`
+	ActorID       string            `json:"actor_id"`
+	ActorUsername string            `json:"actor_username"`
+	Action        string            `json:"action"`
+	TargetType    string            `json:"target_type"`
+	TargetID      string            `json:"target_id"`
+	Details       map[string]string `json:"details,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+// NewAuditEntry creates an audit entry for an action of a user
+func NewAuditEntry(actor *User, action, targetType, targetID string, details map[string]string) *AuditEntry {
+	return &AuditEntry{
+		ID:            "",
+		ActorID:       actor.ID,
+		ActorUsername: actor.Username,
+		Action:        action,
+		TargetType:    targetType,
+		TargetID:      targetID,
+		Details:       details,
+		CreatedAt:     time.Now(),
+	}
+}
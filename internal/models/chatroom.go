@@ -6,19 +6,67 @@ import (
 
 // Chatroom represents a chat room where users can send messages
 type Chatroom struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Topic       string     `json:"topic"`
+	Description string     `json:"description"`
+	CreatedBy   string     `json:"created_by,omitempty"`  // empty for rooms created before creators were recorded
+	ArchivedAt  *time.Time `json:"archived_at,omitempty"` // set while the room is read-only
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Orders of chatroom listings
+const (
+	ChatroomSortName     = "name"
+	ChatroomSortActivity = "activity" // most recent message first
+	ChatroomSortMembers  = "members"  // most members first
+)
+
+// ChatroomQuery holds the filter and order of a chatroom listing
+type ChatroomQuery struct {
+	Name   string // only list rooms whose name contains this, case-insensitively, if set
+	Sort   string
+	Limit  int
+	Offset int
+}
+
+// ChatroomSummary is a chatroom with its member count and latest message,
+// for listings. Members are the users who created, posted in or read the room.
+type ChatroomSummary struct {
+	*Chatroom
+	MemberCount int             `json:"member_count"`
+	LastMessage *MessagePreview `json:"last_message,omitempty"`
+}
+
+// MessagePreview is the start of a message, shown in chatroom listings
+type MessagePreview struct {
 	ID        string    `json:"id"`
-	Name      string    `json:"name"`
+	Username  string    `json:"username"`
+	Content   string    `json:"content"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// NewChatroom creates a new chat room
-func NewChatroom(name string) *Chatroom {
+// ChatroomUpdate holds the chatroom fields to change; nil fields are left as they are
+type ChatroomUpdate struct {
+	Name        *string `json:"name"`
+	Topic       *string `json:"topic"`
+	Description *string `json:"description"`
+}
+
+// NewChatroom creates a new chat room created by a user
+func NewChatroom(name, createdBy string) *Chatroom {
 	now := time.Now()
 	return &Chatroom{
 		ID:        "",
 		Name:      name,
+		CreatedBy: createdBy,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
 }
+
+// Archived reports whether the chatroom has been archived, which makes it read-only
+func (c *Chatroom) Archived() bool {
+	return c.ArchivedAt != nil
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// LinkPreview holds the OpenGraph metadata of a URL posted in a message
+type LinkPreview struct {
+	MessageID   string    `json:"message_id"`
+	URL         string    `json:"url"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	ImageURL    string    `json:"image_url,omitempty"`
+	SiteName    string    `json:"site_name,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// UnfurlRequest asks the unfurler worker to fetch a preview for a URL in a message
+type UnfurlRequest struct {
+	MessageID  string `json:"message_id"`
+	ChatroomID string `json:"chatroom_id"`
+	URL        string `json:"url"`
+}
+
+// UnfurlResult is the worker's answer to an UnfurlRequest
+type UnfurlResult struct {
+	MessageID  string       `json:"message_id"`
+	ChatroomID string       `json:"chatroom_id"`
+	Preview    *LinkPreview `json:"preview,omitempty"`
+	Error      string       `json:"error,omitempty"`
+}
@@ -13,13 +13,20 @@ const (
 )
 
 type Message struct {
-	ID         string      `json:"id"`
-	UserID     string      `json:"user_id"`
-	Username   string      `json:"username"`
-	ChatroomID string      `json:"chatroom_id"`
-	Content    string      `json:"content"`
-	Type       MessageType `json:"type"`
-	CreatedAt  time.Time   `json:"created_at"`
+	ID          string      `json:"id"`
+	UserID      string      `json:"user_id"`
+	Username    string      `json:"username"`
+	DisplayName string      `json:"display_name,omitempty"` // current profile of the author, not stored with the message
+	AvatarURL   string      `json:"avatar_url,omitempty"`
+	ChatroomID  string      `json:"chatroom_id"`
+	Content     string      `json:"content"`
+	HTML        string      `json:"html,omitempty"` // sanitized rendering of Content, never stored
+	Type        MessageType `json:"type"`
+	CreatedAt   time.Time   `json:"created_at"`
+
+	Attachments []*Attachment  `json:"attachments,omitempty"`
+	Mentions    []*Mention     `json:"mentions,omitempty"`
+	Previews    []*LinkPreview `json:"previews,omitempty"`
 }
 
 func NewMessage(userID, username, chatroomID, content string, msgType MessageType) *Message {
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+type NotificationType string
+
+const (
+	NotificationTypeMention NotificationType = "mention"
+)
+
+// Mention represents a user mentioned with @username in a message
+type Mention struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// Notification is an entry in a user's notification feed
+type Notification struct {
+	ID            string           `json:"id"`
+	UserID        string           `json:"user_id"`
+	Type          NotificationType `json:"type"`
+	MessageID     string           `json:"message_id"`
+	ChatroomID    string           `json:"chatroom_id"`
+	ActorID       string           `json:"actor_id"`
+	ActorUsername string           `json:"actor_username"`
+	Content       string           `json:"content"`
+	ReadAt        *time.Time       `json:"read_at"`
+	CreatedAt     time.Time        `json:"created_at"`
+}
+
+// NewMentionNotification creates a notification telling a user they were mentioned in a message
+func NewMentionNotification(userID string, message *Message) *Notification {
+	return &Notification{
+		ID:            "",
+		UserID:        userID,
+		Type:          NotificationTypeMention,
+		MessageID:     message.ID,
+		ChatroomID:    message.ChatroomID,
+		ActorID:       message.UserID,
+		ActorUsername: message.Username,
+		Content:       message.Content,
+		CreatedAt:     time.Now(),
+	}
+}
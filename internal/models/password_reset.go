@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// PasswordReset is a single-use request to reset a user's password. Only the
+// hash of the reset token is stored; the token itself is sent to the user.
+type PasswordReset struct {
+	ID        string
+	UserID    string
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// Active reports whether the reset token can still be used
+func (p *PasswordReset) Active(now time.Time) bool {
+	return p.UsedAt == nil && now.Before(p.ExpiresAt)
+}
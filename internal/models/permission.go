@@ -0,0 +1,39 @@
+package models
+
+// Permission names an operation that only some global roles may perform
+type Permission string
+
+const (
+	PermissionCreateRooms  Permission = "rooms.create"
+	PermissionManageRooms  Permission = "rooms.manage" // delete any room, create rooms without limit
+	PermissionManageUsers  Permission = "users.manage" // change roles, disable accounts, reset 2FA
+	PermissionViewAuditLog Permission = "audit.view"
+)
+
+// rolePermissions lists the permissions granted to each global role. Bots
+// only post messages, so they get none of them.
+var rolePermissions = map[string][]Permission{
+	RoleAdmin: {PermissionCreateRooms, PermissionManageRooms, PermissionManageUsers, PermissionViewAuditLog},
+	RoleUser:  {PermissionCreateRooms},
+	RoleBot:   {},
+}
+
+// RoleHasPermission reports whether a global role grants a permission
+func RoleHasPermission(role string, permission Permission) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RequiredScope returns the access token scope needed to use a permission.
+// Permissions beyond those of regular users need the admin scope, so tokens
+// of administrators can't do administration unless created for it.
+func (p Permission) RequiredScope() string {
+	if RoleHasPermission(RoleUser, p) {
+		return ScopeWrite
+	}
+	return ScopeAdmin
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// Pin marks a message that a moderator pinned to the top of its chat room
+type Pin struct {
+	ChatroomID       string    `json:"chatroom_id"`
+	MessageID        string    `json:"message_id"`
+	PinnedBy         string    `json:"pinned_by"`
+	PinnedByUsername string    `json:"pinned_by_username"`
+	PinnedAt         time.Time `json:"pinned_at"`
+	Message          *Message  `json:"message"`
+}
+
+// NewPin creates a pin of a message by a user
+func NewPin(chatroomID, messageID, pinnedBy string) *Pin {
+	return &Pin{
+		ChatroomID: chatroomID,
+		MessageID:  messageID,
+		PinnedBy:   pinnedBy,
+		PinnedAt:   time.Now(),
+	}
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// Presence represents a user who is currently connected to a chat room
+type Presence struct {
+	UserID      string    `json:"user_id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name,omitempty"`
+	AvatarURL   string    `json:"avatar_url,omitempty"`
+	StatusText  string    `json:"status_text,omitempty"`
+	Connections int       `json:"connections"`
+	Since       time.Time `json:"since"`
+}
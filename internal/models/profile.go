@@ -0,0 +1,35 @@
+package models
+
+import (
+	"path"
+	"strings"
+)
+
+// Profile is the public part of a user's account shown next to their
+// messages and in presence lists
+type Profile struct {
+	UserID      string `json:"user_id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+	StatusText  string `json:"status_text"`
+	TimeZone    string `json:"time_zone"`
+}
+
+// ProfileUpdate holds the profile fields to change; nil fields are left as they are
+type ProfileUpdate struct {
+	DisplayName *string `json:"display_name"`
+	StatusText  *string `json:"status_text"`
+	TimeZone    *string `json:"time_zone"`
+}
+
+// AvatarURL returns the address of a user's avatar, or an empty string if
+// they don't have one. The storage key changes with every upload, so it's
+// included to let browsers cache each version for good.
+func AvatarURL(userID, avatarKey string) string {
+	if avatarKey == "" {
+		return ""
+	}
+	version := strings.TrimSuffix(path.Base(avatarKey), path.Ext(avatarKey))
+	return "/api/users/" + userID + "/avatar?v=" + version
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// ReadReceipt records the last message a user has read in a chat room
+type ReadReceipt struct {
+	UserID     string    `json:"user_id"`
+	Username   string    `json:"username"`
+	ChatroomID string    `json:"chatroom_id"`
+	MessageID  string    `json:"message_id"`
+	ReadAt     time.Time `json:"read_at"`
+
+	// MessageCreatedAt orders read positions; it's set when loading a single
+	// user's receipt
+	MessageCreatedAt time.Time `json:"-"`
+}
+
+// NewReadReceipt creates a read receipt pointing at a message
+func NewReadReceipt(userID, username, chatroomID, messageID string) *ReadReceipt {
+	return &ReadReceipt{
+		UserID:     userID,
+		Username:   username,
+		ChatroomID: chatroomID,
+		MessageID:  messageID,
+		ReadAt:     time.Now(),
+	}
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// Kinds of scheduled messages
+const (
+	ScheduleKindMessage  = "message"  // posted as written, e.g. an announcement
+	ScheduleKindReminder = "reminder" // pushed to its author only, never posted
+)
+
+// ScheduledMessage is a message a user wrote to be posted to a chat room
+// later, or a reminder to themselves set in a chat room
+type ScheduledMessage struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	ChatroomID string    `json:"chatroom_id"`
+	Kind       string    `json:"kind"`
+	Content    string    `json:"content"`
+	SendAt     time.Time `json:"send_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// NewScheduledMessage creates a message to be posted at sendAt. Send times
+// are stored without a time zone, so they are kept in UTC.
+func NewScheduledMessage(userID, chatroomID, kind, content string, sendAt time.Time) *ScheduledMessage {
+	return &ScheduledMessage{
+		ID:         "",
+		UserID:     userID,
+		ChatroomID: chatroomID,
+		Kind:       kind,
+		Content:    content,
+		SendAt:     sendAt.UTC(),
+		CreatedAt:  time.Now(),
+	}
+}
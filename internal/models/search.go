@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// SearchQuery holds the filters of a full-text message search
+type SearchQuery struct {
+	Text       string    // search terms, in web search syntax
+	ChatroomID string    // only search this chatroom, if set
+	Username   string    // only match messages from this user, if set
+	Before     time.Time // only match messages created before this time, if set
+	Limit      int
+	Offset     int
+}
+
+// SearchResult is a message matching a search, with the matched terms highlighted
+type SearchResult struct {
+	Message *Message `json:"message"`
+	Snippet string   `json:"snippet"`
+	Rank    float64  `json:"rank"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// Session is a server-side login session. The session cookie only carries its
+// ID, so a session can be revoked before it expires.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Pending    bool       `json:"-"`       // waiting for a second factor; doesn't authenticate yet
+	Current    bool       `json:"current"` // set when listing, for the session making the request
+}
+
+// NewSession creates a session for a user that expires after maxAge
+func NewSession(userID, userAgent, ipAddress string, maxAge time.Duration) *Session {
+	now := time.Now()
+	return &Session{
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(maxAge),
+	}
+}
+
+// Active reports whether the session can still be used
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// TwoFactor holds a user's TOTP secret. It is enabled once the user confirms
+// enrollment with a code from their authenticator app.
+type TwoFactor struct {
+	UserID       string
+	Secret       string
+	LastUsedStep int64 // time step of the last accepted code, so codes can't be replayed
+	CreatedAt    time.Time
+	EnabledAt    *time.Time
+}
+
+// Enabled reports whether logins require a second factor
+func (t *TwoFactor) Enabled() bool {
+	return t.EnabledAt != nil
+}
+
+// TwoFactorStatus tells a user whether two-factor authentication is enabled
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// TwoFactorEnrollment is the secret a user adds to their authenticator app,
+// both as text and as an otpauth:// URI for QR codes
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
@@ -8,11 +8,17 @@ import (
 
 // User represents a chat user
 type User struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Password  string    `json:"-"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          string     `json:"id"`
+	Username    string     `json:"username"`
+	Password    string     `json:"-"`
+	Role        string     `json:"role"`
+	DisplayName string     `json:"display_name"`
+	AvatarKey   string     `json:"-"` // storage key of the resized avatar image
+	StatusText  string     `json:"status_text"`
+	TimeZone    string     `json:"time_zone"`
+	DisabledAt  *time.Time `json:"disabled_at,omitempty"` // set while an administrator has disabled the account
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
 }
 
 // NewUser creates a new user with hashed password
@@ -27,6 +33,7 @@ func NewUser(username, password string) (*User, error) {
 		ID:        "",
 		Username:  username,
 		Password:  string(hashedPassword),
+		Role:      RoleUser,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}, nil
@@ -37,3 +44,41 @@ func (u *User) CheckPassword(password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 	return err == nil
 }
+
+// Disabled reports whether the account has been disabled by an administrator
+func (u *User) Disabled() bool {
+	return u.DisabledAt != nil
+}
+
+// Can reports whether the user's global role grants a permission
+func (u *User) Can(permission Permission) bool {
+	role := u.Role
+	if role == "" {
+		role = RoleUser
+	}
+	return RoleHasPermission(role, permission)
+}
+
+// Profile returns the public profile of the user
+func (u *User) Profile() *Profile {
+	return &Profile{
+		UserID:      u.ID,
+		Username:    u.Username,
+		DisplayName: u.DisplayName,
+		AvatarURL:   AvatarURL(u.ID, u.AvatarKey),
+		StatusText:  u.StatusText,
+		TimeZone:    u.TimeZone,
+	}
+}
+
+// Global roles of users
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+	RoleBot   = "bot"
+)
+
+// IsValidRole reports whether role is one of the global roles
+func IsValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleUser || role == RoleBot
+}
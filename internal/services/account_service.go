@@ -0,0 +1,199 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/database"
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/pkg/storage"
+)
+
+var (
+	ErrDeleteNotConfirmed = errors.New("type your username to confirm deleting your account")
+	ErrLastAdmin          = errors.New("the last administrator can't delete their account")
+	ErrAccountProtected   = errors.New("this account can't be deleted")
+)
+
+// AccountService handles exporting and deleting a user's own account
+type AccountService struct {
+	userRepo       *database.UserRepository
+	chatroomRepo   *database.ChatroomRepository
+	messageRepo    *database.MessageRepository
+	attachmentRepo *database.AttachmentRepository
+	sessionRepo    *database.SessionRepository
+	tokenRepo      *database.AccessTokenRepository
+	scheduleRepo   *database.ScheduledMessageRepository
+	storage        storage.Storage
+	auditService   *AuditService
+}
+
+// NewAccountService creates a new account service. The storage holds the
+// avatars and attachments included in exports and removed on deletion.
+func NewAccountService(db *sql.DB, store storage.Storage, auditService *AuditService) *AccountService {
+	return &AccountService{
+		userRepo:       database.NewUserRepository(db),
+		chatroomRepo:   database.NewChatroomRepository(db),
+		messageRepo:    database.NewMessageRepository(db),
+		attachmentRepo: database.NewAttachmentRepository(db),
+		sessionRepo:    database.NewSessionRepository(db),
+		tokenRepo:      database.NewAccessTokenRepository(db),
+		scheduleRepo:   database.NewScheduledMessageRepository(db),
+		storage:        store,
+		auditService:   auditService,
+	}
+}
+
+// Export collects everything stored about a user
+func (s *AccountService) Export(user *models.User) (*models.AccountExport, error) {
+	export := &models.AccountExport{
+		ExportedAt: time.Now(),
+		Account:    user,
+		Profile:    user.Profile(),
+	}
+
+	var err error
+	if export.Memberships, err = s.chatroomRepo.GetMemberships(user.ID); err != nil {
+		return nil, err
+	}
+	if export.Messages, err = s.messageRepo.GetByUserID(user.ID); err != nil {
+		return nil, err
+	}
+	if export.Attachments, err = s.attachmentRepo.GetByUserID(user.ID); err != nil {
+		return nil, err
+	}
+	if export.Sessions, err = s.sessionRepo.GetActiveByUserID(user.ID); err != nil {
+		return nil, err
+	}
+	if export.AccessTokens, err = s.tokenRepo.GetByUserID(user.ID); err != nil {
+		return nil, err
+	}
+	if export.ScheduledMessages, err = s.scheduleRepo.GetByUserID(user.ID); err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range export.Attachments {
+		setAttachmentURL(attachment)
+	}
+
+	// Lists are always present in the export, even when empty
+	if export.Memberships == nil {
+		export.Memberships = []*models.Membership{}
+	}
+	if export.Messages == nil {
+		export.Messages = []*models.Message{}
+	}
+	if export.Attachments == nil {
+		export.Attachments = []*models.Attachment{}
+	}
+	if export.Sessions == nil {
+		export.Sessions = []*models.Session{}
+	}
+	if export.AccessTokens == nil {
+		export.AccessTokens = []*models.AccessToken{}
+	}
+	if export.ScheduledMessages == nil {
+		export.ScheduledMessages = []*models.ScheduledMessage{}
+	}
+
+	return export, nil
+}
+
+// WriteArchive writes an export as a ZIP archive: account.json with the
+// export itself, the avatar and the files of the user's attachments. Files
+// missing from storage are skipped rather than failing the whole archive.
+func (s *AccountService) WriteArchive(w io.Writer, user *models.User, export *models.AccountExport) error {
+	archive := zip.NewWriter(w)
+
+	file, err := archive.Create("account.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(export); err != nil {
+		return err
+	}
+
+	if user.AvatarKey != "" {
+		if err := s.copyToArchive(archive, "avatar.png", user.AvatarKey); err != nil {
+			return err
+		}
+	}
+
+	for _, attachment := range export.Attachments {
+		// Prefix the ID so files with the same name don't collide
+		name := fmt.Sprintf("attachments/%s-%s", attachment.ID, path.Base(attachment.Filename))
+		if err := s.copyToArchive(archive, name, attachment.StorageKey); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// copyToArchive adds a stored object to a ZIP archive
+func (s *AccountService) copyToArchive(archive *zip.Writer, name, key string) error {
+	content, err := s.storage.Get(context.Background(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Skipping missing file %s in data export", key)
+			return nil
+		}
+		return err
+	}
+	defer content.Close()
+
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, content)
+	return err
+}
+
+// Delete removes a user's account. Users with a password confirm with it;
+// single sign-on users, who have none, confirm by typing their username.
+// Their messages stay in the chatrooms, attributed to a deleted user.
+func (s *AccountService) Delete(user *models.User, password, confirmation string) error {
+	if user.ID == database.BotUserID || user.ID == database.DeletedUserID {
+		return ErrAccountProtected
+	}
+
+	if user.Password != "" {
+		if !user.CheckPassword(password) {
+			return ErrWrongPassword
+		}
+	} else if confirmation != user.Username {
+		return ErrDeleteNotConfirmed
+	}
+
+	// Someone has to be left to administer the server
+	storageKeys, err := s.userRepo.Delete(user.ID)
+	if err != nil {
+		if err == database.ErrLastActiveAdmin {
+			return ErrLastAdmin
+		}
+		return err
+	}
+
+	// The rows are gone, so leftover files are only logged
+	for _, key := range storageKeys {
+		if err := s.storage.Delete(context.Background(), key); err != nil {
+			log.Printf("Error deleting file %s of deleted user: %v", key, err)
+		}
+	}
+
+	s.auditService.Record(user, models.AuditUserDeleted, models.AuditTargetUser, user.ID, map[string]string{
+		"username": user.Username,
+	})
+	return nil
+}
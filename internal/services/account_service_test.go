@@ -0,0 +1,113 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/database"
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/pkg/storage"
+)
+
+func TestWriteArchive(t *testing.T) {
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	put := func(key, content string) {
+		if err := store.Put(context.Background(), key, strings.NewReader(content), int64(len(content)), "application/octet-stream"); err != nil {
+			t.Fatalf("Failed to store %s: %v", key, err)
+		}
+	}
+	put("avatars/user-1/avatar.png", "avatar")
+	put("attachments/room-1/file", "report")
+
+	user := &models.User{ID: "user-1", Username: "alice", Password: "hash", AvatarKey: "avatars/user-1/avatar.png"}
+	export := &models.AccountExport{
+		ExportedAt: time.Now(),
+		Account:    user,
+		Profile:    user.Profile(),
+		Attachments: []*models.Attachment{
+			{ID: "a1", Filename: "../report.txt", StorageKey: "attachments/room-1/file"},
+			{ID: "a2", Filename: "missing.txt", StorageKey: "attachments/room-1/missing"},
+		},
+	}
+
+	service := &AccountService{storage: store}
+	var buf bytes.Buffer
+	if err := service.WriteArchive(&buf, user, export); err != nil {
+		t.Fatalf("Expected archive to be written, got: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Expected a valid ZIP archive, got: %v", err)
+	}
+
+	files := make(map[string]string)
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		content, _ := io.ReadAll(reader)
+		reader.Close()
+		files[file.Name] = string(content)
+	}
+
+	if len(files) != 3 {
+		t.Errorf("Expected account.json, the avatar and one attachment, got: %v", len(files))
+	}
+	if files["avatar.png"] != "avatar" {
+		t.Errorf("Expected avatar in archive, got: %q", files["avatar.png"])
+	}
+	if files["attachments/a1-report.txt"] != "report" {
+		t.Errorf("Expected attachment named after its ID and base name, got files: %v", files)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(files["account.json"]), &decoded); err != nil {
+		t.Fatalf("Expected account.json to be valid JSON, got: %v", err)
+	}
+	if strings.Contains(files["account.json"], "hash") || strings.Contains(files["account.json"], "storage_key") {
+		t.Errorf("Expected password and storage keys to be left out, got: %s", files["account.json"])
+	}
+}
+
+func TestDeleteConfirmation(t *testing.T) {
+	passwordUser, err := models.NewUser("alice", "correct horse")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	ssoUser := &models.User{ID: "user-2", Username: "bob"}
+
+	testCases := []struct {
+		name         string
+		user         *models.User
+		password     string
+		confirmation string
+		err          error
+	}{
+		{"Wrong password", passwordUser, "wrong", "", ErrWrongPassword},
+		{"Username instead of password", passwordUser, "", "alice", ErrWrongPassword},
+		{"Single sign-on user without confirmation", ssoUser, "", "", ErrDeleteNotConfirmed},
+		{"Single sign-on user with wrong username", ssoUser, "", "Bob", ErrDeleteNotConfirmed},
+		{"Bot", &models.User{ID: database.BotUserID, Username: "Stock Bot"}, "", "Stock Bot", ErrAccountProtected},
+		{"Deleted user placeholder", &models.User{ID: database.DeletedUserID, Username: database.DeletedUsername}, "", database.DeletedUsername, ErrAccountProtected},
+	}
+
+	service := &AccountService{}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := service.Delete(tc.user, tc.password, tc.confirmation); err != tc.err {
+				t.Errorf("Expected error %v, got: %v", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/database"
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// Number of users returned per page of the user list
+const (
+	DefaultUserPageSize = 50
+	MaxUserPageSize     = 200
+)
+
+var (
+	ErrInvalidRole      = errors.New("role must be admin, user or bot")
+	ErrCannotModifySelf = errors.New("administrators can't disable or demote themselves")
+)
+
+// AdminService handles user administration by global administrators
+type AdminService struct {
+	userRepo     *database.UserRepository
+	sessionRepo  *database.SessionRepository
+	auditService *AuditService
+}
+
+// NewAdminService creates a new admin service
+func NewAdminService(db *sql.DB, auditService *AuditService) *AdminService {
+	return &AdminService{
+		userRepo:     database.NewUserRepository(db),
+		sessionRepo:  database.NewSessionRepository(db),
+		auditService: auditService,
+	}
+}
+
+// ListUsers returns a page of users ordered by username
+func (s *AdminService) ListUsers(actor *models.User, limit, offset int) ([]*models.User, error) {
+	if err := authorize(actor, models.PermissionManageUsers); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = DefaultUserPageSize
+	}
+	if limit > MaxUserPageSize {
+		limit = MaxUserPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	users, err := s.userRepo.GetAll(limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	if users == nil {
+		users = []*models.User{}
+	}
+	return users, nil
+}
+
+// SetRole changes the global role of a user
+func (s *AdminService) SetRole(actor *models.User, userID, role string) (*models.User, error) {
+	if err := authorize(actor, models.PermissionManageUsers); err != nil {
+		return nil, err
+	}
+	if !models.IsValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+
+	// Keep at least the acting administrator able to undo mistakes
+	if userID == actor.ID && role != models.RoleAdmin {
+		return nil, ErrCannotModifySelf
+	}
+
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == role {
+		return user, nil
+	}
+
+	if err := s.userRepo.UpdateRole(user.ID, role); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(actor, models.AuditUserRoleChanged, models.AuditTargetUser, user.ID, map[string]string{
+		"username": user.Username,
+		"from":     user.Role,
+		"to":       role,
+	})
+	user.Role = role
+	return user, nil
+}
+
+// DisableUser disables a user's account and ends their sessions. Disabled
+// users can't log in, and their access tokens stop working.
+func (s *AdminService) DisableUser(actor *models.User, userID string) (*models.User, error) {
+	if err := authorize(actor, models.PermissionManageUsers); err != nil {
+		return nil, err
+	}
+	if userID == actor.ID {
+		return nil, ErrCannotModifySelf
+	}
+
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled() {
+		return user, nil
+	}
+
+	now := time.Now()
+	if err := s.userRepo.SetDisabled(user.ID, &now); err != nil {
+		return nil, err
+	}
+	if _, err := s.sessionRepo.RevokeAllByUserID(user.ID, ""); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(actor, models.AuditUserDisabled, models.AuditTargetUser, user.ID, map[string]string{
+		"username": user.Username,
+	})
+	user.DisabledAt = &now
+	return user, nil
+}
+
+// EnableUser lets a disabled user log in again
+func (s *AdminService) EnableUser(actor *models.User, userID string) (*models.User, error) {
+	if err := authorize(actor, models.PermissionManageUsers); err != nil {
+		return nil, err
+	}
+
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Disabled() {
+		return user, nil
+	}
+
+	if err := s.userRepo.SetDisabled(user.ID, nil); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(actor, models.AuditUserEnabled, models.AuditTargetUser, user.ID, map[string]string{
+		"username": user.Username,
+	})
+	user.DisabledAt = nil
+	return user, nil
+}
+
+// getUser loads a user, returning ErrUserNotFound if they don't exist
+func (s *AdminService) getUser(userID string) (*models.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/database"
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/pkg/storage"
+)
+
+// Maximum number of attachments on a single message
+const MaxAttachmentsPerMessage = 10
+
+// DefaultUnsentAttachmentTTL is how long uploads that were never sent with a
+// message are kept by default
+const DefaultUnsentAttachmentTTL = 24 * time.Hour
+
+var (
+	ErrAttachmentTooLarge       = errors.New("attachment is too large")
+	ErrAttachmentTypeNotAllowed = errors.New("attachment type is not allowed")
+	ErrAttachmentNotFound       = errors.New("attachment not found")
+	ErrInvalidAttachment        = errors.New("invalid attachment")
+)
+
+// AttachmentService handles file uploads and downloads
+type AttachmentService struct {
+	attachmentRepo  *database.AttachmentRepository
+	chatroomService *ChatroomService
+	storage         storage.Storage
+	maxSize         int64
+	allowedTypes    map[string]bool
+	unsentTTL       time.Duration
+}
+
+// NewAttachmentService creates a new attachment service that accepts files
+// up to maxSize bytes whose detected MIME type is in allowedTypes. Uploads
+// that aren't sent with a message within unsentTTL are deleted by StartCleanup.
+func NewAttachmentService(db *sql.DB, chatroomService *ChatroomService, store storage.Storage, maxSize int64, allowedTypes []string, unsentTTL time.Duration) *AttachmentService {
+	if unsentTTL <= 0 {
+		unsentTTL = DefaultUnsentAttachmentTTL
+	}
+
+	allowed := make(map[string]bool)
+	for _, contentType := range allowedTypes {
+		allowed[strings.ToLower(contentType)] = true
+	}
+
+	return &AttachmentService{
+		attachmentRepo:  database.NewAttachmentRepository(db),
+		chatroomService: chatroomService,
+		storage:         store,
+		maxSize:         maxSize,
+		allowedTypes:    allowed,
+		unsentTTL:       unsentTTL,
+	}
+}
+
+// MaxSize returns the maximum accepted attachment size in bytes
+func (s *AttachmentService) MaxSize() int64 {
+	return s.maxSize
+}
+
+// Upload validates and stores a file in a chatroom. The attachment isn't
+// visible to anyone until it's sent along with a message.
+func (s *AttachmentService) Upload(userID, chatroomID, filename string, r io.Reader, size int64) (*models.Attachment, error) {
+	canRead, err := s.chatroomService.CanRead(userID, chatroomID)
+	if err != nil {
+		return nil, err
+	}
+	if !canRead {
+		return nil, ErrChatroomNotFound
+	}
+	if err := s.chatroomService.CheckWritable(chatroomID); err != nil {
+		return nil, err
+	}
+
+	if size > s.maxSize {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	// Detect the type from the content rather than trusting the client
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	contentType, err := s.detectContentType(head)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := newStorageKey(chatroomID)
+	if err != nil {
+		return nil, err
+	}
+
+	content := io.MultiReader(bytes.NewReader(head), r)
+	if err := s.storage.Put(context.Background(), key, content, size, contentType); err != nil {
+		return nil, err
+	}
+
+	attachment := models.NewAttachment(userID, chatroomID, sanitizeFilename(filename), contentType, size, key)
+	if err := s.attachmentRepo.Create(attachment); err != nil {
+		// Don't leave orphaned files behind
+		if deleteErr := s.storage.Delete(context.Background(), key); deleteErr != nil {
+			log.Printf("Error deleting orphaned attachment %s: %v", key, deleteErr)
+		}
+		return nil, err
+	}
+
+	setAttachmentURL(attachment)
+	return attachment, nil
+}
+
+// Open returns an attachment and its content if the user can read its chatroom.
+// The caller must close the returned reader.
+func (s *AttachmentService) Open(userID, attachmentID string) (*models.Attachment, io.ReadCloser, error) {
+	attachment, err := s.attachmentRepo.GetByID(attachmentID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return nil, nil, ErrAttachmentNotFound
+		}
+		return nil, nil, err
+	}
+
+	// Unsent uploads are only visible to their uploader
+	if attachment.MessageID == "" && attachment.UserID != userID {
+		return nil, nil, ErrAttachmentNotFound
+	}
+
+	canRead, err := s.chatroomService.CanRead(userID, attachment.ChatroomID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !canRead {
+		return nil, nil, ErrAttachmentNotFound
+	}
+
+	content, err := s.storage.Get(context.Background(), attachment.StorageKey)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, nil, ErrAttachmentNotFound
+		}
+		return nil, nil, err
+	}
+
+	setAttachmentURL(attachment)
+	return attachment, content, nil
+}
+
+// StartCleanup periodically deletes expired unsent uploads and their files
+// in the background
+func (s *AttachmentService) StartCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			storageKeys, err := s.attachmentRepo.DeleteUnsentBefore(time.Now().Add(-s.unsentTTL))
+			if err != nil {
+				log.Printf("Error deleting unsent attachments: %v", err)
+				continue
+			}
+
+			// The rows are gone, so leftover files are only logged
+			for _, key := range storageKeys {
+				if err := s.storage.Delete(context.Background(), key); err != nil {
+					log.Printf("Error deleting attachment file %s: %v", key, err)
+				}
+			}
+			if len(storageKeys) > 0 {
+				log.Printf("Deleted %d unsent attachments", len(storageKeys))
+			}
+		}
+	}()
+}
+
+// detectContentType sniffs the MIME type of a file and checks it's allowed
+func (s *AttachmentService) detectContentType(head []byte) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return "", ErrAttachmentTypeNotAllowed
+	}
+	if !s.allowedTypes[mediaType] {
+		return "", ErrAttachmentTypeNotAllowed
+	}
+	return mediaType, nil
+}
+
+// setAttachmentURL fills in the authenticated download URL of an attachment
+func setAttachmentURL(attachment *models.Attachment) {
+	attachment.URL = "/api/attachments/" + attachment.ID
+}
+
+// newStorageKey generates an unguessable storage key within a chatroom's prefix
+func newStorageKey(chatroomID string) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return "attachments/" + chatroomID + "/" + hex.EncodeToString(random), nil
+}
+
+// sanitizeFilename keeps only the base name of an uploaded file, without control characters
+func sanitizeFilename(filename string) string {
+	filename = filepath.Base(strings.ReplaceAll(filename, "\\", "/"))
+	filename = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' {
+			return -1
+		}
+		return r
+	}, filename)
+
+	if filename == "" || filename == "." || filename == "/" {
+		return "attachment"
+	}
+	if len(filename) > 255 {
+		filename = filename[:255]
+	}
+	return filename
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"testing"
+)
+
+func TestAttachmentService_DetectContentType(t *testing.T) {
+	service := &AttachmentService{
+		allowedTypes: map[string]bool{
+			"image/png":  true,
+			"text/plain": true,
+		},
+	}
+
+	testCases := []struct {
+		name        string
+		content     []byte
+		contentType string
+		allowed     bool
+	}{
+		{
+			name:        "PNG image",
+			content:     []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"),
+			contentType: "image/png",
+			allowed:     true,
+		},
+		{
+			name:        "Plain text without charset",
+			content:     []byte("just some notes"),
+			contentType: "text/plain",
+			allowed:     true,
+		},
+		{
+			name:    "HTML is rejected",
+			content: []byte("<html><script>alert(1)</script></html>"),
+			allowed: false,
+		},
+		{
+			name:    "Unknown binary is rejected",
+			content: []byte{0x00, 0x01, 0x02, 0x03},
+			allowed: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			contentType, err := service.detectContentType(tc.content)
+			if tc.allowed {
+				if err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+				if contentType != tc.contentType {
+					t.Errorf("Expected %s, got %s", tc.contentType, contentType)
+				}
+			} else if err != ErrAttachmentTypeNotAllowed {
+				t.Errorf("Expected ErrAttachmentTypeNotAllowed, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	testCases := map[string]string{
+		"photo.png":             "photo.png",
+		"../../etc/passwd":      "passwd",
+		"C:\\Users\\me\\cv.pdf": "cv.pdf",
+		"bad\"name\n.txt":       "badname.txt",
+		"":                      "attachment",
+		"/":                     "attachment",
+	}
+
+	for input, expected := range testCases {
+		if result := sanitizeFilename(input); result != expected {
+			t.Errorf("sanitizeFilename(%q): expected %q, got %q", input, expected, result)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/database"
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// Number of audit entries returned per page
+const (
+	DefaultAuditPageSize = 50
+	MaxAuditPageSize     = 200
+)
+
+// AuditService records administrative actions and lets administrators review them
+type AuditService struct {
+	auditRepo *database.AuditRepository
+}
+
+// NewAuditService creates a new audit service
+func NewAuditService(db *sql.DB) *AuditService {
+	return &AuditService{
+		auditRepo: database.NewAuditRepository(db),
+	}
+}
+
+// Record adds an action of a user to the audit log. The action has already
+// happened when it's recorded, so failures are only logged.
+func (s *AuditService) Record(actor *models.User, action, targetType, targetID string, details map[string]string) {
+	entry := models.NewAuditEntry(actor, action, targetType, targetID, details)
+	if err := s.auditRepo.Create(entry); err != nil {
+		log.Printf("Error recording audit entry %s by %s on %s %s: %v", action, actor.Username, targetType, targetID, err)
+	}
+}
+
+// List returns audit entries created before a time, newest first, or the
+// most recent ones when before is zero
+func (s *AuditService) List(actor *models.User, before time.Time, limit int) ([]*models.AuditEntry, error) {
+	if err := authorize(actor, models.PermissionViewAuditLog); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = DefaultAuditPageSize
+	}
+	if limit > MaxAuditPageSize {
+		limit = MaxAuditPageSize
+	}
+
+	entries, err := s.auditRepo.GetRecent(before, limit)
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		entries = []*models.AuditEntry{}
+	}
+	return entries, nil
+}
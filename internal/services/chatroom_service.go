@@ -1,28 +1,98 @@
 package services
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/dbvitor/chat-go/internal/database"
 	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/pkg/storage"
+)
+
+// Maximum lengths of chatroom fields, in characters
+const (
+	MaxChatroomNameLength = 50
+	MaxTopicLength        = 250
+	MaxDescriptionLength  = 1000
+)
+
+// Default number of chatrooms a user may create
+const DefaultChatroomCreateLimit = 10
+
+// Chatroom listing pagination limits
+const (
+	DefaultChatroomPageSize = 50
+	MaxChatroomPageSize     = 100
+)
+
+// Maximum length of the message previews in chatroom listings, in characters
+const MaxMessagePreviewLength = 100
+
+var (
+	// ErrChatroomNotFound is returned when a chatroom doesn't exist or the user can't access it
+	ErrChatroomNotFound     = errors.New("chatroom not found")
+	ErrInvalidChatroomName  = errors.New("chatroom name must be between 1 and 50 characters")
+	ErrChatroomNameTaken    = errors.New("a chatroom with this name already exists")
+	ErrChatroomLimitReached = errors.New("you have reached the maximum number of chatrooms you can create")
+	ErrInvalidTopic         = errors.New("topic must be a single line of at most 250 characters")
+	ErrInvalidDescription   = errors.New("description must be at most 1000 characters")
+	ErrChatroomArchived     = errors.New("this chatroom is archived and read-only")
+	ErrInvalidChatroomSort  = errors.New("sort must be one of name, activity or members")
 )
 
 // ChatroomService handles chatroom-related business logic
 type ChatroomService struct {
 	chatroomRepo *database.ChatroomRepository
+	storage      storage.Storage
+	auditService *AuditService
+	createLimit  int
 }
 
-// NewChatroomService creates a new chatroom service
-func NewChatroomService(db *sql.DB) *ChatroomService {
+// NewChatroomService creates a new chatroom service. Users without the
+// rooms.manage permission may create up to createLimit chatrooms; zero or
+// less means no limit. The storage holds the files of deleted rooms' attachments.
+func NewChatroomService(db *sql.DB, store storage.Storage, auditService *AuditService, createLimit int) *ChatroomService {
 	return &ChatroomService{
 		chatroomRepo: database.NewChatroomRepository(db),
+		storage:      store,
+		auditService: auditService,
+		createLimit:  createLimit,
 	}
 }
 
-// Create creates a new chatroom
-func (s *ChatroomService) Create(name string) (*models.Chatroom, error) {
+// Create creates a new chatroom on behalf of a user
+func (s *ChatroomService) Create(actor *models.User, name string) (*models.Chatroom, error) {
+	if err := authorize(actor, models.PermissionCreateRooms); err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if !validChatroomName(name) {
+		return nil, ErrInvalidChatroomName
+	}
+	if err := s.checkNameAvailable(name); err != nil {
+		return nil, err
+	}
+
+	// Room managers aren't limited
+	if s.createLimit > 0 && !actor.Can(models.PermissionManageRooms) {
+		count, err := s.chatroomRepo.CountByCreator(actor.ID)
+		if err != nil {
+			return nil, err
+		}
+		if count >= s.createLimit {
+			return nil, ErrChatroomLimitReached
+		}
+	}
+
 	// Create new chatroom
-	chatroom := models.NewChatroom(name)
+	chatroom := models.NewChatroom(name, actor.ID)
 
 	// Save chatroom to database
 	err := s.chatroomRepo.Create(chatroom)
@@ -30,9 +100,198 @@ func (s *ChatroomService) Create(name string) (*models.Chatroom, error) {
 		return nil, err
 	}
 
+	s.auditService.Record(actor, models.AuditChatroomCreated, models.AuditTargetChatroom, chatroom.ID, map[string]string{
+		"name": chatroom.Name,
+	})
+	return chatroom, nil
+}
+
+// Update renames a chatroom or changes its topic or description
+func (s *ChatroomService) Update(actor *models.User, id string, update *models.ChatroomUpdate) (*models.Chatroom, error) {
+	chatroom, err := s.getManaged(actor, id)
+	if err != nil {
+		return nil, err
+	}
+
+	previousName := chatroom.Name
+	if err := applyChatroomUpdate(chatroom, update); err != nil {
+		return nil, err
+	}
+	if chatroom.Name != previousName {
+		if err := s.checkNameAvailable(chatroom.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.chatroomRepo.Update(chatroom); err != nil {
+		return nil, err
+	}
+
+	details := map[string]string{"name": chatroom.Name}
+	if chatroom.Name != previousName {
+		details["previous_name"] = previousName
+	}
+	s.auditService.Record(actor, models.AuditChatroomUpdated, models.AuditTargetChatroom, chatroom.ID, details)
+	return chatroom, nil
+}
+
+// Archive makes a chatroom read-only; its history stays readable
+func (s *ChatroomService) Archive(actor *models.User, id string) (*models.Chatroom, error) {
+	chatroom, err := s.getManaged(actor, id)
+	if err != nil {
+		return nil, err
+	}
+	if chatroom.Archived() {
+		return chatroom, nil
+	}
+
+	now := time.Now()
+	if err := s.chatroomRepo.SetArchived(chatroom.ID, &now); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(actor, models.AuditChatroomArchived, models.AuditTargetChatroom, chatroom.ID, map[string]string{
+		"name": chatroom.Name,
+	})
+	chatroom.ArchivedAt = &now
 	return chatroom, nil
 }
 
+// Unarchive lets users post in an archived chatroom again
+func (s *ChatroomService) Unarchive(actor *models.User, id string) (*models.Chatroom, error) {
+	chatroom, err := s.getManaged(actor, id)
+	if err != nil {
+		return nil, err
+	}
+	if !chatroom.Archived() {
+		return chatroom, nil
+	}
+
+	if err := s.chatroomRepo.SetArchived(chatroom.ID, nil); err != nil {
+		return nil, err
+	}
+
+	s.auditService.Record(actor, models.AuditChatroomRestored, models.AuditTargetChatroom, chatroom.ID, map[string]string{
+		"name": chatroom.Name,
+	})
+	chatroom.ArchivedAt = nil
+	return chatroom, nil
+}
+
+// Delete removes a chatroom with all of its messages and attachments
+func (s *ChatroomService) Delete(actor *models.User, id string) (*models.Chatroom, error) {
+	chatroom, err := s.getManaged(actor, id)
+	if err != nil {
+		return nil, err
+	}
+
+	storageKeys, err := s.chatroomRepo.Delete(chatroom.ID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return nil, ErrChatroomNotFound
+		}
+		return nil, err
+	}
+
+	// The rows are gone, so leftover files are only logged
+	for _, key := range storageKeys {
+		if err := s.storage.Delete(context.Background(), key); err != nil {
+			log.Printf("Error deleting attachment file %s: %v", key, err)
+		}
+	}
+
+	s.auditService.Record(actor, models.AuditChatroomDeleted, models.AuditTargetChatroom, chatroom.ID, map[string]string{
+		"name": chatroom.Name,
+	})
+	return chatroom, nil
+}
+
+// CanManage reports whether a user may change, archive and delete a
+// chatroom: its creator and users with the rooms.manage permission can
+func CanManage(actor *models.User, chatroom *models.Chatroom) bool {
+	if actor == nil || actor.Disabled() {
+		return false
+	}
+	return actor.Can(models.PermissionManageRooms) || (chatroom.CreatedBy != "" && chatroom.CreatedBy == actor.ID)
+}
+
+// getManaged loads a chatroom the user may manage
+func (s *ChatroomService) getManaged(actor *models.User, id string) (*models.Chatroom, error) {
+	chatroom, err := s.chatroomRepo.GetByID(id)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return nil, ErrChatroomNotFound
+		}
+		return nil, err
+	}
+
+	if !CanManage(actor, chatroom) {
+		return nil, ErrPermissionDenied
+	}
+	return chatroom, nil
+}
+
+// checkNameAvailable returns ErrChatroomNameTaken if a chatroom already has the name
+func (s *ChatroomService) checkNameAvailable(name string) error {
+	_, err := s.chatroomRepo.GetByName(name)
+	if err == nil {
+		return ErrChatroomNameTaken
+	}
+	if !database.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// applyChatroomUpdate validates and applies the set fields of an update
+func applyChatroomUpdate(chatroom *models.Chatroom, update *models.ChatroomUpdate) error {
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if !validChatroomName(name) {
+			return ErrInvalidChatroomName
+		}
+		chatroom.Name = name
+	}
+
+	if update.Topic != nil {
+		topic := strings.TrimSpace(*update.Topic)
+		if !validProfileText(topic, MaxTopicLength) {
+			return ErrInvalidTopic
+		}
+		chatroom.Topic = topic
+	}
+
+	if update.Description != nil {
+		description := strings.TrimSpace(*update.Description)
+		if !validMultilineText(description, MaxDescriptionLength) {
+			return ErrInvalidDescription
+		}
+		chatroom.Description = description
+	}
+
+	return nil
+}
+
+// validChatroomName reports whether name is a non-empty single line of at
+// most MaxChatroomNameLength characters
+func validChatroomName(name string) bool {
+	return name != "" && validProfileText(name, MaxChatroomNameLength)
+}
+
+// validMultilineText reports whether text is valid UTF-8 of at most
+// maxLength characters without control characters other than line breaks and tabs
+func validMultilineText(text string, maxLength int) bool {
+	if !utf8.ValidString(text) || utf8.RuneCountInString(text) > maxLength {
+		return false
+	}
+	for _, r := range text {
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
 // GetByID retrieves a chatroom by ID
 func (s *ChatroomService) GetByID(id string) (*models.Chatroom, error) {
 	return s.chatroomRepo.GetByID(id)
@@ -43,7 +302,103 @@ func (s *ChatroomService) GetByName(name string) (*models.Chatroom, error) {
 	return s.chatroomRepo.GetByName(name)
 }
 
-// GetAll retrieves all chatrooms
-func (s *ChatroomService) GetAll() ([]*models.Chatroom, error) {
-	return s.chatroomRepo.GetAll()
+// List retrieves a page of chatrooms with their summaries, ordered by name
+// unless the query says otherwise. It fetches one extra chatroom so callers
+// can tell whether there is a next page.
+func (s *ChatroomService) List(query models.ChatroomQuery) (summaries []*models.ChatroomSummary, hasMore bool, err error) {
+	query, err = normalizeChatroomQuery(query)
+	if err != nil {
+		return nil, false, err
+	}
+
+	limit := query.Limit
+	query.Limit++
+
+	// Fetch more than a preview holds, leaving room for whitespace that is
+	// collapsed and telling whether the message was cut
+	summaries, err = s.chatroomRepo.GetSummaries(query, 2*MaxMessagePreviewLength)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(summaries) > limit {
+		summaries = summaries[:limit]
+		hasMore = true
+	}
+
+	for _, summary := range summaries {
+		if summary.LastMessage != nil {
+			summary.LastMessage.Content = previewText(summary.LastMessage.Content, MaxMessagePreviewLength)
+		}
+	}
+
+	return summaries, hasMore, nil
+}
+
+// normalizeChatroomQuery applies the defaults and limits of chatroom listings
+func normalizeChatroomQuery(query models.ChatroomQuery) (models.ChatroomQuery, error) {
+	query.Name = strings.TrimSpace(query.Name)
+
+	switch query.Sort {
+	case "":
+		query.Sort = models.ChatroomSortName
+	case models.ChatroomSortName, models.ChatroomSortActivity, models.ChatroomSortMembers:
+	default:
+		return query, ErrInvalidChatroomSort
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = DefaultChatroomPageSize
+	}
+	if query.Limit > MaxChatroomPageSize {
+		query.Limit = MaxChatroomPageSize
+	}
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+
+	return query, nil
+}
+
+// previewText puts a message on a single line and cuts it to maxLength
+// characters, marking the cut with an ellipsis
+func previewText(content string, maxLength int) string {
+	text := strings.Join(strings.Fields(content), " ")
+	if utf8.RuneCountInString(text) <= maxLength {
+		return text
+	}
+
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:maxLength-1])) + "…"
+}
+
+// CanRead reports whether a user may read the messages of a chatroom.
+// Every chatroom is currently open to all authenticated users.
+func (s *ChatroomService) CanRead(userID, chatroomID string) (bool, error) {
+	_, err := s.chatroomRepo.GetByID(chatroomID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// CheckWritable returns an error unless users may post in a chatroom:
+// ErrChatroomNotFound if it doesn't exist and ErrChatroomArchived if it's archived
+func (s *ChatroomService) CheckWritable(chatroomID string) error {
+	chatroom, err := s.chatroomRepo.GetByID(chatroomID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return ErrChatroomNotFound
+		}
+		return err
+	}
+
+	if chatroom.Archived() {
+		return ErrChatroomArchived
+	}
+	return nil
 }
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+func TestApplyChatroomUpdate(t *testing.T) {
+	text := func(s string) *string { return &s }
+
+	testCases := []struct {
+		name   string
+		update models.ChatroomUpdate
+		err    error
+		want   models.Chatroom
+	}{
+		{
+			name:   "All fields",
+			update: models.ChatroomUpdate{Name: text(" Random "), Topic: text(" Anything goes "), Description: text("Line one\nLine two")},
+			want:   models.Chatroom{Name: "Random", Topic: "Anything goes", Description: "Line one\nLine two"},
+		},
+		{
+			name:   "Unset fields are kept",
+			update: models.ChatroomUpdate{Topic: text("New topic")},
+			want:   models.Chatroom{Name: "General", Topic: "New topic", Description: "Old description"},
+		},
+		{
+			name:   "Topic and description can be cleared",
+			update: models.ChatroomUpdate{Topic: text(""), Description: text("")},
+			want:   models.Chatroom{Name: "General"},
+		},
+		{
+			name:   "Empty name",
+			update: models.ChatroomUpdate{Name: text("   ")},
+			err:    ErrInvalidChatroomName,
+		},
+		{
+			name:   "Name too long",
+			update: models.ChatroomUpdate{Name: text(strings.Repeat("a", MaxChatroomNameLength+1))},
+			err:    ErrInvalidChatroomName,
+		},
+		{
+			name:   "Topic with a line break",
+			update: models.ChatroomUpdate{Topic: text("One\nTwo")},
+			err:    ErrInvalidTopic,
+		},
+		{
+			name:   "Description too long",
+			update: models.ChatroomUpdate{Description: text(strings.Repeat("a", MaxDescriptionLength+1))},
+			err:    ErrInvalidDescription,
+		},
+		{
+			name:   "Description with control characters",
+			update: models.ChatroomUpdate{Description: text("Bell\a")},
+			err:    ErrInvalidDescription,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			chatroom := &models.Chatroom{Name: "General", Topic: "Old topic", Description: "Old description"}
+			err := applyChatroomUpdate(chatroom, &tc.update)
+			if err != tc.err {
+				t.Fatalf("Expected error %v, got: %v", tc.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if chatroom.Name != tc.want.Name || chatroom.Topic != tc.want.Topic || chatroom.Description != tc.want.Description {
+				t.Errorf("Expected %+v, got: %+v", tc.want, *chatroom)
+			}
+		})
+	}
+}
+
+func TestCanManage(t *testing.T) {
+	disabledAt := time.Now()
+	chatroom := &models.Chatroom{ID: "room-1", CreatedBy: "owner"}
+	legacyChatroom := &models.Chatroom{ID: "room-2"}
+
+	testCases := []struct {
+		name     string
+		user     *models.User
+		chatroom *models.Chatroom
+		expected bool
+	}{
+		{"Creator", &models.User{ID: "owner", Role: models.RoleUser}, chatroom, true},
+		{"Other user", &models.User{ID: "other", Role: models.RoleUser}, chatroom, false},
+		{"Admin", &models.User{ID: "admin", Role: models.RoleAdmin}, chatroom, true},
+		{"Disabled creator", &models.User{ID: "owner", Role: models.RoleUser, DisabledAt: &disabledAt}, chatroom, false},
+		{"User and room without creator", &models.User{ID: "", Role: models.RoleUser}, legacyChatroom, false},
+		{"Admin and room without creator", &models.User{ID: "admin", Role: models.RoleAdmin}, legacyChatroom, true},
+		{"No user", nil, chatroom, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := CanManage(tc.user, tc.chatroom); got != tc.expected {
+				t.Errorf("Expected %v, got: %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestNormalizeChatroomQuery(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query models.ChatroomQuery
+		err   error
+		want  models.ChatroomQuery
+	}{
+		{
+			name:  "Defaults",
+			query: models.ChatroomQuery{},
+			want:  models.ChatroomQuery{Sort: models.ChatroomSortName, Limit: DefaultChatroomPageSize},
+		},
+		{
+			name:  "Limit is capped",
+			query: models.ChatroomQuery{Sort: models.ChatroomSortActivity, Limit: 1000, Offset: 40},
+			want:  models.ChatroomQuery{Sort: models.ChatroomSortActivity, Limit: MaxChatroomPageSize, Offset: 40},
+		},
+		{
+			name:  "Name is trimmed",
+			query: models.ChatroomQuery{Name: "  gen ", Sort: models.ChatroomSortMembers, Limit: 10, Offset: -5},
+			want:  models.ChatroomQuery{Name: "gen", Sort: models.ChatroomSortMembers, Limit: 10},
+		},
+		{
+			name:  "Unknown sort",
+			query: models.ChatroomQuery{Sort: "created_at"},
+			err:   ErrInvalidChatroomSort,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := normalizeChatroomQuery(tc.query)
+			if err != tc.err {
+				t.Fatalf("Expected error %v, got: %v", tc.err, err)
+			}
+			if err == nil && got != tc.want {
+				t.Errorf("Expected %+v, got: %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPreviewText(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"Short message", "hello", "hello"},
+		{"Whitespace is collapsed", " one\n\n  two\t", "one two"},
+		{"Exactly the limit", strings.Repeat("a", 10), strings.Repeat("a", 10)},
+		{"Long message is cut", "one two three four", "one two t…"},
+		{"Multibyte characters", "ééééééééééé", "ééééééééé…"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := previewText(tc.content, 10); got != tc.expected {
+				t.Errorf("Expected %q, got: %q", tc.expected, got)
+			}
+		})
+	}
+}
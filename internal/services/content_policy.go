@@ -0,0 +1,166 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Default limits of the content policy
+const (
+	DefaultMaxMessageLength = 2000 // in characters, not bytes
+	maxConsecutiveNewlines  = 3
+)
+
+var (
+	ErrMessageEmpty   = errors.New("message cannot be empty")
+	ErrMessageTooLong = errors.New("message is too long")
+	ErrMessageBlocked = errors.New("message contains blocked words")
+)
+
+// Filter modes for the blocklist word filter
+const (
+	FilterModeMask   = "mask"
+	FilterModeReject = "reject"
+)
+
+// WordFilter inspects message content after normalization. It can return the
+// content unchanged, return a modified copy (e.g. with words masked), or
+// reject it with an error.
+type WordFilter interface {
+	Filter(content string) (string, error)
+}
+
+// ContentPolicy validates and normalizes chat messages before they are saved
+type ContentPolicy struct {
+	maxLength int
+	filter    WordFilter
+}
+
+// NewContentPolicy creates a content policy. A nil filter lets all words through.
+func NewContentPolicy(maxLength int, filter WordFilter) *ContentPolicy {
+	if maxLength <= 0 {
+		maxLength = DefaultMaxMessageLength
+	}
+	return &ContentPolicy{maxLength: maxLength, filter: filter}
+}
+
+// MaxLength returns the maximum number of characters allowed in a message
+func (p *ContentPolicy) MaxLength() int {
+	return p.maxLength
+}
+
+// Apply normalizes a message and checks it against the policy. Messages
+// carrying attachments may have empty content.
+func (p *ContentPolicy) Apply(content string, allowEmpty bool) (string, error) {
+	content = NormalizeContent(content)
+
+	if content == "" {
+		if allowEmpty {
+			return "", nil
+		}
+		return "", ErrMessageEmpty
+	}
+
+	if length := utf8.RuneCountInString(content); length > p.maxLength {
+		return "", fmt.Errorf("%w (%d characters, maximum is %d)", ErrMessageTooLong, length, p.maxLength)
+	}
+
+	if p.filter != nil {
+		return p.filter.Filter(content)
+	}
+	return content, nil
+}
+
+// NormalizeContent converts text to Unicode NFC, normalizes line endings,
+// removes control characters and trims surrounding whitespace. Long runs of
+// blank lines are collapsed.
+func NormalizeContent(content string) string {
+	if !utf8.ValidString(content) {
+		content = strings.ToValidUTF8(content, "\ufffd")
+	}
+
+	content = norm.NFC.String(content)
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	var b strings.Builder
+	b.Grow(len(content))
+	newlines := 0
+	for _, r := range content {
+		switch {
+		case r == '\n':
+			newlines++
+			if newlines > maxConsecutiveNewlines {
+				continue
+			}
+		case r == '\t':
+			newlines = 0
+		case unicode.IsControl(r), r == '\u200b', r == '\ufeff':
+			// Control characters and zero-width spaces would let an
+			// otherwise blank message through
+			continue
+		default:
+			newlines = 0
+		}
+		b.WriteRune(r)
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// BlocklistFilter masks or rejects messages containing any of a list of words.
+// Matching is case-insensitive and only on whole words.
+type BlocklistFilter struct {
+	pattern *regexp.Regexp
+	mode    string
+}
+
+// NewBlocklistFilter creates a word filter from a blocklist. It returns nil
+// when the blocklist is empty, so it can be passed straight to NewContentPolicy.
+func NewBlocklistFilter(words []string, mode string) WordFilter {
+	var quoted []string
+	for _, word := range words {
+		word = strings.TrimSpace(norm.NFC.String(word))
+		if word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+
+	if mode != FilterModeReject {
+		mode = FilterModeMask
+	}
+
+	return &BlocklistFilter{
+		pattern: regexp.MustCompile(`(?i)(^|[^\pL\pN_])(` + strings.Join(quoted, "|") + `)($|[^\pL\pN_])`),
+		mode:    mode,
+	}
+}
+
+// Filter implements WordFilter
+func (f *BlocklistFilter) Filter(content string) (string, error) {
+	if !f.pattern.MatchString(content) {
+		return content, nil
+	}
+
+	if f.mode == FilterModeReject {
+		return "", ErrMessageBlocked
+	}
+
+	// Adjacent blocked words share the separator between them, so the
+	// second one is only found on a second pass
+	for pass := 0; pass < 2; pass++ {
+		content = f.pattern.ReplaceAllStringFunc(content, func(match string) string {
+			groups := f.pattern.FindStringSubmatch(match)
+			return groups[1] + strings.Repeat("*", utf8.RuneCountInString(groups[2])) + groups[3]
+		})
+	}
+	return content, nil
+}
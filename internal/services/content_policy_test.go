@@ -0,0 +1,151 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeContent(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Surrounding whitespace is trimmed",
+			input:    "  hello \n",
+			expected: "hello",
+		},
+		{
+			name:     "Decomposed characters are composed (NFC)",
+			input:    "cafe\u0301",
+			expected: "caf\u00e9",
+		},
+		{
+			name:     "Windows line endings",
+			input:    "one\r\ntwo",
+			expected: "one\ntwo",
+		},
+		{
+			name:     "Long runs of blank lines are collapsed",
+			input:    "one\n\n\n\n\n\ntwo",
+			expected: "one\n\n\ntwo",
+		},
+		{
+			name:     "Control and zero-width characters are removed",
+			input:    "a\x00b\x1b[31mc\u200b\ufeff",
+			expected: "ab[31mc",
+		},
+		{
+			name:     "Invalid UTF-8 is replaced",
+			input:    "bad \xff byte",
+			expected: "bad \ufffd byte",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := NormalizeContent(tc.input); result != tc.expected {
+				t.Errorf("Expected %q, got: %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestContentPolicy_Apply(t *testing.T) {
+	policy := NewContentPolicy(10, nil)
+
+	testCases := []struct {
+		name       string
+		input      string
+		allowEmpty bool
+		expected   string
+		err        error
+	}{
+		{
+			name:     "Valid message",
+			input:    " hi there ",
+			expected: "hi there",
+		},
+		{
+			name:  "Empty message",
+			input: "",
+			err:   ErrMessageEmpty,
+		},
+		{
+			name:  "Whitespace-only message",
+			input: " \t\n\u200b ",
+			err:   ErrMessageEmpty,
+		},
+		{
+			name:       "Empty message with attachments",
+			input:      "   ",
+			allowEmpty: true,
+			expected:   "",
+		},
+		{
+			name:     "Length is counted in characters, not bytes",
+			input:    "éééééééééé",
+			expected: "éééééééééé",
+		},
+		{
+			name:  "Too long",
+			input: strings.Repeat("a", 11),
+			err:   ErrMessageTooLong,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := policy.Apply(tc.input, tc.allowEmpty)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Expected error %v, got: %v", tc.err, err)
+			}
+			if result != tc.expected {
+				t.Errorf("Expected %q, got: %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestBlocklistFilter(t *testing.T) {
+	if filter := NewBlocklistFilter([]string{" ", ""}, FilterModeMask); filter != nil {
+		t.Errorf("Expected no filter for an empty blocklist")
+	}
+
+	mask := NewBlocklistFilter([]string{"darn", "heck"}, FilterModeMask)
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"well darn it", "well **** it"},
+		{"DARN!", "****!"},
+		{"darn heck", "**** ****"},
+		{"darning and checkout", "darning and checkout"},
+		{"no bad words", "no bad words"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			result, err := mask.Filter(tc.input)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if result != tc.expected {
+				t.Errorf("Expected %q, got: %q", tc.expected, result)
+			}
+		})
+	}
+
+	reject := NewBlocklistFilter([]string{"darn"}, FilterModeReject)
+	if _, err := reject.Filter("oh darn"); !errors.Is(err, ErrMessageBlocked) {
+		t.Errorf("Expected ErrMessageBlocked, got: %v", err)
+	}
+
+	policy := NewContentPolicy(100, reject)
+	if _, err := policy.Apply("  DARN  ", false); !errors.Is(err, ErrMessageBlocked) {
+		t.Errorf("Expected the policy to apply the filter, got: %v", err)
+	}
+}
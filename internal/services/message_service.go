@@ -3,12 +3,14 @@ package services
 import (
 	"database/sql"
 	"fmt"
+	"log"
 	"regexp"
 	"strings"
 
 	"github.com/dbvitor/chat-go/internal/database"
 	"github.com/dbvitor/chat-go/internal/models"
 	"github.com/dbvitor/chat-go/pkg/broker"
+	"github.com/dbvitor/chat-go/pkg/markdown"
 )
 
 // Maximum number of messages to load from history
@@ -17,32 +19,81 @@ const MaxMessages = 50
 // Regex to validate stock quote commands
 var StockCommandPattern = regexp.MustCompile(`^/stock=([A-Za-z0-9.]+)$`)
 
+// Regex to find @username mentions, which must not be part of a word or e-mail address
+var MentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w.-]+)`)
+
+// Maximum number of users notified by a single message
+const MaxMentionsPerMessage = 20
+
+// Regex to find http(s) URLs to build link previews for
+var URLPattern = regexp.MustCompile("https?://[^\\s<>\"'`]+")
+
+// Maximum number of link previews fetched for a single message
+const MaxPreviewsPerMessage = 3
+
 // Service responsible for message operations
 type MessageService struct {
-	messageRepo *database.MessageRepository
-	userRepo    *database.UserRepository
-	rabbitMQ    *broker.RabbitMQ
+	messageRepo      *database.MessageRepository
+	userRepo         *database.UserRepository
+	chatroomRepo     *database.ChatroomRepository
+	attachmentRepo   *database.AttachmentRepository
+	mentionRepo      *database.MentionRepository
+	notificationRepo *database.NotificationRepository
+	linkPreviewRepo  *database.LinkPreviewRepository
+	rabbitMQ         *broker.RabbitMQ
+	contentPolicy    *ContentPolicy
 }
 
 // Creates a new instance of the message service
-func NewMessageService(db *sql.DB, rabbitMQ *broker.RabbitMQ) *MessageService {
+func NewMessageService(db *sql.DB, rabbitMQ *broker.RabbitMQ, contentPolicy *ContentPolicy) *MessageService {
 	return &MessageService{
-		messageRepo: database.NewMessageRepository(db),
-		userRepo:    database.NewUserRepository(db),
-		rabbitMQ:    rabbitMQ,
+		messageRepo:      database.NewMessageRepository(db),
+		userRepo:         database.NewUserRepository(db),
+		chatroomRepo:     database.NewChatroomRepository(db),
+		attachmentRepo:   database.NewAttachmentRepository(db),
+		mentionRepo:      database.NewMentionRepository(db),
+		notificationRepo: database.NewNotificationRepository(db),
+		linkPreviewRepo:  database.NewLinkPreviewRepository(db),
+		rabbitMQ:         rabbitMQ,
+		contentPolicy:    contentPolicy,
 	}
 }
 
-// Creates a new message and saves it to the database, or processes special commands
-func (s *MessageService) CreateMessage(userID, chatroomID, content string) (*models.Message, error) {
+// Creates a new message and saves it to the database, or processes special commands.
+// Attachments previously uploaded by the user to the chatroom can be sent along with it.
+func (s *MessageService) CreateMessage(userID, chatroomID, content string, attachmentIDs ...string) (*models.Message, error) {
+	// Normalize and validate the content; messages with attachments may be empty
+	content, err := s.contentPolicy.Apply(content, len(attachmentIDs) > 0)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get user data
 	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
 		return nil, err
 	}
 
+	// Archived chatrooms are read-only, commands included
+	chatroom, err := s.chatroomRepo.GetByID(chatroomID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return nil, ErrChatroomNotFound
+		}
+		return nil, err
+	}
+	if chatroom.Archived() {
+		return nil, ErrChatroomArchived
+	}
+
+	// Check the attachments before saving anything
+	attachments, err := s.validateAttachments(userID, chatroomID, attachmentIDs)
+	if err != nil {
+		return nil, err
+	}
+
 	// Check if it's a stock command
-	if match := StockCommandPattern.FindStringSubmatch(content); match != nil {
+	if match := StockCommandPattern.FindStringSubmatch(content); match != nil && len(attachments) == 0 {
 		stockCode := match[1]
 
 		// Send request to the stock bot
@@ -57,16 +108,277 @@ func (s *MessageService) CreateMessage(userID, chatroomID, content string) (*mod
 
 	// Create normal message
 	message := models.NewMessage(userID, user.Username, chatroomID, content, models.MessageTypeChat)
+	message.DisplayName = user.DisplayName
+	message.AvatarURL = models.AvatarURL(user.ID, user.AvatarKey)
 
-	// Save to database
-	err = s.messageRepo.Create(message)
+	// Save to database; attachments are linked in the same transaction, so a
+	// message is never sent without them
+	if len(attachments) > 0 {
+		linked, err := s.messageRepo.CreateWithAttachments(message, attachmentIDs)
+		if err != nil {
+			return nil, err
+		}
+		if !linked {
+			return nil, ErrInvalidAttachment
+		}
+		for _, attachment := range attachments {
+			attachment.MessageID = message.ID
+		}
+		message.Attachments = attachments
+	} else if err := s.messageRepo.Create(message); err != nil {
+		return nil, err
+	}
+
+	renderMessages(message)
+
+	// Notify mentioned users; the message is already sent, so failures are only logged
+	mentions, err := s.recordMentions(message)
+	if err != nil {
+		log.Printf("Error recording mentions: %v", err)
+	}
+	message.Mentions = mentions
+
+	// Previews are fetched in the background and pushed to the chatroom once ready
+	s.requestPreviews(message)
+
+	return message, nil
+}
+
+// requestPreviews asks the unfurler worker for previews of the URLs in a message
+func (s *MessageService) requestPreviews(message *models.Message) {
+	for _, url := range ExtractURLs(message.Content) {
+		request := &models.UnfurlRequest{
+			MessageID:  message.ID,
+			ChatroomID: message.ChatroomID,
+			URL:        url,
+		}
+		if err := s.rabbitMQ.PublishUnfurlRequest(request); err != nil {
+			log.Printf("Error requesting link preview: %v", err)
+		}
+	}
+}
+
+// ExtractURLs returns the distinct http(s) URLs in content, up to MaxPreviewsPerMessage
+func ExtractURLs(content string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+
+	for _, match := range URLPattern.FindAllString(content, -1) {
+		url := strings.TrimRight(match, ".,;:!?*")
+
+		// Keep closing parentheses only when they're balanced, e.g. in Wikipedia links
+		for strings.HasSuffix(url, ")") && strings.Count(url, ")") > strings.Count(url, "(") {
+			url = strings.TrimSuffix(url, ")")
+		}
+
+		if seen[url] || len(url) <= len("https://") {
+			continue
+		}
+		seen[url] = true
+		urls = append(urls, url)
+
+		if len(urls) == MaxPreviewsPerMessage {
+			break
+		}
+	}
+
+	return urls
+}
+
+// AddLinkPreview saves a preview produced by the unfurler and returns the
+// updated message, ready to be pushed to the chatroom
+func (s *MessageService) AddLinkPreview(preview *models.LinkPreview) (*models.Message, error) {
+	message, err := s.messageRepo.GetByID(preview.MessageID)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := s.linkPreviewRepo.Create(preview); err != nil {
+		return nil, err
+	}
+
+	messages := []*models.Message{message}
+	if err := s.loadAttachments(messages); err != nil {
+		return nil, err
+	}
+	if err := s.loadMentions(messages); err != nil {
+		return nil, err
+	}
+	if err := s.loadPreviews(messages); err != nil {
+		return nil, err
+	}
+	renderMessages(message)
+
 	return message, nil
 }
 
+// renderMessages fills in the sanitized HTML of messages from their Markdown content
+func renderMessages(messages ...*models.Message) {
+	for _, message := range messages {
+		message.HTML = markdown.Render(message.Content)
+	}
+}
+
+// ParseMentions returns the distinct usernames mentioned with @username in content
+func ParseMentions(content string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+
+	for _, match := range MentionPattern.FindAllStringSubmatch(content, -1) {
+		// Trailing punctuation ends a sentence rather than the username
+		username := strings.TrimRight(match[1], ".-")
+		key := strings.ToLower(username)
+		if username == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		usernames = append(usernames, username)
+
+		if len(usernames) == MaxMentionsPerMessage {
+			break
+		}
+	}
+
+	return usernames
+}
+
+// recordMentions stores the users mentioned in a message and adds the message
+// to their notification feeds. Authors don't get notified about themselves.
+func (s *MessageService) recordMentions(message *models.Message) ([]*models.Mention, error) {
+	usernames := ParseMentions(message.Content)
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	users, err := s.userRepo.GetByUsernames(usernames)
+	if err != nil {
+		return nil, err
+	}
+
+	var mentions []*models.Mention
+	for _, user := range users {
+		if user.ID == message.UserID || user.ID == database.BotUserID {
+			continue
+		}
+
+		mention := &models.Mention{UserID: user.ID, Username: user.Username}
+		if err := s.mentionRepo.Create(message.ID, mention); err != nil {
+			return mentions, err
+		}
+		if err := s.notificationRepo.Create(models.NewMentionNotification(user.ID, message)); err != nil {
+			return mentions, err
+		}
+		mentions = append(mentions, mention)
+	}
+
+	return mentions, nil
+}
+
+// validateAttachments checks that every attachment was uploaded by the user to
+// the chatroom and hasn't been sent yet
+func (s *MessageService) validateAttachments(userID, chatroomID string, attachmentIDs []string) ([]*models.Attachment, error) {
+	if len(attachmentIDs) > MaxAttachmentsPerMessage {
+		return nil, ErrInvalidAttachment
+	}
+
+	seen := make(map[string]bool)
+	attachments := make([]*models.Attachment, 0, len(attachmentIDs))
+	for _, id := range attachmentIDs {
+		if seen[id] {
+			return nil, ErrInvalidAttachment
+		}
+		seen[id] = true
+
+		attachment, err := s.attachmentRepo.GetByID(id)
+		if err != nil {
+			if database.IsNotFound(err) {
+				return nil, ErrInvalidAttachment
+			}
+			return nil, err
+		}
+		if attachment.UserID != userID || attachment.ChatroomID != chatroomID || attachment.MessageID != "" {
+			return nil, ErrInvalidAttachment
+		}
+
+		setAttachmentURL(attachment)
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, nil
+}
+
+// loadAttachments fills in the attachments of a batch of messages
+func (s *MessageService) loadAttachments(messages []*models.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	messageIDs := make([]string, len(messages))
+	for i, message := range messages {
+		messageIDs[i] = message.ID
+	}
+
+	attachments, err := s.attachmentRepo.GetByMessageIDs(messageIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		for _, attachment := range attachments[message.ID] {
+			setAttachmentURL(attachment)
+		}
+		message.Attachments = attachments[message.ID]
+	}
+
+	return nil
+}
+
+// loadMentions fills in the mentions of a batch of messages
+func (s *MessageService) loadMentions(messages []*models.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	messageIDs := make([]string, len(messages))
+	for i, message := range messages {
+		messageIDs[i] = message.ID
+	}
+
+	mentions, err := s.mentionRepo.GetByMessageIDs(messageIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		message.Mentions = mentions[message.ID]
+	}
+
+	return nil
+}
+
+// loadPreviews fills in the link previews of a batch of messages
+func (s *MessageService) loadPreviews(messages []*models.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	messageIDs := make([]string, len(messages))
+	for i, message := range messages {
+		messageIDs[i] = message.ID
+	}
+
+	previews, err := s.linkPreviewRepo.GetByMessageIDs(messageIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		message.Previews = previews[message.ID]
+	}
+
+	return nil
+}
+
 // Creates a message from the stock bot
 func (s *MessageService) CreateBotMessage(chatroomID string, stockResponse *models.StockResponse) (*models.Message, error) {
 	var content string
@@ -88,10 +400,41 @@ func (s *MessageService) CreateBotMessage(chatroomID string, stockResponse *mode
 		return nil, err
 	}
 
+	renderMessages(message)
+
 	return message, nil
 }
 
 // Gets messages for a specific chatroom
 func (s *MessageService) GetMessagesByChatroomID(chatroomID string) ([]*models.Message, error) {
-	return s.messageRepo.GetByChatroomID(chatroomID, MaxMessages)
+	messages, err := s.messageRepo.GetByChatroomID(chatroomID, MaxMessages)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.prepare(messages); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// prepare loads the attachments, mentions and link previews of messages read
+// from the database and renders them for clients
+func (s *MessageService) prepare(messages []*models.Message) error {
+	if err := s.loadAttachments(messages); err != nil {
+		return err
+	}
+
+	if err := s.loadMentions(messages); err != nil {
+		return err
+	}
+
+	if err := s.loadPreviews(messages); err != nil {
+		return err
+	}
+
+	renderMessages(messages...)
+
+	return nil
 }
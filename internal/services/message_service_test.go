@@ -86,3 +86,119 @@ func TestStockCommandRegex(t *testing.T) {
 		})
 	}
 }
+
+func TestParseMentions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "Single mention",
+			input:    "hey @alice, check this",
+			expected: []string{"alice"},
+		},
+		{
+			name:     "Mention at start",
+			input:    "@bob look",
+			expected: []string{"bob"},
+		},
+		{
+			name:     "Multiple mentions",
+			input:    "@alice and @bob_2 please review",
+			expected: []string{"alice", "bob_2"},
+		},
+		{
+			name:     "Duplicates are ignored case-insensitively",
+			input:    "@alice @Alice @ALICE",
+			expected: []string{"alice"},
+		},
+		{
+			name:     "Trailing punctuation is not part of the username",
+			input:    "thanks @alice.",
+			expected: []string{"alice"},
+		},
+		{
+			name:     "Dots inside usernames are kept",
+			input:    "ping @john.doe now",
+			expected: []string{"john.doe"},
+		},
+		{
+			name:     "E-mail addresses are not mentions",
+			input:    "mail me at alice@example.com",
+			expected: nil,
+		},
+		{
+			name:     "Lone at sign",
+			input:    "meet @ 5pm",
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := ParseMentions(tc.input)
+			if len(result) != len(tc.expected) {
+				t.Fatalf("Expected %v, got %v", tc.expected, result)
+			}
+			for i := range result {
+				if result[i] != tc.expected[i] {
+					t.Errorf("Expected %v, got %v", tc.expected, result)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractURLs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "Single URL",
+			input:    "look at https://example.com/post",
+			expected: []string{"https://example.com/post"},
+		},
+		{
+			name:     "Trailing punctuation is not part of the URL",
+			input:    "see http://example.com/a, and https://example.org.",
+			expected: []string{"http://example.com/a", "https://example.org"},
+		},
+		{
+			name:     "Balanced parentheses are kept",
+			input:    "(https://en.wikipedia.org/wiki/Go_(programming_language))",
+			expected: []string{"https://en.wikipedia.org/wiki/Go_(programming_language)"},
+		},
+		{
+			name:     "Duplicates are ignored",
+			input:    "https://example.com https://example.com",
+			expected: []string{"https://example.com"},
+		},
+		{
+			name:     "Limited per message",
+			input:    "https://a.com https://b.com https://c.com https://d.com",
+			expected: []string{"https://a.com", "https://b.com", "https://c.com"},
+		},
+		{
+			name:     "Other schemes are ignored",
+			input:    "ftp://example.com javascript:alert(1) https://",
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := ExtractURLs(tc.input)
+			if len(result) != len(tc.expected) {
+				t.Fatalf("Expected %v, got %v", tc.expected, result)
+			}
+			for i := range result {
+				if result[i] != tc.expected[i] {
+					t.Errorf("Expected %v, got %v", tc.expected, result)
+				}
+			}
+		})
+	}
+}
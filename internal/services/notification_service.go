@@ -0,0 +1,68 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/database"
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// Notification feed pagination limits
+const (
+	DefaultNotificationLimit = 20
+	MaxNotificationLimit     = 100
+)
+
+// ErrNotificationNotFound is returned when a notification doesn't exist or belongs to another user
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// NotificationService handles the per-user notification feed
+type NotificationService struct {
+	notificationRepo *database.NotificationRepository
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService(db *sql.DB) *NotificationService {
+	return &NotificationService{
+		notificationRepo: database.NewNotificationRepository(db),
+	}
+}
+
+// GetFeed retrieves a page of a user's notifications, newest first
+func (s *NotificationService) GetFeed(userID string, unreadOnly bool, before time.Time, limit int) ([]*models.Notification, error) {
+	if limit <= 0 {
+		limit = DefaultNotificationLimit
+	}
+	if limit > MaxNotificationLimit {
+		limit = MaxNotificationLimit
+	}
+
+	return s.notificationRepo.GetByUserID(userID, unreadOnly, before, limit)
+}
+
+// CountUnread returns the number of unread notifications of a user
+func (s *NotificationService) CountUnread(userID string) (int, error) {
+	return s.notificationRepo.CountUnread(userID)
+}
+
+// MarkRead marks one of the user's notifications as read
+func (s *NotificationService) MarkRead(userID, notificationID string) error {
+	found, err := s.notificationRepo.MarkRead(notificationID, userID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return ErrNotificationNotFound
+		}
+		return err
+	}
+	if !found {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
+// MarkAllRead marks all of the user's notifications as read
+func (s *NotificationService) MarkAllRead(userID string) error {
+	return s.notificationRepo.MarkAllRead(userID)
+}
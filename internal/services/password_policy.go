@@ -0,0 +1,82 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Default limits of the password policy
+const (
+	DefaultMinPasswordLength = 8
+	// bcrypt ignores everything after the first 72 bytes
+	maxPasswordBytes = 72
+	// Shortest username a password may not contain
+	minUsernameCheckLength = 3
+)
+
+var ErrWeakPassword = errors.New("password does not meet the requirements")
+
+// PasswordPolicy holds the strength rules passwords must satisfy
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// Validate checks a password against the policy. The error wraps
+// ErrWeakPassword and lists every rule the password breaks.
+func (p *PasswordPolicy) Validate(password, username string) error {
+	var problems []string
+
+	minLength := p.MinLength
+	if minLength <= 0 {
+		minLength = DefaultMinPasswordLength
+	}
+	if utf8.RuneCountInString(password) < minLength {
+		problems = append(problems, fmt.Sprintf("at least %d characters", minLength))
+	}
+	if len(password) > maxPasswordBytes {
+		problems = append(problems, fmt.Sprintf("at most %d bytes", maxPasswordBytes))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r), unicode.IsSymbol(r), unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		problems = append(problems, "an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		problems = append(problems, "a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		problems = append(problems, "a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		problems = append(problems, "a symbol")
+	}
+
+	// Very short usernames would rule out too many passwords
+	if utf8.RuneCountInString(username) >= minUsernameCheckLength && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		problems = append(problems, "not containing the username")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: must have %s", ErrWeakPassword, strings.Join(problems, ", "))
+	}
+	return nil
+}
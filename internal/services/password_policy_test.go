@@ -0,0 +1,108 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	strict := &PasswordPolicy{
+		MinLength:     10,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	}
+
+	testCases := []struct {
+		name     string
+		policy   *PasswordPolicy
+		password string
+		username string
+		problems []string // substrings expected in the error, nil for a valid password
+	}{
+		{
+			name:     "Default policy accepts a long enough password",
+			policy:   &PasswordPolicy{},
+			password: "correcthorse",
+			username: "alice",
+		},
+		{
+			name:     "Default policy rejects a short password",
+			policy:   &PasswordPolicy{},
+			password: "short",
+			username: "alice",
+			problems: []string{"at least 8 characters"},
+		},
+		{
+			name:     "Length is counted in characters",
+			policy:   &PasswordPolicy{MinLength: 4},
+			password: "éééé",
+			username: "alice",
+		},
+		{
+			name:     "Passwords longer than bcrypt supports are rejected",
+			policy:   &PasswordPolicy{},
+			password: strings.Repeat("a", 73),
+			username: "alice",
+			problems: []string{"at most 72 bytes"},
+		},
+		{
+			name:     "Every broken rule is listed",
+			policy:   strict,
+			password: "abc",
+			username: "alice",
+			problems: []string{"at least 10 characters", "an uppercase letter", "a digit", "a symbol"},
+		},
+		{
+			name:     "Strict policy accepts a complex password",
+			policy:   strict,
+			password: "Tr0ub4dor & 3",
+			username: "alice",
+		},
+		{
+			name:     "Password containing the username",
+			policy:   &PasswordPolicy{},
+			password: "xxALICExx99",
+			username: "alice",
+			problems: []string{"not containing the username"},
+		},
+		{
+			name:     "Very short usernames are not checked",
+			policy:   &PasswordPolicy{},
+			password: "banana-split",
+			username: "an",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.policy.Validate(tc.password, tc.username)
+			if tc.problems == nil {
+				if err != nil {
+					t.Errorf("Expected no error, got: %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrWeakPassword) {
+				t.Fatalf("Expected ErrWeakPassword, got: %v", err)
+			}
+			for _, problem := range tc.problems {
+				if !strings.Contains(err.Error(), problem) {
+					t.Errorf("Expected error to mention %q, got: %v", problem, err)
+				}
+			}
+		})
+	}
+}
+
+func TestResetURL(t *testing.T) {
+	service := &PasswordResetService{baseURL: "https://chat.example.com"}
+
+	url := service.ResetURL("abc-_123")
+	if url != "https://chat.example.com/?reset_token=abc-_123" {
+		t.Errorf("Expected reset URL with token, got: %s", url)
+	}
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/database"
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/pkg/auth"
+	"github.com/dbvitor/chat-go/pkg/notify"
+)
+
+// DefaultPasswordResetTTL is how long a password reset link works by default
+const DefaultPasswordResetTTL = time.Hour
+
+// How long sending a reset notification may take
+const resetNotifyTimeout = 30 * time.Second
+
+// ErrInvalidResetToken is returned for reset tokens that don't exist, expired or were already used
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// PasswordResetService issues password reset tokens and redeems them
+type PasswordResetService struct {
+	resetRepo   *database.PasswordResetRepository
+	userRepo    *database.UserRepository
+	userService *UserService
+	notifier    notify.Notifier
+	ttl         time.Duration
+	baseURL     string
+}
+
+// NewPasswordResetService creates a new password reset service. Reset links
+// point to baseURL, the public address of the web client, and expire after ttl.
+func NewPasswordResetService(db *sql.DB, userService *UserService, notifier notify.Notifier, ttl time.Duration, baseURL string) *PasswordResetService {
+	if ttl <= 0 {
+		ttl = DefaultPasswordResetTTL
+	}
+	return &PasswordResetService{
+		resetRepo:   database.NewPasswordResetRepository(db),
+		userRepo:    database.NewUserRepository(db),
+		userService: userService,
+		notifier:    notifier,
+		ttl:         ttl,
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// RequestReset sends a reset link to a user. Unknown usernames are ignored
+// without an error, so the endpoint can't be used to find out which accounts
+// exist; for the same reason the link is sent in the background.
+func (s *PasswordResetService) RequestReset(username string) error {
+	user, err := s.userRepo.GetByUsername(username)
+	if err != nil {
+		if database.IsNotFound(err) {
+			log.Printf("Password reset requested for unknown user %q", username)
+			return nil
+		}
+		return err
+	}
+
+	token, err := generateResetToken()
+	if err != nil {
+		return err
+	}
+
+	// Only the most recent link works
+	if err := s.resetRepo.InvalidateByUserID(user.ID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	reset := &models.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+	if err := s.resetRepo.Create(reset); err != nil {
+		return err
+	}
+
+	message := notify.Message{
+		UserID:    user.ID,
+		Recipient: user.Username,
+		Subject:   "Reset your password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password of your account %s.\n"+
+				"To choose a new password, open this link within %s:\n\n%s\n\n"+
+				"If it wasn't you, ignore this message; your password stays the same.",
+			user.Username, s.ttl, s.ResetURL(token),
+		),
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), resetNotifyTimeout)
+		defer cancel()
+		if err := s.notifier.Send(ctx, message); err != nil {
+			log.Printf("Error sending password reset to user %s: %v", user.ID, err)
+		}
+	}()
+
+	return nil
+}
+
+// ResetPassword sets a new password using a reset token. The token can only
+// be used once, and every session of the user is revoked. It returns the ID
+// of the user.
+func (s *PasswordResetService) ResetPassword(token, password string) (string, error) {
+	reset, err := s.resetRepo.GetByHash(auth.HashToken(token))
+	if err != nil {
+		if database.IsNotFound(err) {
+			return "", ErrInvalidResetToken
+		}
+		return "", err
+	}
+
+	now := time.Now()
+	if !reset.Active(now) {
+		return "", ErrInvalidResetToken
+	}
+
+	// Check the password before using up the token, so the user can try again
+	user, err := s.userRepo.GetByID(reset.UserID)
+	if err != nil {
+		return "", err
+	}
+	if err := s.userService.ValidatePassword(user, password); err != nil {
+		return "", err
+	}
+
+	used, err := s.resetRepo.MarkUsed(reset.ID, now)
+	if err != nil {
+		return "", err
+	}
+	if !used {
+		return "", ErrInvalidResetToken
+	}
+
+	if err := s.userService.SetPassword(user.ID, password, ""); err != nil {
+		return "", err
+	}
+
+	return user.ID, nil
+}
+
+// ResetURL returns the link of the web client that redeems a reset token
+func (s *PasswordResetService) ResetURL(token string) string {
+	return s.baseURL + "/?reset_token=" + url.QueryEscape(token)
+}
+
+// StartCleanup periodically deletes expired password resets in the background
+func (s *PasswordResetService) StartCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			deleted, err := s.resetRepo.DeleteExpired(time.Now())
+			if err != nil {
+				log.Printf("Error deleting expired password resets: %v", err)
+			} else if deleted > 0 {
+				log.Printf("Deleted %d expired password resets", deleted)
+			}
+		}
+	}()
+}
+
+// generateResetToken creates a random, URL-safe reset token
+func generateResetToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
@@ -0,0 +1,20 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// ErrPermissionDenied is returned when a user's role doesn't allow an operation
+var ErrPermissionDenied = errors.New("permission denied")
+
+// authorize checks that the acting user's global role grants a permission.
+// Handlers check permissions before calling services too; services check them
+// again so no caller can skip them.
+func authorize(actor *models.User, permission models.Permission) error {
+	if actor == nil || actor.Disabled() || !actor.Can(permission) {
+		return ErrPermissionDenied
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+func TestAuthorize(t *testing.T) {
+	disabledAt := time.Now()
+
+	testCases := []struct {
+		name       string
+		user       *models.User
+		permission models.Permission
+		allowed    bool
+	}{
+		{"Admin managing users", &models.User{Role: models.RoleAdmin}, models.PermissionManageUsers, true},
+		{"Admin creating rooms", &models.User{Role: models.RoleAdmin}, models.PermissionCreateRooms, true},
+		{"User creating rooms", &models.User{Role: models.RoleUser}, models.PermissionCreateRooms, true},
+		{"User deleting rooms", &models.User{Role: models.RoleUser}, models.PermissionManageRooms, false},
+		{"User viewing audit log", &models.User{Role: models.RoleUser}, models.PermissionViewAuditLog, false},
+		{"User without role", &models.User{}, models.PermissionCreateRooms, true},
+		{"Bot creating rooms", &models.User{Role: models.RoleBot}, models.PermissionCreateRooms, false},
+		{"Disabled admin", &models.User{Role: models.RoleAdmin, DisabledAt: &disabledAt}, models.PermissionManageUsers, false},
+		{"No user", nil, models.PermissionCreateRooms, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := authorize(tc.user, tc.permission)
+			if tc.allowed && err != nil {
+				t.Errorf("Expected permission to be granted, got: %v", err)
+			}
+			if !tc.allowed && err != ErrPermissionDenied {
+				t.Errorf("Expected ErrPermissionDenied, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestPermissionRequiredScope(t *testing.T) {
+	if scope := models.PermissionCreateRooms.RequiredScope(); scope != models.ScopeWrite {
+		t.Errorf("Expected %s to need the write scope, got: %s", models.PermissionCreateRooms, scope)
+	}
+	if scope := models.PermissionManageUsers.RequiredScope(); scope != models.ScopeAdmin {
+		t.Errorf("Expected %s to need the admin scope, got: %s", models.PermissionManageUsers, scope)
+	}
+}
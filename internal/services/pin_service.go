@@ -0,0 +1,173 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+
+	"github.com/dbvitor/chat-go/internal/database"
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// Maximum number of pinned messages in a chatroom
+const MaxPinsPerChatroom = 50
+
+// messageIDPattern matches the UUIDs messages are identified by
+var messageIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+var (
+	ErrMessageNotFound = errors.New("message not found")
+	ErrNotPinned       = errors.New("message is not pinned")
+	ErrPinLimitReached = errors.New("this chatroom has reached the maximum number of pinned messages")
+)
+
+// PinService handles pinning messages to the top of chatrooms. Pins are
+// managed by the room's moderators: the users who may manage the chatroom.
+type PinService struct {
+	pinRepo         *database.PinRepository
+	messageRepo     *database.MessageRepository
+	chatroomService *ChatroomService
+	messageService  *MessageService
+	auditService    *AuditService
+}
+
+// NewPinService creates a new pin service
+func NewPinService(db *sql.DB, chatroomService *ChatroomService, messageService *MessageService, auditService *AuditService) *PinService {
+	return &PinService{
+		pinRepo:         database.NewPinRepository(db),
+		messageRepo:     database.NewMessageRepository(db),
+		chatroomService: chatroomService,
+		messageService:  messageService,
+		auditService:    auditService,
+	}
+}
+
+// Pin pins a message of a chatroom and returns the room's pins. Pinning a
+// message twice has no effect.
+func (s *PinService) Pin(actor *models.User, chatroomID, messageID string) ([]*models.Pin, error) {
+	chatroom, err := s.getEditable(actor, chatroomID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !messageIDPattern.MatchString(messageID) {
+		return nil, ErrMessageNotFound
+	}
+	message, err := s.messageRepo.GetByID(messageID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+
+	count, err := s.pinRepo.CountByChatroomID(chatroom.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPinnable(chatroom, message, count); err != nil {
+		return nil, err
+	}
+
+	pinned, err := s.pinRepo.Create(models.NewPin(chatroom.ID, message.ID, actor.ID))
+	if err != nil {
+		return nil, err
+	}
+	if pinned {
+		s.auditService.Record(actor, models.AuditMessagePinned, models.AuditTargetMessage, message.ID, map[string]string{
+			"chatroom_id": chatroom.ID,
+			"author":      message.Username,
+		})
+	}
+
+	return s.List(chatroom.ID)
+}
+
+// Unpin removes a pinned message from a chatroom and returns the room's pins
+func (s *PinService) Unpin(actor *models.User, chatroomID, messageID string) ([]*models.Pin, error) {
+	chatroom, err := s.getEditable(actor, chatroomID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !messageIDPattern.MatchString(messageID) {
+		return nil, ErrNotPinned
+	}
+	unpinned, err := s.pinRepo.Delete(chatroom.ID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if !unpinned {
+		return nil, ErrNotPinned
+	}
+
+	s.auditService.Record(actor, models.AuditMessageUnpinned, models.AuditTargetMessage, messageID, map[string]string{
+		"chatroom_id": chatroom.ID,
+	})
+	return s.List(chatroom.ID)
+}
+
+// getEditable loads a chatroom whose pins the user may change
+func (s *PinService) getEditable(actor *models.User, chatroomID string) (*models.Chatroom, error) {
+	chatroom, err := s.chatroomService.GetByID(chatroomID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return nil, ErrChatroomNotFound
+		}
+		return nil, err
+	}
+
+	if err := checkPinsEditable(actor, chatroom); err != nil {
+		return nil, err
+	}
+	return chatroom, nil
+}
+
+// checkPinsEditable returns an error unless the user may change the pins of
+// a chatroom: only its moderators may, and only while it isn't archived
+func checkPinsEditable(actor *models.User, chatroom *models.Chatroom) error {
+	if !CanManage(actor, chatroom) {
+		return ErrPermissionDenied
+	}
+	if chatroom.Archived() {
+		return ErrChatroomArchived
+	}
+	return nil
+}
+
+// checkPinnable returns an error unless a message can be pinned to a
+// chatroom that already has pinCount pins
+func checkPinnable(chatroom *models.Chatroom, message *models.Message, pinCount int) error {
+	if message.ChatroomID != chatroom.ID {
+		return ErrMessageNotFound
+	}
+	if pinCount >= MaxPinsPerChatroom {
+		return ErrPinLimitReached
+	}
+	return nil
+}
+
+// List retrieves the pinned messages of a chatroom, most recently pinned first
+func (s *PinService) List(chatroomID string) ([]*models.Pin, error) {
+	if _, err := s.chatroomService.GetByID(chatroomID); err != nil {
+		if database.IsNotFound(err) {
+			return nil, ErrChatroomNotFound
+		}
+		return nil, err
+	}
+
+	pins, err := s.pinRepo.GetByChatroomID(chatroomID)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]*models.Message, len(pins))
+	for i, pin := range pins {
+		messages[i] = pin.Message
+	}
+	if err := s.messageService.prepare(messages); err != nil {
+		return nil, err
+	}
+
+	return pins, nil
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+func TestCheckPinsEditable(t *testing.T) {
+	archivedAt := time.Now()
+	disabledAt := time.Now()
+	chatroom := &models.Chatroom{ID: "room-1", CreatedBy: "owner"}
+	archived := &models.Chatroom{ID: "room-2", CreatedBy: "owner", ArchivedAt: &archivedAt}
+
+	testCases := []struct {
+		name     string
+		user     *models.User
+		chatroom *models.Chatroom
+		err      error
+	}{
+		{"Room creator", &models.User{ID: "owner", Role: models.RoleUser}, chatroom, nil},
+		{"Admin", &models.User{ID: "admin", Role: models.RoleAdmin}, chatroom, nil},
+		{"Other user", &models.User{ID: "other", Role: models.RoleUser}, chatroom, ErrPermissionDenied},
+		{"Disabled creator", &models.User{ID: "owner", Role: models.RoleUser, DisabledAt: &disabledAt}, chatroom, ErrPermissionDenied},
+		{"Archived room", &models.User{ID: "owner", Role: models.RoleUser}, archived, ErrChatroomArchived},
+		{"Other user in archived room", &models.User{ID: "other", Role: models.RoleUser}, archived, ErrPermissionDenied},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := checkPinsEditable(tc.user, tc.chatroom); err != tc.err {
+				t.Errorf("Expected error %v, got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestCheckPinnable(t *testing.T) {
+	chatroom := &models.Chatroom{ID: "room-1"}
+
+	testCases := []struct {
+		name     string
+		message  *models.Message
+		pinCount int
+		err      error
+	}{
+		{"First pin", &models.Message{ID: "m1", ChatroomID: "room-1"}, 0, nil},
+		{"Last pin below the limit", &models.Message{ID: "m1", ChatroomID: "room-1"}, MaxPinsPerChatroom - 1, nil},
+		{"Limit reached", &models.Message{ID: "m1", ChatroomID: "room-1"}, MaxPinsPerChatroom, ErrPinLimitReached},
+		{"Message from another room", &models.Message{ID: "m2", ChatroomID: "room-2"}, 0, ErrMessageNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := checkPinnable(chatroom, tc.message, tc.pinCount); err != tc.err {
+				t.Errorf("Expected error %v, got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestMessageIDPattern(t *testing.T) {
+	testCases := []struct {
+		id       string
+		expected bool
+	}{
+		{"6f1c2a9e-3b4d-4e5f-8a9b-0c1d2e3f4a5b", true},
+		{"6F1C2A9E-3B4D-4E5F-8A9B-0C1D2E3F4A5B", true},
+		{"not-a-uuid", false},
+		{"6f1c2a9e3b4d4e5f8a9b0c1d2e3f4a5b", false},
+		{"", false},
+	}
+
+	for _, tc := range testCases {
+		if got := messageIDPattern.MatchString(tc.id); got != tc.expected {
+			t.Errorf("Expected %v for %q, got: %v", tc.expected, tc.id, got)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// PresenceService tracks which users are connected to each chatroom.
+// A user may have several connections to the same room (e.g. two browser
+// tabs), so join and leave are only reported for the first and last one.
+type PresenceService struct {
+	rooms map[string]map[string]*models.Presence // chatroom ID -> user ID -> presence
+	mutex sync.RWMutex
+}
+
+// NewPresenceService creates a new presence service
+func NewPresenceService() *PresenceService {
+	return &PresenceService{
+		rooms: make(map[string]map[string]*models.Presence),
+	}
+}
+
+// Connect registers a connection and reports whether it is the user's first one in the room
+func (s *PresenceService) Connect(chatroomID, userID, username string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	users, ok := s.rooms[chatroomID]
+	if !ok {
+		users = make(map[string]*models.Presence)
+		s.rooms[chatroomID] = users
+	}
+
+	presence, ok := users[userID]
+	if !ok {
+		presence = &models.Presence{
+			UserID:   userID,
+			Username: username,
+			Since:    time.Now(),
+		}
+		users[userID] = presence
+	}
+	presence.Connections++
+
+	return presence.Connections == 1
+}
+
+// Disconnect removes a connection and reports whether it was the user's last one in the room
+func (s *PresenceService) Disconnect(chatroomID, userID string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	users, ok := s.rooms[chatroomID]
+	if !ok {
+		return false
+	}
+
+	presence, ok := users[userID]
+	if !ok {
+		return false
+	}
+
+	presence.Connections--
+	if presence.Connections > 0 {
+		return false
+	}
+
+	delete(users, userID)
+	if len(users) == 0 {
+		delete(s.rooms, chatroomID)
+	}
+	return true
+}
+
+// UpdateProfile copies a user's profile to their presence in every chatroom
+// and returns the IDs of those chatrooms
+func (s *PresenceService) UpdateProfile(profile *models.Profile) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var chatroomIDs []string
+	for chatroomID, users := range s.rooms {
+		presence, ok := users[profile.UserID]
+		if !ok {
+			continue
+		}
+		presence.DisplayName = profile.DisplayName
+		presence.AvatarURL = profile.AvatarURL
+		presence.StatusText = profile.StatusText
+		chatroomIDs = append(chatroomIDs, chatroomID)
+	}
+	sort.Strings(chatroomIDs)
+
+	return chatroomIDs
+}
+
+// GetByChatroomID returns the users connected to a chatroom, ordered by username
+func (s *PresenceService) GetByChatroomID(chatroomID string) []*models.Presence {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	users := make([]*models.Presence, 0, len(s.rooms[chatroomID]))
+	for _, presence := range s.rooms[chatroomID] {
+		copied := *presence
+		users = append(users, &copied)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+
+	return users
+}
+
+// GetChatroomsByUserID returns the IDs of the chatrooms a user is connected to
+func (s *PresenceService) GetChatroomsByUserID(userID string) []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var chatroomIDs []string
+	for chatroomID, users := range s.rooms {
+		if _, ok := users[userID]; ok {
+			chatroomIDs = append(chatroomIDs, chatroomID)
+		}
+	}
+	sort.Strings(chatroomIDs)
+
+	return chatroomIDs
+}
+
+// IsOnline reports whether the user has at least one connection in any chatroom
+func (s *PresenceService) IsOnline(userID string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, users := range s.rooms {
+		if _, ok := users[userID]; ok {
+			return true
+		}
+	}
+	return false
+}
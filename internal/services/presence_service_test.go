@@ -0,0 +1,97 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+func TestPresenceService_FirstAndLastConnection(t *testing.T) {
+	service := NewPresenceService()
+
+	// First connection is reported as a join
+	if !service.Connect("room-1", "user-1", "alice") {
+		t.Errorf("Expected first connection to be reported")
+	}
+
+	// Second tab is not
+	if service.Connect("room-1", "user-1", "alice") {
+		t.Errorf("Expected second connection not to be reported")
+	}
+
+	users := service.GetByChatroomID("room-1")
+	if len(users) != 1 || users[0].Connections != 2 {
+		t.Fatalf("Expected one user with two connections, got: %+v", users)
+	}
+
+	// Closing one tab is not a leave
+	if service.Disconnect("room-1", "user-1") {
+		t.Errorf("Expected disconnect with remaining connections not to be reported")
+	}
+
+	// Closing the last one is
+	if !service.Disconnect("room-1", "user-1") {
+		t.Errorf("Expected last disconnect to be reported")
+	}
+
+	if users := service.GetByChatroomID("room-1"); len(users) != 0 {
+		t.Errorf("Expected no users, got: %+v", users)
+	}
+
+	// Unknown disconnects are ignored
+	if service.Disconnect("room-1", "user-1") {
+		t.Errorf("Expected unknown disconnect not to be reported")
+	}
+}
+
+func TestPresenceService_MultipleRooms(t *testing.T) {
+	service := NewPresenceService()
+
+	service.Connect("room-1", "user-2", "bob")
+	service.Connect("room-1", "user-1", "alice")
+	service.Connect("room-2", "user-1", "alice")
+
+	// Users are ordered by username
+	users := service.GetByChatroomID("room-1")
+	if len(users) != 2 || users[0].Username != "alice" || users[1].Username != "bob" {
+		t.Fatalf("Expected alice and bob, got: %+v", users)
+	}
+
+	rooms := service.GetChatroomsByUserID("user-1")
+	if len(rooms) != 2 || rooms[0] != "room-1" || rooms[1] != "room-2" {
+		t.Errorf("Expected alice in both rooms, got: %v", rooms)
+	}
+
+	// Leaving one room keeps the user online
+	service.Disconnect("room-1", "user-1")
+	if !service.IsOnline("user-1") {
+		t.Errorf("Expected alice to still be online")
+	}
+
+	service.Disconnect("room-2", "user-1")
+	if service.IsOnline("user-1") {
+		t.Errorf("Expected alice to be offline")
+	}
+}
+
+func TestPresenceService_UpdateProfile(t *testing.T) {
+	service := NewPresenceService()
+
+	service.Connect("room-2", "user-1", "alice")
+	service.Connect("room-1", "user-1", "alice")
+	service.Connect("room-1", "user-2", "bob")
+
+	rooms := service.UpdateProfile(&models.Profile{UserID: "user-1", Username: "alice", DisplayName: "Alice", StatusText: "Lunch"})
+	if len(rooms) != 2 || rooms[0] != "room-1" || rooms[1] != "room-2" {
+		t.Errorf("Expected both of alice's rooms, got: %v", rooms)
+	}
+
+	users := service.GetByChatroomID("room-1")
+	if users[0].DisplayName != "Alice" || users[0].StatusText != "Lunch" || users[1].DisplayName != "" {
+		t.Errorf("Expected only alice's presence to be updated, got: %+v %+v", users[0], users[1])
+	}
+
+	if rooms := service.UpdateProfile(&models.Profile{UserID: "user-3"}); len(rooms) != 0 {
+		t.Errorf("Expected no rooms for an offline user, got: %v", rooms)
+	}
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"image/png"
+	"io"
+	"log"
+	"strings"
+	"time"
+	_ "time/tzdata" // validate time zones on hosts without a zoneinfo database
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dbvitor/chat-go/internal/database"
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/pkg/imaging"
+	"github.com/dbvitor/chat-go/pkg/storage"
+)
+
+// Limits of the profile fields
+const (
+	MaxDisplayNameLength = 50
+	MaxStatusTextLength  = 140
+)
+
+// Avatars are cropped to a square and stored as AvatarSize x AvatarSize PNG images
+const AvatarSize = 256
+
+// Largest uploaded avatar decoded, in pixels, e.g. 6000x4000
+const maxAvatarPixels = 24_000_000
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidDisplayName = errors.New("display name must be at most 50 characters and can't contain control characters")
+	ErrInvalidStatusText  = errors.New("status text must be at most 140 characters and can't contain control characters")
+	ErrInvalidTimeZone    = errors.New("time zone must be an IANA time zone name, e.g. Europe/Lisbon")
+	ErrAvatarTooLarge     = errors.New("avatar is too large")
+	ErrInvalidAvatar      = errors.New("avatar must be a PNG, JPEG or GIF image")
+	ErrAvatarNotFound     = errors.New("avatar not found")
+)
+
+// ProfileService manages the display name, avatar, status and time zone of users
+type ProfileService struct {
+	userRepo      *database.UserRepository
+	storage       storage.Storage
+	maxAvatarSize int64
+}
+
+// NewProfileService creates a new profile service that stores avatars in
+// store and accepts uploads up to maxAvatarSize bytes
+func NewProfileService(db *sql.DB, store storage.Storage, maxAvatarSize int64) *ProfileService {
+	return &ProfileService{
+		userRepo:      database.NewUserRepository(db),
+		storage:       store,
+		maxAvatarSize: maxAvatarSize,
+	}
+}
+
+// MaxAvatarSize returns the maximum accepted avatar upload size in bytes
+func (s *ProfileService) MaxAvatarSize() int64 {
+	return s.maxAvatarSize
+}
+
+// Get returns the profile of a user
+func (s *ProfileService) Get(userID string) (*models.Profile, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return user.Profile(), nil
+}
+
+// Update changes the profile fields set in update and returns the updated user
+func (s *ProfileService) Update(userID string, update *models.ProfileUpdate) (*models.User, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyProfileUpdate(user, update); err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.UpdateProfile(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SetAvatar resizes an uploaded image and makes it the user's avatar,
+// returning the updated user
+func (s *ProfileService) SetAvatar(userID string, r io.Reader) (*models.User, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read one byte past the limit to tell a full upload from a truncated one
+	data, err := io.ReadAll(io.LimitReader(r, s.maxAvatarSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > s.maxAvatarSize {
+		return nil, ErrAvatarTooLarge
+	}
+
+	img, err := imaging.Decode(data, maxAvatarPixels)
+	if err != nil {
+		if errors.Is(err, imaging.ErrTooManyPixels) {
+			return nil, ErrAvatarTooLarge
+		}
+		return nil, ErrInvalidAvatar
+	}
+
+	// Re-encoding also strips metadata such as the location of photos
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, imaging.Thumbnail(img, AvatarSize)); err != nil {
+		return nil, err
+	}
+
+	key, err := newAvatarKey(userID)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(encoded.Len())
+	if err := s.storage.Put(context.Background(), key, &encoded, size, "image/png"); err != nil {
+		return nil, err
+	}
+
+	previous, err := s.userRepo.UpdateAvatar(userID, key)
+	if err != nil {
+		s.deleteAvatar(key)
+		return nil, err
+	}
+	s.deleteAvatar(previous)
+
+	user.AvatarKey = key
+	return user, nil
+}
+
+// RemoveAvatar deletes the user's avatar and returns the updated user
+func (s *ProfileService) RemoveAvatar(userID string) (*models.User, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := s.userRepo.UpdateAvatar(userID, "")
+	if err != nil {
+		return nil, err
+	}
+	s.deleteAvatar(previous)
+
+	user.AvatarKey = ""
+	return user, nil
+}
+
+// OpenAvatar returns the avatar image of a user; the caller must close it
+func (s *ProfileService) OpenAvatar(userID string) (io.ReadCloser, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		if err == ErrUserNotFound {
+			return nil, ErrAvatarNotFound
+		}
+		return nil, err
+	}
+	if user.AvatarKey == "" {
+		return nil, ErrAvatarNotFound
+	}
+
+	content, err := s.storage.Get(context.Background(), user.AvatarKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, ErrAvatarNotFound
+		}
+		return nil, err
+	}
+	return content, nil
+}
+
+// getUser loads a user, mapping missing users to ErrUserNotFound
+func (s *ProfileService) getUser(userID string) (*models.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// deleteAvatar removes a replaced avatar image; failures only leave an
+// unreferenced file behind, so they're logged
+func (s *ProfileService) deleteAvatar(key string) {
+	if key == "" {
+		return
+	}
+	if err := s.storage.Delete(context.Background(), key); err != nil {
+		log.Printf("Error deleting avatar %s: %v", key, err)
+	}
+}
+
+// applyProfileUpdate validates the fields set in update and copies them to user
+func applyProfileUpdate(user *models.User, update *models.ProfileUpdate) error {
+	if update.DisplayName != nil {
+		displayName := strings.TrimSpace(*update.DisplayName)
+		if !validProfileText(displayName, MaxDisplayNameLength) {
+			return ErrInvalidDisplayName
+		}
+		user.DisplayName = displayName
+	}
+
+	if update.StatusText != nil {
+		statusText := strings.TrimSpace(*update.StatusText)
+		if !validProfileText(statusText, MaxStatusTextLength) {
+			return ErrInvalidStatusText
+		}
+		user.StatusText = statusText
+	}
+
+	if update.TimeZone != nil {
+		timeZone := strings.TrimSpace(*update.TimeZone)
+		if timeZone != "" && !validTimeZone(timeZone) {
+			return ErrInvalidTimeZone
+		}
+		user.TimeZone = timeZone
+	}
+
+	return nil
+}
+
+// validProfileText reports whether text is valid UTF-8 of at most maxLength
+// characters without control characters such as line breaks
+func validProfileText(text string, maxLength int) bool {
+	if !utf8.ValidString(text) || utf8.RuneCountInString(text) > maxLength {
+		return false
+	}
+	for _, r := range text {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// validTimeZone reports whether name is an IANA time zone. "Local" names the
+// server's zone rather than the user's, so it isn't accepted.
+func validTimeZone(name string) bool {
+	if name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// newAvatarKey generates a storage key for a new avatar version of a user
+func newAvatarKey(userID string) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return "avatars/" + userID + "/" + hex.EncodeToString(random) + ".png", nil
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+func TestApplyProfileUpdate(t *testing.T) {
+	text := func(s string) *string { return &s }
+
+	testCases := []struct {
+		name   string
+		update models.ProfileUpdate
+		err    error
+		want   models.User
+	}{
+		{
+			name:   "All fields",
+			update: models.ProfileUpdate{DisplayName: text("  Alice Liddell "), StatusText: text("Down the rabbit hole"), TimeZone: text("Europe/London")},
+			want:   models.User{DisplayName: "Alice Liddell", StatusText: "Down the rabbit hole", TimeZone: "Europe/London"},
+		},
+		{
+			name:   "Unset fields are kept",
+			update: models.ProfileUpdate{StatusText: text("Away")},
+			want:   models.User{DisplayName: "Old name", StatusText: "Away", TimeZone: "UTC"},
+		},
+		{
+			name:   "Empty strings clear fields",
+			update: models.ProfileUpdate{DisplayName: text(""), StatusText: text(""), TimeZone: text("")},
+			want:   models.User{},
+		},
+		{
+			name:   "Display name at the limit",
+			update: models.ProfileUpdate{DisplayName: text(strings.Repeat("é", MaxDisplayNameLength))},
+			want:   models.User{DisplayName: strings.Repeat("é", MaxDisplayNameLength), StatusText: "Old status", TimeZone: "UTC"},
+		},
+		{
+			name:   "Display name too long",
+			update: models.ProfileUpdate{DisplayName: text(strings.Repeat("a", MaxDisplayNameLength+1))},
+			err:    ErrInvalidDisplayName,
+		},
+		{
+			name:   "Display name with a line break",
+			update: models.ProfileUpdate{DisplayName: text("Alice\nAdmin")},
+			err:    ErrInvalidDisplayName,
+		},
+		{
+			name:   "Status text too long",
+			update: models.ProfileUpdate{StatusText: text(strings.Repeat("a", MaxStatusTextLength+1))},
+			err:    ErrInvalidStatusText,
+		},
+		{
+			name:   "Unknown time zone",
+			update: models.ProfileUpdate{TimeZone: text("Mars/Olympus_Mons")},
+			err:    ErrInvalidTimeZone,
+		},
+		{
+			name:   "Server time zone",
+			update: models.ProfileUpdate{TimeZone: text("Local")},
+			err:    ErrInvalidTimeZone,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			user := &models.User{DisplayName: "Old name", StatusText: "Old status", TimeZone: "UTC"}
+			err := applyProfileUpdate(user, &tc.update)
+			if err != tc.err {
+				t.Fatalf("Expected error %v, got: %v", tc.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if user.DisplayName != tc.want.DisplayName || user.StatusText != tc.want.StatusText || user.TimeZone != tc.want.TimeZone {
+				t.Errorf("Expected %q, %q, %q, got: %q, %q, %q",
+					tc.want.DisplayName, tc.want.StatusText, tc.want.TimeZone,
+					user.DisplayName, user.StatusText, user.TimeZone)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/dbvitor/chat-go/internal/database"
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// ErrMessageNotInChatroom is returned when a message doesn't belong to the given chatroom
+var ErrMessageNotInChatroom = errors.New("message not found in chatroom")
+
+// ReadReceiptService handles read positions and unread counts
+type ReadReceiptService struct {
+	readReceiptRepo *database.ReadReceiptRepository
+	messageRepo     *database.MessageRepository
+}
+
+// NewReadReceiptService creates a new read receipt service
+func NewReadReceiptService(db *sql.DB) *ReadReceiptService {
+	return &ReadReceiptService{
+		readReceiptRepo: database.NewReadReceiptRepository(db),
+		messageRepo:     database.NewMessageRepository(db),
+	}
+}
+
+// MarkRead moves the user's read pointer in a chatroom up to the given message.
+// It returns nil without error if the user had already read past it.
+func (s *ReadReceiptService) MarkRead(userID, username, chatroomID, messageID string) (*models.ReadReceipt, error) {
+	// Make sure the message belongs to the chatroom
+	message, err := s.messageRepo.GetByID(messageID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return nil, ErrMessageNotInChatroom
+		}
+		return nil, err
+	}
+	if message.ChatroomID != chatroomID {
+		return nil, ErrMessageNotInChatroom
+	}
+
+	// Skip the write when the user has already read past the message. The
+	// repository still guards against concurrent updates moving it backwards.
+	current, err := s.readReceiptRepo.GetByUserAndChatroomID(userID, chatroomID)
+	if err != nil && !database.IsNotFound(err) {
+		return nil, err
+	}
+	if !advancesReadPointer(current, message) {
+		return nil, nil
+	}
+
+	receipt := models.NewReadReceipt(userID, username, chatroomID, messageID)
+	receipt.MessageCreatedAt = message.CreatedAt
+	moved, err := s.readReceiptRepo.Upsert(receipt)
+	if err != nil {
+		return nil, err
+	}
+	if !moved {
+		return nil, nil
+	}
+
+	return receipt, nil
+}
+
+// GetByChatroomID retrieves the read positions of every user in a chatroom
+func (s *ReadReceiptService) GetByChatroomID(chatroomID string) ([]*models.ReadReceipt, error) {
+	return s.readReceiptRepo.GetByChatroomID(chatroomID)
+}
+
+// GetUnreadCounts returns the number of unread messages in each of the given
+// chatrooms for a user
+func (s *ReadReceiptService) GetUnreadCounts(userID string, chatroomIDs []string) (map[string]int, error) {
+	if len(chatroomIDs) == 0 {
+		return map[string]int{}, nil
+	}
+
+	counts, err := s.readReceiptRepo.GetUnreadCounts(userID, chatroomIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return unreadCountsFor(chatroomIDs, counts), nil
+}
+
+// advancesReadPointer reports whether reading a message moves the read
+// pointer forward from the current receipt, which is nil before the first read
+func advancesReadPointer(current *models.ReadReceipt, message *models.Message) bool {
+	return current == nil || message.CreatedAt.After(current.MessageCreatedAt)
+}
+
+// unreadCountsFor returns the unread count of every requested chatroom,
+// zero for rooms missing from counts and nothing for rooms not requested
+func unreadCountsFor(chatroomIDs []string, counts map[string]int) map[string]int {
+	result := make(map[string]int, len(chatroomIDs))
+	for _, chatroomID := range chatroomIDs {
+		result[chatroomID] = counts[chatroomID]
+	}
+	return result
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+func TestAdvancesReadPointer(t *testing.T) {
+	readUpTo := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	current := &models.ReadReceipt{MessageID: "m2", MessageCreatedAt: readUpTo}
+
+	testCases := []struct {
+		name    string
+		current *models.ReadReceipt
+		sentAt  time.Time
+		want    bool
+	}{
+		{
+			name:   "First read",
+			sentAt: readUpTo,
+			want:   true,
+		},
+		{
+			name:    "Newer message",
+			current: current,
+			sentAt:  readUpTo.Add(time.Second),
+			want:    true,
+		},
+		{
+			name:    "Same message",
+			current: current,
+			sentAt:  readUpTo,
+			want:    false,
+		},
+		{
+			name:    "Older message",
+			current: current,
+			sentAt:  readUpTo.Add(-time.Minute),
+			want:    false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			message := &models.Message{ID: "m", CreatedAt: tc.sentAt}
+			if got := advancesReadPointer(tc.current, message); got != tc.want {
+				t.Errorf("Expected %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUnreadCountsFor(t *testing.T) {
+	testCases := []struct {
+		name        string
+		chatroomIDs []string
+		counts      map[string]int
+		want        map[string]int
+	}{
+		{
+			name:        "Rooms without unread messages count zero",
+			chatroomIDs: []string{"general", "random"},
+			counts:      map[string]int{"general": 3},
+			want:        map[string]int{"general": 3, "random": 0},
+		},
+		{
+			name:        "Rooms that weren't requested are left out",
+			chatroomIDs: []string{"general"},
+			counts:      map[string]int{"general": 1, "random": 7},
+			want:        map[string]int{"general": 1},
+		},
+		{
+			name:        "No rooms",
+			chatroomIDs: nil,
+			counts:      map[string]int{"general": 1},
+			want:        map[string]int{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := unreadCountsFor(tc.chatroomIDs, tc.counts)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Expected %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/database"
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/pkg/auth"
+)
+
+// Limits on scheduled messages
+const (
+	MaxSchedulesPerUser = 25
+	MaxScheduleDelay    = 365 * 24 * time.Hour
+)
+
+// Number of due messages delivered per batch
+const ScheduleBatchSize = 100
+
+// Regex to match reminder commands, e.g. "/remind me in 2h to check AAPL"
+var RemindCommandPattern = regexp.MustCompile(`(?is)^/remind\s+me\s+in\s+(.+?)\s+to\s+(.+)$`)
+
+// Regex to find the parts of a reminder delay, e.g. "1h 30m" or "2 days"
+var delayPartPattern = regexp.MustCompile(`(?i)(\d+)\s*(days?|d|hours?|hrs?|h|minutes?|mins?|m)`)
+
+var (
+	ErrScheduleNotFound     = errors.New("scheduled message not found")
+	ErrInvalidScheduleKind  = errors.New("kind must be message or reminder")
+	ErrInvalidSendTime      = errors.New("send time must be in the future and at most a year away")
+	ErrScheduleLimitReached = errors.New("you have reached the maximum number of scheduled messages")
+	ErrInvalidRemindCommand = errors.New("usage: /remind me in 2h to check AAPL")
+)
+
+// ScheduleService handles messages posted to chatrooms at a later time, and
+// reminders users set for themselves. Due messages are delivered through
+// MessageService.CreateMessage, like messages sent over the WebSocket
+// connection; reminders only go to their author.
+type ScheduleService struct {
+	scheduleRepo    *database.ScheduledMessageRepository
+	userRepo        *database.UserRepository
+	chatroomService *ChatroomService
+	messageService  *MessageService
+	contentPolicy   *ContentPolicy
+}
+
+// NewScheduleService creates a new schedule service. Scheduled content is
+// checked against the content policy of chat messages when it's scheduled.
+func NewScheduleService(db *sql.DB, chatroomService *ChatroomService, messageService *MessageService, contentPolicy *ContentPolicy) *ScheduleService {
+	return &ScheduleService{
+		scheduleRepo:    database.NewScheduledMessageRepository(db),
+		userRepo:        database.NewUserRepository(db),
+		chatroomService: chatroomService,
+		messageService:  messageService,
+		contentPolicy:   contentPolicy,
+	}
+}
+
+// Create schedules a message of a user to a chatroom
+func (s *ScheduleService) Create(userID, chatroomID, kind, content string, sendAt time.Time) (*models.ScheduledMessage, error) {
+	if kind != models.ScheduleKindMessage && kind != models.ScheduleKindReminder {
+		return nil, ErrInvalidScheduleKind
+	}
+	if err := validateSendTime(sendAt, time.Now()); err != nil {
+		return nil, err
+	}
+
+	// Reject now what would be rejected when the message is delivered
+	schedule := models.NewScheduledMessage(userID, chatroomID, kind, strings.TrimSpace(content), sendAt)
+	if schedule.Content == "" {
+		return nil, ErrMessageEmpty
+	}
+	if _, err := s.contentPolicy.Apply(schedule.Content, false); err != nil {
+		return nil, err
+	}
+
+	if err := s.chatroomService.CheckWritable(chatroomID); err != nil {
+		return nil, err
+	}
+
+	count, err := s.scheduleRepo.CountByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= MaxSchedulesPerUser {
+		return nil, ErrScheduleLimitReached
+	}
+
+	if err := s.scheduleRepo.Create(schedule); err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// Remind schedules a reminder from a "/remind me in <delay> to <text>" command
+func (s *ScheduleService) Remind(userID, chatroomID, command string) (*models.ScheduledMessage, error) {
+	delay, text, err := ParseRemindCommand(command)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Create(userID, chatroomID, models.ScheduleKindReminder, text, time.Now().Add(delay))
+}
+
+// List retrieves the pending scheduled messages of a user, soonest first
+func (s *ScheduleService) List(userID string) ([]*models.ScheduledMessage, error) {
+	schedules, err := s.scheduleRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if schedules == nil {
+		schedules = []*models.ScheduledMessage{}
+	}
+	return schedules, nil
+}
+
+// Cancel deletes a pending scheduled message of a user
+func (s *ScheduleService) Cancel(userID, id string) error {
+	deleted, err := s.scheduleRepo.Delete(id, userID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return ErrScheduleNotFound
+		}
+		return err
+	}
+	if !deleted {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+// DeliverDue posts the messages due at now and passes each posted message to
+// deliver, which sends it to connected clients. Due reminders are passed to
+// remind, which sends them to their author and reports whether they were
+// connected. Each message is locked while it's delivered and deleted only
+// afterwards. Messages that can never be posted, e.g. to rooms archived
+// since, are dropped; other failures, and reminders of users who aren't
+// connected, are retried on the next call.
+func (s *ScheduleService) DeliverDue(now time.Time, deliver func(message *models.Message), remind func(schedule *models.ScheduledMessage) bool) (int, error) {
+	delivered := 0
+	var after *models.ScheduledMessage
+	for {
+		schedules, err := s.scheduleRepo.GetDue(now, after, ScheduleBatchSize)
+		if err != nil {
+			return delivered, err
+		}
+
+		for _, schedule := range schedules {
+			ok, err := s.deliverOne(schedule.ID, now, deliver, remind)
+			if err != nil {
+				log.Printf("Error delivering scheduled message %s of user %s, will retry: %v", schedule.ID, schedule.UserID, err)
+				continue
+			}
+			if ok {
+				delivered++
+			}
+		}
+
+		if len(schedules) < ScheduleBatchSize {
+			return delivered, nil
+		}
+		after = schedules[len(schedules)-1]
+	}
+}
+
+// deliverOne claims and delivers a due scheduled message, reporting whether
+// it was delivered. It returns an error only for failures worth retrying.
+func (s *ScheduleService) deliverOne(id string, now time.Time, deliver func(message *models.Message), remind func(schedule *models.ScheduledMessage) bool) (bool, error) {
+	claim, err := s.scheduleRepo.Claim(id, now)
+	if err != nil {
+		return false, err
+	}
+	if claim == nil {
+		// Cancelled meanwhile, or being delivered by another replica
+		return false, nil
+	}
+	defer claim.Release()
+
+	schedule := claim.Schedule
+	if schedule.Kind == models.ScheduleKindReminder {
+		if !remind(schedule) {
+			return false, nil
+		}
+		return true, claim.Complete()
+	}
+
+	message, err := s.post(schedule)
+	if err != nil {
+		if !isPermanentDeliveryError(err) {
+			return false, err
+		}
+		log.Printf("Dropping scheduled message %s of user %s: %v", schedule.ID, schedule.UserID, err)
+		return false, claim.Complete()
+	}
+
+	// The message is saved either way; if it can't be deleted, it's posted
+	// again on the next call rather than lost
+	completeErr := claim.Complete()
+
+	// Commands such as /stock= aren't saved as messages
+	if message != nil {
+		deliver(message)
+	}
+	return true, completeErr
+}
+
+// post creates the message of a scheduled message on behalf of its author
+func (s *ScheduleService) post(schedule *models.ScheduledMessage) (*models.Message, error) {
+	user, err := s.userRepo.GetByID(schedule.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled() {
+		return nil, auth.ErrAccountDisabled
+	}
+
+	return s.messageService.CreateMessage(schedule.UserID, schedule.ChatroomID, schedule.Content)
+}
+
+// isPermanentDeliveryError reports whether a scheduled message failed to post
+// for a reason retrying can't fix: its room or author is gone or can't post,
+// or its content is no longer allowed
+func isPermanentDeliveryError(err error) bool {
+	switch {
+	case errors.Is(err, ErrChatroomArchived),
+		errors.Is(err, ErrChatroomNotFound),
+		errors.Is(err, auth.ErrAccountDisabled),
+		errors.Is(err, ErrMessageEmpty),
+		errors.Is(err, ErrMessageTooLong),
+		errors.Is(err, ErrMessageBlocked):
+		return true
+	}
+	return database.IsNotFound(err)
+}
+
+// validateSendTime checks that a message is scheduled after now and at most
+// MaxScheduleDelay later
+func validateSendTime(sendAt, now time.Time) error {
+	if !sendAt.After(now) || sendAt.Sub(now) > MaxScheduleDelay {
+		return ErrInvalidSendTime
+	}
+	return nil
+}
+
+// IsRemindCommand reports whether a chat message is a reminder command,
+// valid or not
+func IsRemindCommand(content string) bool {
+	fields := strings.Fields(content)
+	return len(fields) > 0 && strings.EqualFold(fields[0], "/remind")
+}
+
+// ParseRemindCommand reads the delay and text of a reminder command such as
+// "/remind me in 1h 30m to check AAPL". Delays are made of days, hours and minutes.
+func ParseRemindCommand(command string) (time.Duration, string, error) {
+	match := RemindCommandPattern.FindStringSubmatch(strings.TrimSpace(command))
+	if match == nil {
+		return 0, "", ErrInvalidRemindCommand
+	}
+
+	delay, ok := parseDelay(match[1])
+	text := strings.TrimSpace(match[2])
+	if !ok || text == "" {
+		return 0, "", ErrInvalidRemindCommand
+	}
+
+	return delay, text, nil
+}
+
+// parseDelay parses delays such as "2h", "1h30m", "90 minutes" or "1 day and 2 hours"
+func parseDelay(value string) (time.Duration, bool) {
+	parts := delayPartPattern.FindAllStringSubmatchIndex(value, -1)
+	if len(parts) == 0 {
+		return 0, false
+	}
+
+	var delay time.Duration
+	rest := value
+	for i := len(parts) - 1; i >= 0; i-- {
+		part := parts[i]
+
+		unit := time.Minute
+		switch strings.ToLower(value[part[4]:part[5]])[0] {
+		case 'd':
+			unit = 24 * time.Hour
+		case 'h':
+			unit = time.Hour
+		}
+
+		// Anything longer than the maximum delay is rejected later; this
+		// only keeps the sum from overflowing
+		amount, err := strconv.Atoi(value[part[2]:part[3]])
+		if err != nil || time.Duration(amount) > MaxScheduleDelay/unit+1 {
+			return 0, false
+		}
+		delay += time.Duration(amount) * unit
+
+		rest = rest[:part[0]] + " " + rest[part[1]:]
+	}
+
+	// Only whitespace, commas and "and" may separate the parts
+	for _, word := range strings.Fields(strings.ReplaceAll(rest, ",", " ")) {
+		if !strings.EqualFold(word, "and") {
+			return 0, false
+		}
+	}
+
+	return delay, delay > 0
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/pkg/auth"
+)
+
+func TestParseRemindCommand(t *testing.T) {
+	testCases := []struct {
+		name    string
+		command string
+		delay   time.Duration
+		text    string
+		err     error
+	}{
+		{"Hours", "/remind me in 2h to check AAPL", 2 * time.Hour, "check AAPL", nil},
+		{"Combined units", "/remind me in 1h30m to call Bob", 90 * time.Minute, "call Bob", nil},
+		{"Spelled out units", "/remind me in 1 day and 2 hours to renew", 26 * time.Hour, "renew", nil},
+		{"Minutes", "/remind me in 45 mins to stretch", 45 * time.Minute, "stretch", nil},
+		{"Case insensitive", "/Remind Me In 10M To stand up", 10 * time.Minute, "stand up", nil},
+		{"Text containing to", "/remind me in 3d to go to the bank", 72 * time.Hour, "go to the bank", nil},
+		{"Multiline text", "/remind me in 5m to buy\nmilk", 5 * time.Minute, "buy\nmilk", nil},
+		{"Unknown unit", "/remind me in 2 weeks to check", 0, "", ErrInvalidRemindCommand},
+		{"Garbage in delay", "/remind me in 2h soon to check", 0, "", ErrInvalidRemindCommand},
+		{"Zero delay", "/remind me in 0m to check", 0, "", ErrInvalidRemindCommand},
+		{"Missing text", "/remind me in 2h to   ", 0, "", ErrInvalidRemindCommand},
+		{"Missing delay", "/remind me to check", 0, "", ErrInvalidRemindCommand},
+		{"Huge delay", "/remind me in 99999999999d to check", 0, "", ErrInvalidRemindCommand},
+		{"Not a reminder", "remind me in 2h to check", 0, "", ErrInvalidRemindCommand},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			delay, text, err := ParseRemindCommand(tc.command)
+			if err != tc.err {
+				t.Fatalf("Expected error %v, got: %v", tc.err, err)
+			}
+			if delay != tc.delay || text != tc.text {
+				t.Errorf("Expected %v and %q, got: %v and %q", tc.delay, tc.text, delay, text)
+			}
+		})
+	}
+}
+
+func TestValidateSendTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name   string
+		sendAt time.Time
+		err    error
+	}{
+		{"In an hour", now.Add(time.Hour), nil},
+		{"At the maximum delay", now.Add(MaxScheduleDelay), nil},
+		{"Now", now, ErrInvalidSendTime},
+		{"In the past", now.Add(-time.Minute), ErrInvalidSendTime},
+		{"Beyond the maximum delay", now.Add(MaxScheduleDelay + time.Minute), ErrInvalidSendTime},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := validateSendTime(tc.sendAt, now); err != tc.err {
+				t.Errorf("Expected %v, got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestNewScheduledMessageUsesUTC(t *testing.T) {
+	sendAt := time.Date(2024, 5, 1, 9, 0, 0, 0, time.FixedZone("BRT", -3*60*60))
+	schedule := models.NewScheduledMessage("user-1", "room-1", models.ScheduleKindReminder, "check AAPL", sendAt)
+
+	if schedule.SendAt.Location() != time.UTC {
+		t.Errorf("Expected the send time in UTC, got: %v", schedule.SendAt.Location())
+	}
+	if !schedule.SendAt.Equal(sendAt) {
+		t.Errorf("Expected the same instant as %v, got: %v", sendAt, schedule.SendAt)
+	}
+}
+
+func TestIsPermanentDeliveryError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"Archived chatroom", ErrChatroomArchived, true},
+		{"Deleted chatroom", ErrChatroomNotFound, true},
+		{"Disabled author", auth.ErrAccountDisabled, true},
+		{"Deleted author", sql.ErrNoRows, true},
+		{"Blocked content", ErrMessageBlocked, true},
+		{"Content too long", fmt.Errorf("%w (2500 characters, maximum is 2000)", ErrMessageTooLong), true},
+		{"Database unavailable", errors.New("connection refused"), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isPermanentDeliveryError(tc.err); got != tc.expected {
+				t.Errorf("Expected %v, got: %v", tc.expected, got)
+			}
+		})
+	}
+}
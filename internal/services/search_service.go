@@ -0,0 +1,100 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"html"
+	"strings"
+
+	"github.com/dbvitor/chat-go/internal/database"
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// Search pagination limits
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// ErrEmptySearchQuery is returned when a search has no terms
+var ErrEmptySearchQuery = errors.New("search query is required")
+
+// SearchService handles full-text message search
+type SearchService struct {
+	messageRepo     *database.MessageRepository
+	chatroomService *ChatroomService
+}
+
+// NewSearchService creates a new search service
+func NewSearchService(db *sql.DB, chatroomService *ChatroomService) *SearchService {
+	return &SearchService{
+		messageRepo:     database.NewMessageRepository(db),
+		chatroomService: chatroomService,
+	}
+}
+
+// Search finds messages the user can read that match the query. It fetches one
+// extra result so callers can tell whether there is a next page.
+func (s *SearchService) Search(userID string, query models.SearchQuery) (results []*models.SearchResult, hasMore bool, err error) {
+	query.Text = strings.TrimSpace(query.Text)
+	if query.Text == "" {
+		return nil, false, ErrEmptySearchQuery
+	}
+
+	// Restrict the search to a single room the user can read
+	if query.ChatroomID != "" {
+		canRead, err := s.chatroomService.CanRead(userID, query.ChatroomID)
+		if err != nil {
+			return nil, false, err
+		}
+		if !canRead {
+			return nil, false, ErrChatroomNotFound
+		}
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = DefaultSearchLimit
+	}
+	if query.Limit > MaxSearchLimit {
+		query.Limit = MaxSearchLimit
+	}
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+
+	limit := query.Limit
+	query.Limit++
+
+	results, err = s.messageRepo.Search(query)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(results) > limit {
+		results = results[:limit]
+		hasMore = true
+	}
+
+	for _, result := range results {
+		result.Snippet = HighlightSnippet(result.Snippet)
+		renderMessages(result.Message)
+	}
+
+	return results, hasMore, nil
+}
+
+// HighlightSnippet escapes a search snippet for HTML and turns the match
+// markers added by the database into <mark> tags
+func HighlightSnippet(snippet string) string {
+	// Markers typed by users would produce unbalanced tags, so drop them all
+	if strings.Count(snippet, database.SnippetStartMarker) != strings.Count(snippet, database.SnippetStopMarker) {
+		snippet = strings.ReplaceAll(snippet, database.SnippetStartMarker, "")
+		snippet = strings.ReplaceAll(snippet, database.SnippetStopMarker, "")
+	}
+
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, database.SnippetStartMarker, "<mark>")
+	escaped = strings.ReplaceAll(escaped, database.SnippetStopMarker, "</mark>")
+
+	return escaped
+}
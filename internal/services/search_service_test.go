@@ -0,0 +1,48 @@
+package services
+
+import (
+	"testing"
+)
+
+func TestHighlightSnippet(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Single match",
+			input:    "check \x02AAPL\x03 today",
+			expected: "check <mark>AAPL</mark> today",
+		},
+		{
+			name:     "Multiple matches",
+			input:    "\x02stock\x03 and \x02stocks\x03",
+			expected: "<mark>stock</mark> and <mark>stocks</mark>",
+		},
+		{
+			name:     "HTML is escaped",
+			input:    "<script>alert(1)</script> \x02hello\x03",
+			expected: "&lt;script&gt;alert(1)&lt;/script&gt; <mark>hello</mark>",
+		},
+		{
+			name:     "Unbalanced markers are dropped",
+			input:    "\x02hello\x03 \x02world",
+			expected: "hello world",
+		},
+		{
+			name:     "No matches",
+			input:    "plain text",
+			expected: "plain text",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := HighlightSnippet(tc.input)
+			if result != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/database"
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// ErrSessionNotFound is returned when a session doesn't exist, belongs to another user or was already revoked
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionService manages the login sessions of users
+type SessionService struct {
+	sessionRepo *database.SessionRepository
+}
+
+// NewSessionService creates a new session service
+func NewSessionService(db *sql.DB) *SessionService {
+	return &SessionService{
+		sessionRepo: database.NewSessionRepository(db),
+	}
+}
+
+// GetByUserID lists the active sessions of a user, flagging the current one
+func (s *SessionService) GetByUserID(userID, currentID string) ([]*models.Session, error) {
+	sessions, err := s.sessionRepo.GetActiveByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range sessions {
+		session.Current = session.ID == currentID
+	}
+
+	return sessions, nil
+}
+
+// Revoke ends one of the user's sessions
+func (s *SessionService) Revoke(userID, sessionID string) error {
+	revoked, err := s.sessionRepo.Revoke(userID, sessionID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOthers ends every session of the user except the current one,
+// logging out all other devices
+func (s *SessionService) RevokeOthers(userID, currentID string) (int64, error) {
+	return s.sessionRepo.RevokeAllByUserID(userID, currentID)
+}
+
+// StartCleanup periodically deletes expired and revoked sessions in the background
+func (s *SessionService) StartCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			deleted, err := s.sessionRepo.DeleteExpired(time.Now())
+			if err != nil {
+				log.Printf("Error deleting expired sessions: %v", err)
+			} else if deleted > 0 {
+				log.Printf("Deleted %d expired sessions", deleted)
+			}
+		}
+	}()
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/database"
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/pkg/auth"
+	"github.com/dbvitor/chat-go/pkg/oidc"
+)
+
+// Limits on usernames generated for single sign-on users
+const (
+	maxGeneratedUsernameLength = 40
+	maxUsernameSuffix          = 100
+)
+
+// Roles a group mapping can grant, most privileged first. A user in several
+// mapped groups gets the most privileged of their roles.
+var rolePrecedence = []string{models.RoleAdmin, models.RoleUser, models.RoleBot}
+
+// SSOFlow is a login started with the identity provider. Its state, nonce and
+// verifier are kept by the browser until the provider redirects back.
+type SSOFlow struct {
+	URL      string
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+// SSOService signs users in with an OpenID Connect provider, creating local
+// users on their first login
+type SSOService struct {
+	provider     *oidc.Provider
+	userRepo     *database.UserRepository
+	identityRepo *database.IdentityRepository
+	groupRoles   map[string]string
+}
+
+// NewSSOService creates a new single sign-on service. groupRoles maps groups
+// of the provider to global roles; when empty, roles aren't managed by the provider.
+func NewSSOService(db *sql.DB, provider *oidc.Provider, groupRoles map[string]string) *SSOService {
+	return &SSOService{
+		provider:     provider,
+		userRepo:     database.NewUserRepository(db),
+		identityRepo: database.NewIdentityRepository(db),
+		groupRoles:   groupRoles,
+	}
+}
+
+// ParseRoleMapping parses group to role mappings such as "chat-admins=admin"
+func ParseRoleMapping(entries []string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, entry := range entries {
+		group, role, ok := strings.Cut(entry, "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !ok || group == "" || !models.IsValidRole(role) {
+			return nil, fmt.Errorf("invalid role mapping %q, expected group=admin|user|bot", entry)
+		}
+		mapping[group] = role
+	}
+	return mapping, nil
+}
+
+// Start begins a login, returning the provider URL to send the browser to
+func (s *SSOService) Start(ctx context.Context) (*SSOFlow, error) {
+	flow := &SSOFlow{}
+	for _, value := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		random, err := oidc.RandomString()
+		if err != nil {
+			return nil, err
+		}
+		*value = random
+	}
+
+	url, err := s.provider.AuthCodeURL(ctx, flow.State, flow.Nonce, flow.Verifier)
+	if err != nil {
+		return nil, err
+	}
+	flow.URL = url
+
+	return flow, nil
+}
+
+// Finish completes a login with the code the provider redirected back with.
+// It returns the local user of the provider account, creating it if needed.
+func (s *SSOService) Finish(ctx context.Context, flow *SSOFlow, code string) (*models.User, error) {
+	tokens, err := s.provider.Exchange(ctx, code, flow.Verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := s.provider.VerifyIDToken(ctx, tokens.IDToken, flow.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userForClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// Provider accounts can't get around an account being disabled here
+	if user.Disabled() {
+		return nil, auth.ErrAccountDisabled
+	}
+
+	return user, nil
+}
+
+// userForClaims returns the user linked to the subject of an ID token,
+// provisioning one just in time on the first login
+func (s *SSOService) userForClaims(claims *oidc.Claims) (*models.User, error) {
+	var user *models.User
+	userID, err := s.identityRepo.GetUserID(claims.Issuer, claims.Subject)
+	switch {
+	case err == nil:
+		user, err = s.userRepo.GetByID(userID)
+		if err != nil {
+			return nil, err
+		}
+	case database.IsNotFound(err):
+		user, err = s.provision(claims)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	// Keep the role in sync with the user's groups at the provider
+	if len(s.groupRoles) > 0 && user.Role != models.RoleBot {
+		if role := RoleForGroups(s.groupRoles, claims.Groups); role != user.Role {
+			if err := s.userRepo.UpdateRole(user.ID, role); err != nil {
+				return nil, err
+			}
+			log.Printf("Role of user %s changed from %s to %s by identity provider groups", user.Username, user.Role, role)
+			user.Role = role
+		}
+	}
+
+	return user, nil
+}
+
+// provision creates a local user for an account of the identity provider.
+// The user has no password, so they can only sign in through the provider.
+func (s *SSOService) provision(claims *oidc.Claims) (*models.User, error) {
+	username, err := s.availableUsername(usernameFromClaims(claims))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &models.User{
+		Username:  username,
+		Role:      models.RoleUser,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, err
+	}
+
+	if err := s.identityRepo.Create(claims.Issuer, claims.Subject, user.ID); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Provisioned user %s for subject %s of %s", user.Username, claims.Subject, claims.Issuer)
+	return user, nil
+}
+
+// availableUsername returns the username, or the first free one with a
+// numeric suffix. Usernames are compared case-insensitively, like mentions.
+func (s *SSOService) availableUsername(base string) (string, error) {
+	candidates := make([]string, 0, maxUsernameSuffix)
+	candidates = append(candidates, base)
+	for i := 2; i <= maxUsernameSuffix; i++ {
+		candidates = append(candidates, fmt.Sprintf("%s%d", base, i))
+	}
+
+	taken, err := s.userRepo.GetByUsernames(candidates)
+	if err != nil {
+		return "", err
+	}
+	used := make(map[string]bool, len(taken))
+	for _, user := range taken {
+		used[strings.ToLower(user.Username)] = true
+	}
+
+	for _, candidate := range candidates {
+		if !used[strings.ToLower(candidate)] {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no free username for %q", base)
+}
+
+// usernameFromClaims picks a username for a new user from their ID token:
+// the preferred username, else the local part of the email address, else the name
+func usernameFromClaims(claims *oidc.Claims) string {
+	email, _, _ := strings.Cut(claims.Email, "@")
+	for _, candidate := range []string{claims.PreferredUsername, email, claims.Name} {
+		if username := sanitizeUsername(candidate); username != "" {
+			return username
+		}
+	}
+	return "user"
+}
+
+// sanitizeUsername keeps the ASCII characters that can be @mentioned
+func sanitizeUsername(name string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.', r == '-':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('.')
+		}
+		if b.Len() >= maxGeneratedUsernameLength {
+			break
+		}
+	}
+	return strings.Trim(b.String(), ".-")
+}
+
+// RoleForGroups returns the role granted by a user's groups, or the plain
+// user role when none of them is mapped
+func RoleForGroups(groupRoles map[string]string, groups []string) string {
+	granted := make(map[string]bool)
+	for _, group := range groups {
+		if role, ok := groupRoles[group]; ok {
+			granted[role] = true
+		}
+	}
+
+	for _, role := range rolePrecedence {
+		if granted[role] {
+			return role
+		}
+	}
+	return models.RoleUser
+}
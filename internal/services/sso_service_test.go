@@ -0,0 +1,79 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/pkg/oidc"
+)
+
+func TestParseRoleMapping(t *testing.T) {
+	mapping, err := ParseRoleMapping([]string{"chat-admins=admin", " staff = user "})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if mapping["chat-admins"] != models.RoleAdmin || mapping["staff"] != models.RoleUser {
+		t.Errorf("Expected parsed mapping, got: %v", mapping)
+	}
+
+	for _, invalid := range []string{"chat-admins", "=admin", "chat-admins=superuser"} {
+		if _, err := ParseRoleMapping([]string{invalid}); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestRoleForGroups(t *testing.T) {
+	mapping := map[string]string{
+		"chat-admins": models.RoleAdmin,
+		"staff":       models.RoleUser,
+		"robots":      models.RoleBot,
+	}
+
+	testCases := []struct {
+		name     string
+		groups   []string
+		expected string
+	}{
+		{"No groups", nil, models.RoleUser},
+		{"Unmapped groups", []string{"finance"}, models.RoleUser},
+		{"Admin group", []string{"finance", "chat-admins"}, models.RoleAdmin},
+		{"Most privileged role wins", []string{"robots", "staff", "chat-admins"}, models.RoleAdmin},
+		{"Bot group", []string{"robots"}, models.RoleBot},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if role := RoleForGroups(mapping, tc.groups); role != tc.expected {
+				t.Errorf("Expected role %s, got: %s", tc.expected, role)
+			}
+		})
+	}
+}
+
+func TestUsernameFromClaims(t *testing.T) {
+	testCases := []struct {
+		name     string
+		claims   oidc.Claims
+		expected string
+	}{
+		{"Preferred username", oidc.Claims{PreferredUsername: "alice", Email: "a.smith@example.com"}, "alice"},
+		{"Email local part", oidc.Claims{Email: "a.smith@example.com", Name: "Alice Smith"}, "a.smith"},
+		{"Name with spaces", oidc.Claims{Name: "Alice Smith"}, "Alice.Smith"},
+		{"Characters that can't be mentioned are dropped", oidc.Claims{PreferredUsername: "élodie+chat"}, "lodiechat"},
+		{"Nothing usable", oidc.Claims{PreferredUsername: "日本", Name: "--"}, "user"},
+		{
+			"Long names are truncated",
+			oidc.Claims{PreferredUsername: "a123456789b123456789c123456789d123456789e123456789"},
+			"a123456789b123456789c123456789d123456789",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if username := usernameFromClaims(&tc.claims); username != tc.expected {
+				t.Errorf("Expected username %q, got: %q", tc.expected, username)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/database"
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/pkg/auth"
+)
+
+// Limits on personal access tokens
+const (
+	MaxTokensPerUser   = 25
+	MaxTokenNameLength = 100
+	MaxTokenLifetime   = 365 * 24 * time.Hour
+)
+
+var (
+	ErrTokenNotFound     = errors.New("token not found")
+	ErrInvalidTokenName  = errors.New("token name is required and must be at most 100 characters")
+	ErrInvalidScopes     = errors.New("scopes must be one or more of: read, write, admin")
+	ErrInvalidExpiration = errors.New("expiration must be between 1 and 365 days")
+	ErrTooManyTokens     = errors.New("too many active tokens")
+)
+
+// TokenService manages personal access tokens
+type TokenService struct {
+	tokenRepo *database.AccessTokenRepository
+}
+
+// NewTokenService creates a new token service
+func NewTokenService(db *sql.DB) *TokenService {
+	return &TokenService{
+		tokenRepo: database.NewAccessTokenRepository(db),
+	}
+}
+
+// Create issues a new token for a user. A zero lifetime creates a token that
+// never expires. The plaintext token is only available on the returned value.
+func (s *TokenService) Create(userID, name string, scopes []string, lifetime time.Duration) (*models.AccessToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > MaxTokenNameLength {
+		return nil, ErrInvalidTokenName
+	}
+
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	if lifetime < 0 || lifetime > MaxTokenLifetime {
+		return nil, ErrInvalidExpiration
+	}
+
+	count, err := s.tokenRepo.CountActiveByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= MaxTokensPerUser {
+		return nil, ErrTooManyTokens
+	}
+
+	value, prefix, hash, err := auth.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	token := &models.AccessToken{
+		UserID:    userID,
+		Name:      name,
+		Scopes:    scopes,
+		Prefix:    prefix,
+		TokenHash: hash,
+		CreatedAt: time.Now(),
+	}
+	if lifetime > 0 {
+		expiresAt := token.CreatedAt.Add(lifetime)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := s.tokenRepo.Create(token); err != nil {
+		return nil, err
+	}
+
+	token.Token = value
+	return token, nil
+}
+
+// GetByUserID lists the tokens of a user that haven't been revoked
+func (s *TokenService) GetByUserID(userID string) ([]*models.AccessToken, error) {
+	return s.tokenRepo.GetByUserID(userID)
+}
+
+// Revoke disables one of the user's tokens
+func (s *TokenService) Revoke(userID, tokenID string) error {
+	revoked, err := s.tokenRepo.Revoke(userID, tokenID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return ErrTokenNotFound
+		}
+		return err
+	}
+	if !revoked {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// normalizeScopes validates scopes and removes duplicates
+func normalizeScopes(scopes []string) ([]string, error) {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !models.IsValidScope(scope) {
+			return nil, ErrInvalidScopes
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+
+	if len(normalized) == 0 {
+		return nil, ErrInvalidScopes
+	}
+	return normalized, nil
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeScopes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    []string
+		expected []string
+		err      error
+	}{
+		{
+			name:     "Single scope",
+			input:    []string{"read"},
+			expected: []string{"read"},
+		},
+		{
+			name:     "Case and duplicates are normalized",
+			input:    []string{" Write", "write", "READ"},
+			expected: []string{"write", "read"},
+		},
+		{
+			name:  "Unknown scope",
+			input: []string{"read", "superuser"},
+			err:   ErrInvalidScopes,
+		},
+		{
+			name:  "No scopes",
+			input: nil,
+			err:   ErrInvalidScopes,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := normalizeScopes(tc.input)
+			if err != tc.err {
+				t.Fatalf("Expected error %v, got: %v", tc.err, err)
+			}
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected %v, got: %v", tc.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/database"
+	"github.com/dbvitor/chat-go/internal/models"
+	"github.com/dbvitor/chat-go/pkg/auth"
+	"github.com/dbvitor/chat-go/pkg/totp"
+)
+
+// Recovery codes issued when two-factor authentication is enabled
+const (
+	RecoveryCodeCount  = 10
+	recoveryCodeLength = 10 // characters, shown in two groups of five
+)
+
+var (
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled    = errors.New("two-factor enrollment has not been started")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TwoFactorService manages TOTP two-factor authentication and recovery codes
+type TwoFactorService struct {
+	twoFactorRepo *database.TwoFactorRepository
+	auditService  *AuditService
+	issuer        string
+}
+
+// NewTwoFactorService creates a new two-factor service. The issuer names the
+// application in authenticator apps.
+func NewTwoFactorService(db *sql.DB, auditService *AuditService, issuer string) *TwoFactorService {
+	return &TwoFactorService{
+		twoFactorRepo: database.NewTwoFactorRepository(db),
+		auditService:  auditService,
+		issuer:        issuer,
+	}
+}
+
+// IsEnabled reports whether logins of a user require a second factor
+func (s *TwoFactorService) IsEnabled(userID string) (bool, error) {
+	twoFactor, err := s.twoFactorRepo.GetByUserID(userID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return twoFactor.Enabled(), nil
+}
+
+// Status returns whether two-factor authentication is enabled for a user and
+// how many recovery codes they have left
+func (s *TwoFactorService) Status(userID string) (*models.TwoFactorStatus, error) {
+	enabled, err := s.IsEnabled(userID)
+	if err != nil || !enabled {
+		return &models.TwoFactorStatus{}, err
+	}
+
+	count, err := s.twoFactorRepo.CountRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorStatus{Enabled: true, RecoveryCodesLeft: count}, nil
+}
+
+// Enroll generates a new secret for a user. Two-factor authentication is
+// only enabled once Enable confirms the user can generate codes with it.
+func (s *TwoFactorService) Enroll(user *models.User) (*models.TwoFactorEnrollment, error) {
+	enabled, err := s.IsEnabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.twoFactorRepo.Save(&models.TwoFactor{
+		UserID:    user.ID,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorEnrollment{
+		Secret: secret,
+		URI:    totp.URI(s.issuer, user.Username, secret),
+	}, nil
+}
+
+// Enable turns on two-factor authentication after checking a code generated
+// with the enrolled secret. It returns the user's recovery codes, which are
+// only available now.
+func (s *TwoFactorService) Enable(userID, code string) ([]string, error) {
+	twoFactor, err := s.twoFactorRepo.GetByUserID(userID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return nil, ErrTwoFactorNotEnrolled
+		}
+		return nil, err
+	}
+	if twoFactor.Enabled() {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	now := time.Now()
+	step, ok := totp.Validate(twoFactor.Secret, normalizeCode(code), now)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	enabled, err := s.twoFactorRepo.Enable(userID, step, now)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	return s.issueRecoveryCodes(userID)
+}
+
+// Verify checks a second factor of a user: a code from their authenticator
+// app or one of their recovery codes. Each code can only be used once.
+func (s *TwoFactorService) Verify(userID, code string) error {
+	twoFactor, err := s.twoFactorRepo.GetByUserID(userID)
+	if err != nil {
+		if database.IsNotFound(err) {
+			return ErrTwoFactorNotEnabled
+		}
+		return err
+	}
+	if !twoFactor.Enabled() {
+		return ErrTwoFactorNotEnabled
+	}
+
+	code = normalizeCode(code)
+	now := time.Now()
+
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(twoFactor.Secret, code, now)
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+
+		// Refuse a code that was already used, e.g. one seen over a shoulder
+		fresh, err := s.twoFactorRepo.UseStep(userID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	used, err := s.twoFactorRepo.UseRecoveryCode(userID, auth.HashToken(code), now)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user after checking
+// a second factor
+func (s *TwoFactorService) RegenerateRecoveryCodes(userID, code string) ([]string, error) {
+	if err := s.Verify(userID, code); err != nil {
+		return nil, err
+	}
+	return s.issueRecoveryCodes(userID)
+}
+
+// Disable turns off two-factor authentication for a user after checking a
+// second factor
+func (s *TwoFactorService) Disable(userID, code string) error {
+	if err := s.Verify(userID, code); err != nil {
+		return err
+	}
+	return s.remove(userID)
+}
+
+// ForceDisable turns off two-factor authentication for a user without a code,
+// for administrators helping users who lost their authenticator and recovery codes
+func (s *TwoFactorService) ForceDisable(actor *models.User, userID string) error {
+	if err := authorize(actor, models.PermissionManageUsers); err != nil {
+		return err
+	}
+
+	if err := s.remove(userID); err != nil {
+		return err
+	}
+
+	s.auditService.Record(actor, models.AuditUserTwoFactorOff, models.AuditTargetUser, userID, nil)
+	return nil
+}
+
+// remove deletes the two-factor settings and recovery codes of a user
+func (s *TwoFactorService) remove(userID string) error {
+	deleted, err := s.twoFactorRepo.Delete(userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrTwoFactorNotEnabled
+	}
+	return nil
+}
+
+// issueRecoveryCodes generates and stores a new set of recovery codes
+func (s *TwoFactorService) issueRecoveryCodes(userID string) ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = auth.HashToken(normalizeCode(code))
+	}
+
+	if err := s.twoFactorRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// generateRecoveryCode creates a random recovery code such as "k7m2q-x9vtb"
+func generateRecoveryCode() (string, error) {
+	secret := make([]byte, 8)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(secret))[:recoveryCodeLength]
+	return code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:], nil
+}
+
+// normalizeCode removes the separators users may type in codes and ignores case
+func normalizeCode(code string) string {
+	code = strings.NewReplacer(" ", "", "-", "").Replace(code)
+	return strings.ToLower(code)
+}
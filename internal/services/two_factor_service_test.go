@@ -0,0 +1,50 @@
+package services
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/dbvitor/chat-go/pkg/totp"
+)
+
+func TestGenerateRecoveryCode(t *testing.T) {
+	format := regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if !format.MatchString(code) {
+			t.Errorf("Expected code like xxxxx-xxxxx, got: %s", code)
+		}
+		if seen[code] {
+			t.Errorf("Expected unique codes, got %s twice", code)
+		}
+		seen[code] = true
+
+		// Normalized recovery codes can't be mistaken for authenticator codes
+		if len(normalizeCode(code)) == totp.Digits {
+			t.Errorf("Expected recovery code length to differ from TOTP codes, got: %s", code)
+		}
+	}
+}
+
+func TestNormalizeCode(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"123456", "123456"},
+		{"123 456", "123456"},
+		{"ABCDE-FGHIJ", "abcdefghij"},
+		{" abcde fghij ", "abcdefghij"},
+	}
+
+	for _, tc := range testCases {
+		if normalized := normalizeCode(tc.input); normalized != tc.expected {
+			t.Errorf("Expected %q to normalize to %q, got: %q", tc.input, tc.expected, normalized)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// TypingService tracks who is typing in each chatroom. Indicators are never
+// persisted and expire on their own if the client stops refreshing them,
+// e.g. because it disconnected mid-typing.
+type TypingService struct {
+	timeout time.Duration
+	timers  map[string]*time.Timer // chatroom ID + user ID -> expiry timer
+	mutex   sync.Mutex
+}
+
+// NewTypingService creates a typing service whose indicators expire after timeout
+func NewTypingService(timeout time.Duration) *TypingService {
+	return &TypingService{
+		timeout: timeout,
+		timers:  make(map[string]*time.Timer),
+	}
+}
+
+// Start marks a user as typing and reports whether they weren't already.
+// onExpire is called if the indicator times out before Stop is called.
+func (s *TypingService) Start(chatroomID, userID string, onExpire func()) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := typingKey(chatroomID, userID)
+
+	// Refresh the expiry of an indicator that's already active
+	if timer, ok := s.timers[key]; ok && timer.Stop() {
+		timer.Reset(s.timeout)
+		return false
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(s.timeout, func() {
+		s.mutex.Lock()
+		// Only expire if the indicator wasn't stopped or replaced meanwhile
+		expired := s.timers[key] == timer
+		if expired {
+			delete(s.timers, key)
+		}
+		s.mutex.Unlock()
+
+		if expired && onExpire != nil {
+			onExpire()
+		}
+	})
+	s.timers[key] = timer
+
+	return true
+}
+
+// Stop clears a user's typing indicator and reports whether it was active
+func (s *TypingService) Stop(chatroomID, userID string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := typingKey(chatroomID, userID)
+	timer, ok := s.timers[key]
+	if !ok {
+		return false
+	}
+
+	timer.Stop()
+	delete(s.timers, key)
+	return true
+}
+
+// IsTyping reports whether a user is currently typing in a chatroom
+func (s *TypingService) IsTyping(chatroomID, userID string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, ok := s.timers[typingKey(chatroomID, userID)]
+	return ok
+}
+
+func typingKey(chatroomID, userID string) string {
+	return chatroomID + ":" + userID
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTypingService_StartStop(t *testing.T) {
+	service := NewTypingService(time.Minute)
+
+	// First start is reported, refreshes are not
+	if !service.Start("room-1", "user-1", nil) {
+		t.Errorf("Expected first start to be reported")
+	}
+	if service.Start("room-1", "user-1", nil) {
+		t.Errorf("Expected refresh not to be reported")
+	}
+	if !service.IsTyping("room-1", "user-1") {
+		t.Errorf("Expected user to be typing")
+	}
+
+	// Typing is tracked per room
+	if service.IsTyping("room-2", "user-1") {
+		t.Errorf("Expected user not to be typing in another room")
+	}
+
+	if !service.Stop("room-1", "user-1") {
+		t.Errorf("Expected stop to be reported")
+	}
+	if service.Stop("room-1", "user-1") {
+		t.Errorf("Expected second stop not to be reported")
+	}
+}
+
+func TestTypingService_Expiry(t *testing.T) {
+	service := NewTypingService(20 * time.Millisecond)
+
+	expired := make(chan struct{}, 1)
+	service.Start("room-1", "user-1", func() {
+		expired <- struct{}{}
+	})
+
+	select {
+	case <-expired:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected typing indicator to expire")
+	}
+
+	if service.IsTyping("room-1", "user-1") {
+		t.Errorf("Expected expired indicator to be cleared")
+	}
+}
+
+func TestTypingService_StopPreventsExpiry(t *testing.T) {
+	service := NewTypingService(20 * time.Millisecond)
+
+	expired := make(chan struct{}, 1)
+	service.Start("room-1", "user-1", func() {
+		expired <- struct{}{}
+	})
+	service.Stop("room-1", "user-1")
+
+	select {
+	case <-expired:
+		t.Errorf("Expected stopped indicator not to expire")
+	case <-time.After(60 * time.Millisecond):
+	}
+}
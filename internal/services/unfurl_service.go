@@ -0,0 +1,310 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dbvitor/chat-go/internal/models"
+	"golang.org/x/net/html"
+)
+
+// Limits applied when fetching pages to unfurl
+const (
+	UnfurlTimeout      = 5 * time.Second
+	UnfurlMaxBodySize  = 1 << 20 // only the first megabyte is parsed
+	UnfurlMaxRedirects = 3
+	unfurlMaxTextRunes = 300
+)
+
+var (
+	ErrUnfurlBlockedAddress = errors.New("address is not allowed")
+	ErrUnfurlNotHTML        = errors.New("response is not an HTML page")
+	ErrUnfurlNoMetadata     = errors.New("page has no preview metadata")
+)
+
+// Networks that previews must never be fetched from, to prevent server-side
+// request forgery against internal services
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",      // "this" network
+	"10.0.0.0/8",     // private
+	"100.64.0.0/10",  // carrier-grade NAT
+	"127.0.0.0/8",    // loopback
+	"169.254.0.0/16", // link-local, including cloud metadata endpoints
+	"172.16.0.0/12",  // private
+	"192.0.0.0/24",   // IETF protocol assignments
+	"192.168.0.0/16", // private
+	"198.18.0.0/15",  // benchmarking
+	"224.0.0.0/4",    // multicast
+	"240.0.0.0/4",    // reserved
+	"::/128",         // unspecified
+	"::1/128",        // loopback
+	"fc00::/7",       // unique local
+	"fe80::/10",      // link-local
+	"ff00::/8",       // multicast
+	"64:ff9b::/96",   // NAT64, may map to private IPv4
+	"2001:db8::/32",  // documentation
+)
+
+// UnfurlService fetches OpenGraph metadata to build link previews
+type UnfurlService struct {
+	client       *http.Client
+	allowPrivate bool // only set by tests that unfurl a local server
+}
+
+// NewUnfurlService creates the link preview service
+func NewUnfurlService() *UnfurlService {
+	return newUnfurlService(false)
+}
+
+func newUnfurlService(allowPrivate bool) *UnfurlService {
+	s := &UnfurlService{allowPrivate: allowPrivate}
+
+	// Addresses are checked after DNS resolution, right before connecting,
+	// so a hostname can't be re-pointed at an internal address in between
+	dialer := &net.Dialer{
+		Timeout: 2 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return s.checkAddress(address)
+		},
+	}
+
+	s.client = &http.Client{
+		Timeout: UnfurlTimeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   3 * time.Second,
+			ResponseHeaderTimeout: 3 * time.Second,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= UnfurlMaxRedirects {
+				return errors.New("too many redirects")
+			}
+			return checkUnfurlURL(req.URL)
+		},
+	}
+
+	return s
+}
+
+// Unfurl fetches a page and extracts its preview metadata
+func (s *UnfurlService) Unfurl(ctx context.Context, rawURL string) (*models.LinkPreview, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnfurlURL(parsed); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "chat-go-unfurler/1.0")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("page returned status code %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, ErrUnfurlNotHTML
+	}
+
+	preview, err := ParseOpenGraph(io.LimitReader(resp.Body, UnfurlMaxBodySize), resp.Request.URL)
+	if err != nil {
+		return nil, err
+	}
+	preview.URL = rawURL
+
+	return preview, nil
+}
+
+// checkAddress refuses connections to blocked networks
+func (s *UnfurlService) checkAddress(address string) error {
+	if s.allowPrivate {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ErrUnfurlBlockedAddress
+	}
+	if IsBlockedIP(ip) {
+		return fmt.Errorf("%w: %s", ErrUnfurlBlockedAddress, ip)
+	}
+	return nil
+}
+
+// IsBlockedIP reports whether an IP belongs to a private, loopback or otherwise internal network
+func IsBlockedIP(ip net.IP) bool {
+	// IPv4-mapped IPv6 addresses are checked as the IPv4 address they map to
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkUnfurlURL only allows plain http(s) URLs without credentials
+func checkUnfurlURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrUnfurlBlockedAddress, u.Scheme)
+	}
+	if u.User != nil || u.Hostname() == "" {
+		return ErrUnfurlBlockedAddress
+	}
+	return nil
+}
+
+// ParseOpenGraph extracts OpenGraph metadata from an HTML page, falling back
+// to the <title> and description meta tags. Relative image URLs are resolved
+// against base.
+func ParseOpenGraph(r io.Reader, base *url.URL) (*models.LinkPreview, error) {
+	preview := &models.LinkPreview{CreatedAt: time.Now()}
+	var title, description string
+
+	tokenizer := html.NewTokenizer(r)
+	inTitle := false
+
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			// End of document, or of the size limit
+			return finishPreview(preview, title, description, base)
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "title":
+				inTitle = tokenType == html.StartTagToken
+			case "meta":
+				key, content := metaKeyAndContent(token)
+				switch key {
+				case "og:title":
+					preview.Title = content
+				case "og:description":
+					preview.Description = content
+				case "og:image", "og:image:url":
+					if preview.ImageURL == "" {
+						preview.ImageURL = content
+					}
+				case "og:site_name":
+					preview.SiteName = content
+				case "description":
+					description = content
+				}
+			case "body":
+				// Metadata lives in <head>; no need to read the rest of the page
+				return finishPreview(preview, title, description, base)
+			}
+
+		case html.TextToken:
+			if inTitle && title == "" {
+				title = strings.TrimSpace(string(tokenizer.Text()))
+			}
+
+		case html.EndTagToken:
+			if token := tokenizer.Token(); token.Data == "title" {
+				inTitle = false
+			}
+		}
+	}
+}
+
+func finishPreview(preview *models.LinkPreview, title, description string, base *url.URL) (*models.LinkPreview, error) {
+	if preview.Title == "" {
+		preview.Title = title
+	}
+	if preview.Description == "" {
+		preview.Description = description
+	}
+	if preview.Title == "" {
+		return nil, ErrUnfurlNoMetadata
+	}
+
+	preview.Title = truncateRunes(preview.Title, unfurlMaxTextRunes)
+	preview.Description = truncateRunes(preview.Description, unfurlMaxTextRunes)
+	preview.SiteName = truncateRunes(preview.SiteName, unfurlMaxTextRunes)
+
+	// Only keep absolute http(s) image URLs
+	if preview.ImageURL != "" {
+		image, err := url.Parse(preview.ImageURL)
+		if err == nil && base != nil {
+			image = base.ResolveReference(image)
+		}
+		if err != nil || (image.Scheme != "http" && image.Scheme != "https") {
+			preview.ImageURL = ""
+		} else {
+			preview.ImageURL = image.String()
+		}
+	}
+
+	return preview, nil
+}
+
+// metaKeyAndContent returns the property (or name) and content of a meta tag
+func metaKeyAndContent(token html.Token) (string, string) {
+	var key, content string
+	for _, attr := range token.Attr {
+		switch attr.Key {
+		case "property", "name":
+			if key == "" {
+				key = strings.ToLower(strings.TrimSpace(attr.Val))
+			}
+		case "content":
+			content = strings.TrimSpace(attr.Val)
+		}
+	}
+	return key, content
+}
+
+func truncateRunes(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:max-1]) + "…"
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
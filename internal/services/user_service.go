@@ -7,17 +7,27 @@ import (
 	"github.com/dbvitor/chat-go/internal/database"
 	"github.com/dbvitor/chat-go/internal/models"
 	"github.com/dbvitor/chat-go/pkg/auth"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrWrongPassword is returned when a password change doesn't supply the current password
+var ErrWrongPassword = errors.New("current password is incorrect")
+
 // UserService handles user-related business logic
 type UserService struct {
-	userRepo *database.UserRepository
+	userRepo          *database.UserRepository
+	sessionRepo       *database.SessionRepository
+	passwordResetRepo *database.PasswordResetRepository
+	passwordPolicy    *PasswordPolicy
 }
 
-// NewUserService creates a new user service
-func NewUserService(db *sql.DB) *UserService {
+// NewUserService creates a new user service. New passwords must satisfy passwordPolicy.
+func NewUserService(db *sql.DB, passwordPolicy *PasswordPolicy) *UserService {
 	return &UserService{
-		userRepo: database.NewUserRepository(db),
+		userRepo:          database.NewUserRepository(db),
+		sessionRepo:       database.NewSessionRepository(db),
+		passwordResetRepo: database.NewPasswordResetRepository(db),
+		passwordPolicy:    passwordPolicy,
 	}
 }
 
@@ -33,6 +43,11 @@ func (s *UserService) Register(username, password string) (*models.User, error)
 		return nil, auth.ErrUserAlreadyExists
 	}
 
+	// Check password strength
+	if err := s.passwordPolicy.Validate(password, username); err != nil {
+		return nil, err
+	}
+
 	// Create new user
 	user, err := models.NewUser(username, password)
 	if err != nil {
@@ -64,6 +79,11 @@ func (s *UserService) Login(username, password string) (*models.User, error) {
 		return nil, auth.ErrInvalidCredentials
 	}
 
+	// Only tell who knows the password that the account is disabled
+	if user.Disabled() {
+		return nil, auth.ErrAccountDisabled
+	}
+
 	return user, nil
 }
 
@@ -71,3 +91,54 @@ func (s *UserService) Login(username, password string) (*models.User, error) {
 func (s *UserService) GetByID(id string) (*models.User, error) {
 	return s.userRepo.GetByID(id)
 }
+
+// ValidatePassword checks a new password for a user against the password policy
+func (s *UserService) ValidatePassword(user *models.User, password string) error {
+	return s.passwordPolicy.Validate(password, user.Username)
+}
+
+// ChangePassword replaces a user's password after checking their current one.
+// Every other session of the user is revoked.
+func (s *UserService) ChangePassword(userID, currentPassword, newPassword, currentSessionID string) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if !user.CheckPassword(currentPassword) {
+		return ErrWrongPassword
+	}
+
+	return s.SetPassword(userID, newPassword, currentSessionID)
+}
+
+// SetPassword replaces a user's password and revokes their sessions, except
+// keepSessionID (empty to revoke them all), so a leaked session doesn't
+// outlive the password change. Pending password reset links stop working.
+func (s *UserService) SetPassword(userID, password, keepSessionID string) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.ValidatePassword(user, password); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	user.Password = string(hashedPassword)
+
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	if err := s.passwordResetRepo.InvalidateByUserID(userID); err != nil {
+		return err
+	}
+
+	_, err = s.sessionRepo.RevokeAllByUserID(userID, keepSessionID)
+	return err
+}
@@ -1,11 +1,16 @@
 package auth
 
 import (
+	"database/sql"
 	"errors"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/dbvitor/chat-go/internal/config"
 	"github.com/dbvitor/chat-go/internal/models"
 	"github.com/gorilla/sessions"
 )
@@ -14,80 +19,244 @@ var (
 	ErrInvalidCredentials = errors.New("invalid username or password")
 	ErrUserAlreadyExists  = errors.New("user already exists")
 	ErrNotAuthenticated   = errors.New("not authenticated")
+	ErrAccountDisabled    = errors.New("account is disabled")
 )
 
 const SessionName = "chat-session"
 
-const UserKey = "user_id"
+// SessionKey is the cookie value holding the ID of the server-side session
+const SessionKey = "session_id"
+
+// How often the last seen time of a session is written to the database
+const touchInterval = time.Minute
+
+// Longest user agent stored with a session
+const maxUserAgentLength = 512
+
+// How long a pending session waits for the second factor of a login
+const pendingSessionMaxAge = 5 * time.Minute
+
+// SessionStore persists server-side sessions
+type SessionStore interface {
+	Create(session *models.Session) error
+	GetByID(id string) (*models.Session, error)
+	Touch(id string, lastSeenAt time.Time) error
+	Revoke(userID, id string) (bool, error)
+}
 
 var Store *sessions.CookieStore
 
-// Initialize sets up the authentication module
-func Initialize() {
-	// Get session key from environment variable, or use a default key
-	sessionKey := os.Getenv("SESSION_KEY")
-	if sessionKey == "" {
-		sessionKey = "supersecretkey123456789"
-		log.Println("Warning: Using default session key. Set SESSION_KEY for better security.")
+var (
+	sessionStore  SessionStore
+	sessionMaxAge time.Duration
+	trustProxy    bool
+	secureCookies bool
+)
+
+// Initialize sets up the authentication module. It fails in production mode
+// when no session key is configured.
+func Initialize(sessionRepo SessionStore, tokenRepo TokenStore) error {
+	production := config.Production()
+
+	// Get the session keys from the environment
+	keyPairs, err := loadSessionKeys(os.Getenv("SESSION_KEYS"), os.Getenv("SESSION_KEY"), production)
+	if err != nil {
+		if err == ErrNoSessionKeys {
+			return errors.New("SESSION_KEYS must be set in production, generate one with go run ./cmd/keygen")
+		}
+		return err
 	}
+	if os.Getenv("SESSION_KEYS") == "" {
+		if os.Getenv("SESSION_KEY") == "" {
+			log.Println("Warning: SESSION_KEYS not set, using random keys. Sessions won't survive a restart.")
+		} else {
+			log.Println("Warning: SESSION_KEY doesn't encrypt session cookies. Set SESSION_KEYS instead.")
+		}
+	}
+
+	sessionStore = sessionRepo
+	tokenStore = tokenRepo
+	sessionMaxAge = config.Duration("SESSION_MAX_AGE", 7*24*time.Hour)
+	trustProxy = config.Bool("TRUST_PROXY_HEADERS", false)
+	secureCookies = config.Bool("COOKIE_SECURE", production)
 
 	// Create a new cookie store
-	Store = sessions.NewCookieStore([]byte(sessionKey))
+	Store = sessions.NewCookieStore(keyPairs...)
 
 	// Set session options
 	Store.Options = &sessions.Options{
 		Path:     "/",
-		MaxAge:   86400 * 7,
+		MaxAge:   int(sessionMaxAge.Seconds()),
 		HttpOnly: true,
+		Secure:   secureCookies,
 		// Add SameSite attribute for better security
 		SameSite: http.SameSiteLaxMode,
 	}
+	return nil
+}
+
+// SecureCookies reports whether cookies set in response to the request
+// should be marked Secure: always with COOKIE_SECURE, otherwise when the
+// request came over TLS, directly or through a trusted proxy
+func SecureCookies(r *http.Request) bool {
+	if secureCookies || r.TLS != nil {
+		return true
+	}
+	return trustProxy && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// SaveSession saves a cookie session, marking the cookie Secure behind TLS
+func SaveSession(w http.ResponseWriter, r *http.Request, session *sessions.Session) error {
+	session.Options.Secure = SecureCookies(r)
+	return session.Save(r, w)
 }
 
-// Authenticate verifies user credentials and creates a session
+// Authenticate creates a server-side session for the user and sets the session cookie
 func Authenticate(w http.ResponseWriter, r *http.Request, user *models.User) error {
-	// Create a new session
+	return startSession(w, r, user, false)
+}
+
+// StartPending creates a pending session for a user who still has to enter a
+// second factor. It doesn't authenticate requests; once the second factor is
+// checked, Authenticate replaces it with a full session.
+func StartPending(w http.ResponseWriter, r *http.Request, user *models.User) error {
+	return startSession(w, r, user, true)
+}
+
+// startSession creates a server-side session and sets the session cookie,
+// replacing the previous session of the browser
+func startSession(w http.ResponseWriter, r *http.Request, user *models.User, pending bool) error {
+	// Get the cookie session, or start a new one if it can't be decoded
 	session, err := Store.Get(r, SessionName)
 	if err != nil {
 		log.Printf("Session error: %v", err)
-		session = sessions.NewSession(Store, SessionName)
-		session.Options = &sessions.Options{
-			Path:     "/",
-			MaxAge:   86400 * 7,
-			HttpOnly: true,
-			SameSite: http.SameSiteLaxMode,
+	}
+
+	// Logging in again replaces the previous session of this browser
+	if previous, ok := session.Values[SessionKey].(string); ok {
+		if stored, err := sessionStore.GetByID(previous); err == nil {
+			if _, err := sessionStore.Revoke(stored.UserID, stored.ID); err != nil {
+				log.Printf("Failed to revoke previous session: %v", err)
+			}
 		}
 	}
 
-	// Store user ID in session
-	session.Values[UserKey] = user.ID
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	maxAge := sessionMaxAge
+	if pending {
+		maxAge = pendingSessionMaxAge
+	}
+
+	stored := models.NewSession(user.ID, userAgent, ClientIP(r, trustProxy), maxAge)
+	stored.Pending = pending
+	if err := sessionStore.Create(stored); err != nil {
+		log.Printf("Failed to create session: %v", err)
+		return err
+	}
+
+	// Store only the session ID in the cookie, dropping the user ID that
+	// older cookies carried
+	delete(session.Values, "user_id")
+	session.Values[SessionKey] = stored.ID
 
 	// Save the session
-	err = session.Save(r, w)
+	err = SaveSession(w, r, session)
 	if err != nil {
 		log.Printf("Failed to save session: %v", err)
 	}
 	return err
 }
 
-// GetAuthenticatedUser retrieves the authenticated user from the session
-func GetAuthenticatedUser(r *http.Request) (string, error) {
-	// Get the session
+// GetSession retrieves the active server-side session of the request. Only
+// the session cookie is considered, not bearer tokens.
+func GetSession(r *http.Request) (*models.Session, error) {
+	stored, err := loadSession(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// A pending session only lets the user finish logging in
+	if stored.Pending {
+		return nil, ErrNotAuthenticated
+	}
+
+	now := time.Now()
+	if now.Sub(stored.LastSeenAt) > touchInterval {
+		if err := sessionStore.Touch(stored.ID, now); err != nil {
+			log.Printf("Failed to update session last seen time: %v", err)
+		}
+		stored.LastSeenAt = now
+	}
+
+	return stored, nil
+}
+
+// GetPendingSession retrieves the pending session of a login waiting for its
+// second factor
+func GetPendingSession(r *http.Request) (*models.Session, error) {
+	stored, err := loadSession(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !stored.Pending {
+		return nil, ErrNotAuthenticated
+	}
+	return stored, nil
+}
+
+// loadSession retrieves the active server-side session named by the session cookie
+func loadSession(r *http.Request) (*models.Session, error) {
+	// Get the cookie session
 	session, err := Store.Get(r, SessionName)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Get the user ID from the session
-	userID, ok := session.Values[UserKey].(string)
+	// Get the session ID from the cookie
+	sessionID, ok := session.Values[SessionKey].(string)
 	if !ok {
-		return "", ErrNotAuthenticated
+		return nil, ErrNotAuthenticated
+	}
+
+	stored, err := sessionStore.GetByID(sessionID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Failed to load session: %v", err)
+		}
+		return nil, ErrNotAuthenticated
+	}
+
+	// Revoked and expired sessions no longer authenticate, even with a valid cookie
+	if !stored.Active(time.Now()) {
+		return nil, ErrNotAuthenticated
+	}
+
+	return stored, nil
+}
+
+// GetAuthenticatedUser retrieves the authenticated user from the request
+// context or, outside the auth middleware, from the request credentials
+func GetAuthenticatedUser(r *http.Request) (string, error) {
+	if principal := GetPrincipal(r); principal != nil {
+		return principal.UserID(), nil
 	}
 
-	return userID, nil
+	session, token, err := ResolveCredentials(r)
+	if err != nil {
+		return "", err
+	}
+	if token != nil {
+		return token.UserID, nil
+	}
+	return session.UserID, nil
 }
 
-// Logout removes the user from the session
+// Logout revokes the server-side session and expires the cookie
 func Logout(w http.ResponseWriter, r *http.Request) error {
 	// Get the session
 	session, err := Store.Get(r, SessionName)
@@ -95,24 +264,48 @@ func Logout(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	// Delete the user ID from the session
-	delete(session.Values, UserKey)
+	// Revoke the server-side session so the cookie can't be replayed. This
+	// also abandons a login waiting for its second factor.
+	if stored, err := loadSession(r); err == nil {
+		if _, err := sessionStore.Revoke(stored.UserID, stored.ID); err != nil {
+			return err
+		}
+	}
+
+	// Delete the session ID from the cookie
+	delete(session.Values, SessionKey)
 
 	// Set session to expire
 	session.Options.MaxAge = -1
 
 	// Save the session
-	return session.Save(r, w)
+	return SaveSession(w, r, session)
 }
 
 // IsAuthenticated checks if the user is authenticated
 func IsAuthenticated(r *http.Request) bool {
-	session, err := Store.Get(r, SessionName)
-	if err != nil {
-		return false
+	_, err := GetAuthenticatedUser(r)
+	return err == nil
+}
+
+// ClientIP returns the IP address of the client that made the request
+func ClientIP(r *http.Request, trustProxy bool) string {
+	// Only trust forwarding headers when running behind a known proxy. The
+	// proxy appends the address it saw to X-Forwarded-For; earlier entries
+	// come from the client and may be forged.
+	if trustProxy {
+		hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		if last := strings.TrimSpace(hops[len(hops)-1]); last != "" {
+			return last
+		}
+		if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+			return strings.TrimSpace(realIP)
+		}
 	}
 
-	// Check if user ID exists in session
-	_, ok := session.Values[UserKey].(string)
-	return ok
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
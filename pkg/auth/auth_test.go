@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// memoryStore is an in-memory SessionStore for tests
+type memoryStore struct {
+	sessions map[string]*models.Session
+	mutex    sync.Mutex
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{sessions: make(map[string]*models.Session)}
+}
+
+func (m *memoryStore) Create(session *models.Session) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	session.ID = strconv.Itoa(len(m.sessions) + 1)
+	stored := *session
+	m.sessions[session.ID] = &stored
+	return nil
+}
+
+func (m *memoryStore) GetByID(id string) (*models.Session, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (m *memoryStore) Touch(id string, lastSeenAt time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.sessions[id].LastSeenAt = lastSeenAt
+	return nil
+}
+
+func (m *memoryStore) Revoke(userID, id string) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	session, ok := m.sessions[id]
+	if !ok || session.UserID != userID || session.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	session.RevokedAt = &now
+	return true, nil
+}
+
+// login authenticates a user and returns a request carrying the session cookie
+func login(t *testing.T, user *models.User) *http.Request {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("POST", "/api/auth/login", nil)
+	request.Header.Set("User-Agent", "test-browser")
+	if err := Authenticate(recorder, request, user); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	authenticated := httptest.NewRequest("GET", "/api/auth/check", nil)
+	for _, cookie := range recorder.Result().Cookies() {
+		authenticated.AddCookie(cookie)
+	}
+	return authenticated
+}
+
+func TestSessions(t *testing.T) {
+	store := newMemoryStore()
+	Initialize(store, newMemoryTokenStore())
+	user := &models.User{ID: "user-1", Username: "alice"}
+
+	request := login(t, user)
+
+	// The cookie resolves to the server-side session
+	session, err := GetSession(request)
+	if err != nil {
+		t.Fatalf("Expected session, got: %v", err)
+	}
+	if session.UserID != user.ID || session.UserAgent != "test-browser" {
+		t.Errorf("Expected session of %s from test-browser, got: %+v", user.ID, session)
+	}
+	if userID, err := GetAuthenticatedUser(request); err != nil || userID != user.ID {
+		t.Errorf("Expected user %s, got: %s (%v)", user.ID, userID, err)
+	}
+
+	// A second login is an independent session
+	other := login(t, user)
+	if !IsAuthenticated(other) {
+		t.Fatalf("Expected second session to be authenticated")
+	}
+
+	// Revoking a session invalidates its cookie immediately, but not the other one
+	store.Revoke(user.ID, session.ID)
+	if IsAuthenticated(request) {
+		t.Errorf("Expected revoked session not to authenticate")
+	}
+	if !IsAuthenticated(other) {
+		t.Errorf("Expected other session to remain valid")
+	}
+
+	// Logout revokes the session server-side
+	recorder := httptest.NewRecorder()
+	if err := Logout(recorder, other); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if IsAuthenticated(other) {
+		t.Errorf("Expected replayed cookie to be rejected after logout")
+	}
+}
+
+func TestSessions_Expired(t *testing.T) {
+	store := newMemoryStore()
+	Initialize(store, newMemoryTokenStore())
+
+	request := login(t, &models.User{ID: "user-1"})
+	session, _ := GetSession(request)
+	store.sessions[session.ID].ExpiresAt = time.Now().Add(-time.Second)
+
+	if _, err := GetSession(request); err != ErrNotAuthenticated {
+		t.Errorf("Expected ErrNotAuthenticated for expired session, got: %v", err)
+	}
+}
+
+func TestSessions_NoCookie(t *testing.T) {
+	Initialize(newMemoryStore(), newMemoryTokenStore())
+
+	if IsAuthenticated(httptest.NewRequest("GET", "/", nil)) {
+		t.Errorf("Expected request without cookie not to be authenticated")
+	}
+}
+
+func TestSessions_Pending(t *testing.T) {
+	store := newMemoryStore()
+	Initialize(store, newMemoryTokenStore())
+	user := &models.User{ID: "user-1", Username: "alice"}
+
+	// A login waiting for its second factor gets a short-lived pending session
+	recorder := httptest.NewRecorder()
+	if err := StartPending(recorder, httptest.NewRequest("POST", "/api/auth/login", nil), user); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	request := httptest.NewRequest("POST", "/api/auth/2fa/verify", nil)
+	for _, cookie := range recorder.Result().Cookies() {
+		request.AddCookie(cookie)
+	}
+
+	pending, err := GetPendingSession(request)
+	if err != nil {
+		t.Fatalf("Expected pending session, got: %v", err)
+	}
+	if time.Until(pending.ExpiresAt) > pendingSessionMaxAge {
+		t.Errorf("Expected pending session to expire within %s, got: %s", pendingSessionMaxAge, pending.ExpiresAt)
+	}
+
+	// It doesn't authenticate requests
+	if IsAuthenticated(request) {
+		t.Errorf("Expected pending session not to authenticate")
+	}
+
+	// Completing the login replaces it with a full session
+	recorder = httptest.NewRecorder()
+	if err := Authenticate(recorder, request, user); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if store.sessions[pending.ID].RevokedAt == nil {
+		t.Errorf("Expected pending session to be revoked")
+	}
+
+	authenticated := httptest.NewRequest("GET", "/api/auth/check", nil)
+	for _, cookie := range recorder.Result().Cookies() {
+		authenticated.AddCookie(cookie)
+	}
+	if !IsAuthenticated(authenticated) {
+		t.Errorf("Expected full session to authenticate")
+	}
+	if _, err := GetPendingSession(authenticated); err != ErrNotAuthenticated {
+		t.Errorf("Expected full session not to count as pending, got: %v", err)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	testCases := []struct {
+		name       string
+		trustProxy bool
+		headers    map[string][]string
+		expected   string
+	}{
+		{"Remote address", false, nil, "10.0.0.1"},
+		{"Headers ignored without a proxy", false, map[string][]string{"X-Forwarded-For": {"203.0.113.7"}}, "10.0.0.1"},
+		{"Address appended by the proxy", true, map[string][]string{"X-Forwarded-For": {"203.0.113.7"}}, "203.0.113.7"},
+		{"Spoofed prefix sent by the client", true, map[string][]string{"X-Forwarded-For": {"1.2.3.4, 5.6.7.8, 203.0.113.7"}}, "203.0.113.7"},
+		{"Spoofed header line sent by the client", true, map[string][]string{"X-Forwarded-For": {"1.2.3.4", "203.0.113.7"}}, "203.0.113.7"},
+		{"Real IP header", true, map[string][]string{"X-Real-Ip": {"203.0.113.9"}}, "203.0.113.9"},
+		{"No forwarding headers", true, nil, "10.0.0.1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = "10.0.0.1:54321"
+			for key, values := range tc.headers {
+				r.Header[key] = values
+			}
+
+			if got := ClientIP(r, tc.trustProxy); got != tc.expected {
+				t.Errorf("Expected %q, got: %q", tc.expected, got)
+			}
+		})
+	}
+}
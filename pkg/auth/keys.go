@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Key sizes of generated session keys. Authentication keys sign the cookie
+// with HMAC-SHA256 and encryption keys select AES-256.
+const (
+	AuthKeySize       = 64
+	EncryptionKeySize = 32
+)
+
+// Shortest authentication key accepted in production
+const minAuthKeySize = 32
+
+// The key older versions fell back to when SESSION_KEY was unset
+const legacyDefaultKey = "supersecretkey123456789"
+
+var ErrNoSessionKeys = errors.New("no session keys configured")
+
+// ParseSessionKeys parses a comma-separated list of session keys, newest
+// first, into the key pairs of a cookie store. Each entry is a base64
+// authentication key, optionally followed by a colon and a base64 encryption
+// key of 16, 24 or 32 bytes. New cookies use the first entry; the others keep
+// decoding cookies issued before a rotation.
+func ParseSessionKeys(value string) ([][]byte, error) {
+	var keyPairs [][]byte
+	for i, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		authPart, encryptionPart, _ := strings.Cut(entry, ":")
+		authKey, err := base64.StdEncoding.DecodeString(authPart)
+		if err != nil {
+			return nil, fmt.Errorf("session key %d: invalid authentication key: %w", i+1, err)
+		}
+		if len(authKey) < minAuthKeySize {
+			return nil, fmt.Errorf("session key %d: authentication key must be at least %d bytes", i+1, minAuthKeySize)
+		}
+
+		var encryptionKey []byte
+		if encryptionPart != "" {
+			encryptionKey, err = base64.StdEncoding.DecodeString(encryptionPart)
+			if err != nil {
+				return nil, fmt.Errorf("session key %d: invalid encryption key: %w", i+1, err)
+			}
+			switch len(encryptionKey) {
+			case 16, 24, 32:
+			default:
+				return nil, fmt.Errorf("session key %d: encryption key must be 16, 24 or 32 bytes", i+1)
+			}
+		}
+
+		keyPairs = append(keyPairs, authKey, encryptionKey)
+	}
+
+	if len(keyPairs) == 0 {
+		return nil, ErrNoSessionKeys
+	}
+	return keyPairs, nil
+}
+
+// GenerateSessionKey returns a new entry for SESSION_KEYS with random
+// authentication and encryption keys
+func GenerateSessionKey() (string, error) {
+	authKey := make([]byte, AuthKeySize)
+	if _, err := rand.Read(authKey); err != nil {
+		return "", err
+	}
+	encryptionKey := make([]byte, EncryptionKeySize)
+	if _, err := rand.Read(encryptionKey); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(authKey) + ":" + base64.StdEncoding.EncodeToString(encryptionKey), nil
+}
+
+// loadSessionKeys returns the cookie store key pairs configured with
+// SESSION_KEYS and the older SESSION_KEY. Outside production, random keys are
+// generated when none are configured, so cookies don't survive a restart.
+func loadSessionKeys(sessionKeys, legacyKey string, production bool) ([][]byte, error) {
+	var keyPairs [][]byte
+	if sessionKeys != "" {
+		parsed, err := ParseSessionKeys(sessionKeys)
+		if err != nil {
+			return nil, err
+		}
+		keyPairs = parsed
+	}
+
+	// SESSION_KEY holds a plain authentication key without encryption. It's
+	// kept after SESSION_KEYS so cookies signed with it stay valid while
+	// migrating.
+	if legacyKey != "" {
+		if production && (legacyKey == legacyDefaultKey || len(legacyKey) < minAuthKeySize) {
+			return nil, fmt.Errorf("SESSION_KEY must be at least %d bytes and not the old default key", minAuthKeySize)
+		}
+		keyPairs = append(keyPairs, []byte(legacyKey), nil)
+	}
+
+	if len(keyPairs) > 0 {
+		return keyPairs, nil
+	}
+	if production {
+		return nil, ErrNoSessionKeys
+	}
+
+	entry, err := GenerateSessionKey()
+	if err != nil {
+		return nil, err
+	}
+	return ParseSessionKeys(entry)
+}
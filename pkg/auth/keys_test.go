@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestParseSessionKeys(t *testing.T) {
+	authKey := strings.Repeat("QUJD", 16)      // 48 bytes
+	encryptionKey := strings.Repeat("QUJD", 8) // 24 bytes
+
+	testCases := []struct {
+		name  string
+		value string
+		pairs int
+		valid bool
+	}{
+		{"Authentication key only", authKey, 1, true},
+		{"Authentication and encryption keys", authKey + ":" + encryptionKey, 1, true},
+		{"Rotated keys", authKey + ":" + encryptionKey + ", " + authKey, 2, true},
+		{"Empty", " , ", 0, false},
+		{"Not base64", "not base64!", 0, false},
+		{"Short authentication key", "QUJD", 0, false},
+		{"Bad encryption key size", authKey + ":QUJD", 0, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			keyPairs, err := ParseSessionKeys(tc.value)
+			if tc.valid != (err == nil) {
+				t.Fatalf("Expected valid to be %t, got error: %v", tc.valid, err)
+			}
+			if len(keyPairs) != tc.pairs*2 {
+				t.Errorf("Expected %d key pairs, got: %d keys", tc.pairs, len(keyPairs))
+			}
+		})
+	}
+}
+
+func TestLoadSessionKeys(t *testing.T) {
+	if _, err := loadSessionKeys("", "", true); err != ErrNoSessionKeys {
+		t.Errorf("Expected production to refuse missing keys, got: %v", err)
+	}
+	if _, err := loadSessionKeys("", legacyDefaultKey, true); err == nil {
+		t.Error("Expected production to refuse the old default key")
+	}
+	if keyPairs, err := loadSessionKeys("", "", false); err != nil || len(keyPairs) != 2 || len(keyPairs[1]) != EncryptionKeySize {
+		t.Errorf("Expected random keys outside production, got: %d keys, error: %v", len(keyPairs), err)
+	}
+}
+
+func TestSessionKeyRotation(t *testing.T) {
+	oldKey, _ := GenerateSessionKey()
+	newKey, _ := GenerateSessionKey()
+
+	oldPairs, _ := ParseSessionKeys(oldKey)
+	rotatedPairs, _ := ParseSessionKeys(newKey + "," + oldKey)
+	newPairs, _ := ParseSessionKeys(newKey)
+
+	// Issue a cookie before the rotation
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/", nil)
+	session, _ := sessions.NewCookieStore(oldPairs...).New(request, SessionName)
+	session.Values[SessionKey] = "session-1"
+	if err := session.Save(request, recorder); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+	cookie := recorder.Result().Cookies()[0]
+
+	request = httptest.NewRequest("GET", "/", nil)
+	request.AddCookie(cookie)
+	session, err := sessions.NewCookieStore(rotatedPairs...).Get(request, SessionName)
+	if err != nil || session.Values[SessionKey] != "session-1" {
+		t.Errorf("Expected cookie to decode with the previous key still configured, got error: %v", err)
+	}
+
+	request = httptest.NewRequest("GET", "/", nil)
+	request.AddCookie(cookie)
+	if _, err := sessions.NewCookieStore(newPairs...).Get(request, SessionName); err == nil {
+		t.Error("Expected cookie to be rejected once the previous key is dropped")
+	}
+}
+
+func TestSecureCookies(t *testing.T) {
+	defer func() { secureCookies, trustProxy = false, false }()
+
+	request := httptest.NewRequest("GET", "/", nil)
+	request.Header.Set("X-Forwarded-Proto", "https")
+	if SecureCookies(request) {
+		t.Error("Expected forwarded protocol to be ignored without a trusted proxy")
+	}
+
+	trustProxy = true
+	if !SecureCookies(request) {
+		t.Error("Expected Secure cookies behind a TLS-terminating proxy")
+	}
+
+	trustProxy = false
+	request = httptest.NewRequest("GET", "/", nil)
+	request.TLS = &tls.ConnectionState{}
+	if !SecureCookies(request) {
+		t.Error("Expected Secure cookies over TLS")
+	}
+
+	secureCookies = true
+	if !SecureCookies(httptest.NewRequest("GET", "/", nil)) {
+		t.Error("Expected Secure cookies when forced")
+	}
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// Principal is the authenticated user of a request along with the credential
+// they used: a browser session or a personal access token
+type Principal struct {
+	User    *models.User
+	Session *models.Session     // set when authenticated with the session cookie
+	Token   *models.AccessToken // set when authenticated with a bearer token
+}
+
+// UserID returns the ID of the authenticated user
+func (p *Principal) UserID() string {
+	return p.User.ID
+}
+
+// Role returns the global role of the authenticated user
+func (p *Principal) Role() string {
+	if p.User.Role == "" {
+		return models.RoleUser
+	}
+	return p.User.Role
+}
+
+// HasRole reports whether the principal has one of the given roles
+func (p *Principal) HasRole(roles ...string) bool {
+	role := p.Role()
+	for _, allowed := range roles {
+		if role == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the principal's role grants a permission
+func (p *Principal) Can(permission models.Permission) bool {
+	return p.User.Can(permission)
+}
+
+// Actor returns the user that services should authorize the request as.
+// Access tokens without the admin scope act with the permissions of a
+// regular user, even when their owner is an administrator.
+func (p *Principal) Actor() *models.User {
+	if p.Token == nil || p.Token.HasScope(models.ScopeAdmin) || p.Role() != models.RoleAdmin {
+		return p.User
+	}
+
+	restricted := *p.User
+	restricted.Role = models.RoleUser
+	return &restricted
+}
+
+type principalContextKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal stored in ctx, if any
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// GetPrincipal returns the principal resolved for the request by the auth
+// middleware, or nil if the request isn't authenticated
+func GetPrincipal(r *http.Request) *Principal {
+	principal, _ := PrincipalFromContext(r.Context())
+	return principal
+}
+
+// ResolveCredentials validates the bearer token or session cookie of a
+// request. Exactly one of the returned session and token is set on success.
+func ResolveCredentials(r *http.Request) (*models.Session, *models.AccessToken, error) {
+	// A bearer token takes precedence, and an invalid one doesn't fall back to the cookie
+	token, err := GetToken(r)
+	if err == nil {
+		return nil, token, nil
+	}
+	if err != ErrNoToken {
+		return nil, nil, err
+	}
+
+	session, err := GetSession(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return session, nil, nil
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+func TestPrincipalActor(t *testing.T) {
+	admin := &models.User{ID: "admin", Role: models.RoleAdmin}
+	user := &models.User{ID: "user", Role: models.RoleUser}
+
+	testCases := []struct {
+		name      string
+		principal *Principal
+		role      string
+	}{
+		{"Admin with session", &Principal{User: admin, Session: &models.Session{}}, models.RoleAdmin},
+		{"Admin with admin token", &Principal{User: admin, Token: &models.AccessToken{Scopes: []string{models.ScopeAdmin}}}, models.RoleAdmin},
+		{"Admin with write token", &Principal{User: admin, Token: &models.AccessToken{Scopes: []string{models.ScopeWrite}}}, models.RoleUser},
+		{"User with admin token", &Principal{User: user, Token: &models.AccessToken{Scopes: []string{models.ScopeAdmin}}}, models.RoleUser},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if role := tc.principal.Actor().Role; role != tc.role {
+				t.Errorf("Expected actor role %s, got: %s", tc.role, role)
+			}
+		})
+	}
+
+	// Restricting the actor must not change the authenticated user
+	(&Principal{User: admin, Token: &models.AccessToken{Scopes: []string{models.ScopeRead}}}).Actor()
+	if admin.Role != models.RoleAdmin {
+		t.Errorf("Expected the user's role to be left alone, got: %s", admin.Role)
+	}
+}
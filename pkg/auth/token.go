@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// TokenPrefix marks personal access tokens, so leaked tokens are easy to recognize and scan for
+const TokenPrefix = "cgp_"
+
+// Number of characters of a token kept in plaintext to identify it
+const tokenDisplayLength = len(TokenPrefix) + 6
+
+// ErrNoToken is returned when a request doesn't carry a bearer token
+var ErrNoToken = errors.New("no bearer token")
+
+// TokenStore looks up personal access tokens by the hash of their value
+type TokenStore interface {
+	GetByHash(hash string) (*models.AccessToken, error)
+	Touch(id string, lastUsedAt time.Time) error
+}
+
+var tokenStore TokenStore
+
+// GenerateToken creates a new random token. It returns the plaintext token,
+// which is shown to the user once, its display prefix and the hash to store.
+func GenerateToken() (token, prefix, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+
+	token = TokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return token, token[:tokenDisplayLength], HashToken(token), nil
+}
+
+// HashToken returns the hash under which a token is stored. Tokens are long
+// random values, so a fast hash is enough; unlike passwords they can't be guessed.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// HasAuthorizationHeader reports whether a request carries credentials in the
+// Authorization header. Such requests are never authenticated by the session
+// cookie, even if the header is invalid.
+func HasAuthorizationHeader(r *http.Request) bool {
+	_, ok := bearerToken(r)
+	return ok
+}
+
+// bearerToken extracts the token from the Authorization header
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", false
+	}
+
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", true
+	}
+	return strings.TrimSpace(token), true
+}
+
+// GetToken retrieves the active access token the request was authenticated
+// with. It returns ErrNoToken for requests without an Authorization header.
+func GetToken(r *http.Request) (*models.AccessToken, error) {
+	value, ok := bearerToken(r)
+	if !ok {
+		return nil, ErrNoToken
+	}
+	if !strings.HasPrefix(value, TokenPrefix) {
+		return nil, ErrNotAuthenticated
+	}
+
+	token, err := tokenStore.GetByHash(HashToken(value))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Failed to load access token: %v", err)
+		}
+		return nil, ErrNotAuthenticated
+	}
+
+	now := time.Now()
+	if !token.Active(now) {
+		return nil, ErrNotAuthenticated
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > touchInterval {
+		if err := tokenStore.Touch(token.ID, now); err != nil {
+			log.Printf("Failed to update token last used time: %v", err)
+		}
+		token.LastUsedAt = &now
+	}
+
+	return token, nil
+}
+
+// RequiredScope returns the token scope needed for a request method: reads
+// need the read scope and anything that changes state needs write
+func RequiredScope(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return models.ScopeRead
+	default:
+		return models.ScopeWrite
+	}
+}
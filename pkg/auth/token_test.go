@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"database/sql"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dbvitor/chat-go/internal/models"
+)
+
+// memoryTokenStore is an in-memory TokenStore for tests
+type memoryTokenStore struct {
+	tokens map[string]*models.AccessToken // by hash
+	mutex  sync.Mutex
+}
+
+func newMemoryTokenStore() *memoryTokenStore {
+	return &memoryTokenStore{tokens: make(map[string]*models.AccessToken)}
+}
+
+func (m *memoryTokenStore) add(token *models.AccessToken) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.tokens[token.TokenHash] = token
+}
+
+func (m *memoryTokenStore) GetByHash(hash string) (*models.AccessToken, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	token, ok := m.tokens[hash]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (m *memoryTokenStore) Touch(id string, lastUsedAt time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, token := range m.tokens {
+		if token.ID == id {
+			token.LastUsedAt = &lastUsedAt
+		}
+	}
+	return nil
+}
+
+func TestGenerateToken(t *testing.T) {
+	token, prefix, hash, err := GenerateToken()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if !strings.HasPrefix(token, TokenPrefix) || !strings.HasPrefix(token, prefix) {
+		t.Errorf("Expected token %q to start with %q", token, prefix)
+	}
+	if hash != HashToken(token) || strings.Contains(hash, token) {
+		t.Errorf("Expected hash to be derived from the token without containing it")
+	}
+
+	other, _, _, _ := GenerateToken()
+	if other == token {
+		t.Errorf("Expected tokens to be random")
+	}
+}
+
+func TestBearerAuthentication(t *testing.T) {
+	tokens := newMemoryTokenStore()
+	Initialize(newMemoryStore(), tokens)
+
+	value, prefix, hash, _ := GenerateToken()
+	tokens.add(&models.AccessToken{
+		ID:        "token-1",
+		UserID:    "user-1",
+		Scopes:    []string{models.ScopeRead},
+		Prefix:    prefix,
+		TokenHash: hash,
+	})
+
+	request := httptest.NewRequest("GET", "/api/chatrooms", nil)
+	request.Header.Set("Authorization", "Bearer "+value)
+
+	userID, err := GetAuthenticatedUser(request)
+	if err != nil || userID != "user-1" {
+		t.Fatalf("Expected user-1, got: %s (%v)", userID, err)
+	}
+
+	token, err := GetToken(request)
+	if err != nil {
+		t.Fatalf("Expected token, got: %v", err)
+	}
+	if token.HasScope(RequiredScope("POST")) {
+		t.Errorf("Expected read token not to allow writes")
+	}
+	if tokens.tokens[hash].LastUsedAt == nil {
+		t.Errorf("Expected token use to be recorded")
+	}
+
+	// Invalid tokens don't authenticate
+	for _, header := range []string{"Bearer cgp_wrong", "Bearer nope", "Basic dXNlcjpwYXNz", "Bearer"} {
+		request.Header.Set("Authorization", header)
+		if IsAuthenticated(request) {
+			t.Errorf("Expected %q not to authenticate", header)
+		}
+	}
+
+	// Expired tokens don't authenticate
+	expired := time.Now().Add(-time.Minute)
+	tokens.tokens[hash].ExpiresAt = &expired
+	request.Header.Set("Authorization", "Bearer "+value)
+	if IsAuthenticated(request) {
+		t.Errorf("Expected expired token not to authenticate")
+	}
+
+	// Requests without a token report ErrNoToken
+	if _, err := GetToken(httptest.NewRequest("GET", "/", nil)); err != ErrNoToken {
+		t.Errorf("Expected ErrNoToken, got: %v", err)
+	}
+}
+
+func TestAccessToken_HasScope(t *testing.T) {
+	testCases := []struct {
+		granted  []string
+		required string
+		expected bool
+	}{
+		{[]string{models.ScopeRead}, models.ScopeRead, true},
+		{[]string{models.ScopeRead}, models.ScopeWrite, false},
+		{[]string{models.ScopeWrite}, models.ScopeRead, true},
+		{[]string{models.ScopeWrite}, models.ScopeAdmin, false},
+		{[]string{models.ScopeAdmin}, models.ScopeWrite, true},
+		{[]string{models.ScopeRead}, "unknown", false},
+		{nil, models.ScopeRead, false},
+	}
+
+	for _, tc := range testCases {
+		token := &models.AccessToken{Scopes: tc.granted}
+		if result := token.HasScope(tc.required); result != tc.expected {
+			t.Errorf("Expected %v granting %s to be %t, got: %t", tc.granted, tc.required, tc.expected, result)
+		}
+	}
+}
@@ -101,12 +101,47 @@ func NewRabbitMQ() (*RabbitMQ, error) {
 		return nil, err
 	}
 
+	// Creates queues for link preview requests and results
+	for _, queue := range []string{unfurlQueue(), unfurlResultQueue()} {
+		_, err = channel.QueueDeclare(
+			queue, // name
+			true,  // durable
+			false, // delete when unused
+			false, // exclusive
+			false, // no-wait
+			nil,   // arguments
+		)
+		if err != nil {
+			channel.Close()
+			conn.Close()
+			return nil, err
+		}
+	}
+
 	return &RabbitMQ{
 		conn:    conn,
 		channel: channel,
 	}, nil
 }
 
+// Queue for link preview requests (from .env or uses default)
+func unfurlQueue() string {
+	queue := os.Getenv("RABBITMQ_UNFURL_QUEUE")
+	if queue == "" {
+		queue = "unfurl_requests"
+	}
+	return queue
+}
+
+// Queue for link preview results (from .env or uses default)
+func unfurlResultQueue() string {
+	queue := os.Getenv("RABBITMQ_UNFURL_RESULT_QUEUE")
+	if queue == "" {
+		queue = "unfurl_results"
+	}
+	return queue
+}
+
 // Closes connection and releases resources
 func (r *RabbitMQ) Close() error {
 	if r.channel != nil {
@@ -226,3 +261,63 @@ func (r *RabbitMQ) ConsumeStockResults() (<-chan amqp.Delivery, error) {
 		nil,                                // args
 	)
 }
+
+// Sends a link preview request to the unfurler worker
+func (r *RabbitMQ) PublishUnfurlRequest(request *models.UnfurlRequest) error {
+	return r.publishJSON(unfurlQueue(), request)
+}
+
+// Sends a link preview back to the server
+func (r *RabbitMQ) PublishUnfurlResult(result *models.UnfurlResult) error {
+	return r.publishJSON(unfurlResultQueue(), result)
+}
+
+// Receives link preview requests (used by the unfurler)
+func (r *RabbitMQ) ConsumeUnfurlRequests() (<-chan amqp.Delivery, error) {
+	return r.channel.Consume(
+		unfurlQueue(), // queue
+		"",            // consumer
+		true,          // auto-ack
+		false,         // exclusive
+		false,         // no-local
+		false,         // no-wait
+		nil,           // args
+	)
+}
+
+// Receives link preview results (used by the server)
+func (r *RabbitMQ) ConsumeUnfurlResults() (<-chan amqp.Delivery, error) {
+	return r.channel.Consume(
+		unfurlResultQueue(), // queue
+		"",                  // consumer
+		true,                // auto-ack
+		false,               // exclusive
+		false,               // no-local
+		false,               // no-wait
+		nil,                 // args
+	)
+}
+
+// Marshals a value to JSON and publishes it to a queue
+func (r *RabbitMQ) publishJSON(queue string, value interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	body, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return r.channel.PublishWithContext(
+		ctx,
+		"",    // exchange
+		queue, // routing key
+		false, // mandatory
+		false, // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			Body:         body,
+		},
+	)
+}
@@ -0,0 +1,93 @@
+// Package imaging decodes uploaded images and scales them down to
+// thumbnails, using only the decoders of the standard library
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif"  // register the GIF decoder
+	_ "image/jpeg" // register the JPEG decoder
+	_ "image/png"  // register the PNG decoder
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrTooManyPixels     = errors.New("image has too many pixels")
+)
+
+// Decode decodes a PNG, JPEG or GIF image. The dimensions are checked before
+// the pixels are decoded, so small files declaring huge images are refused
+// without allocating memory for them.
+func Decode(data []byte, maxPixels int) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return nil, ErrUnsupportedFormat
+		}
+		return nil, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width > maxPixels/config.Height {
+		return nil, ErrTooManyPixels
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// Thumbnail crops the center square of an image and scales it to size x
+// size pixels. Each pixel of the thumbnail averages the source pixels it
+// covers, which keeps downscaled photos smooth; smaller images are enlarged
+// by repeating pixels.
+func Thumbnail(src image.Image, size int) *image.RGBA {
+	// Crop the largest centered square
+	bounds := src.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	origin := image.Pt(bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2)
+
+	// Work on premultiplied RGBA pixels, so transparent pixels don't bleed
+	// their color into the average
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), src, origin, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0, y1 := span(y, size, side)
+		for x := 0; x < size; x++ {
+			x0, x1 := span(x, size, side)
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := square.Pix[sy*square.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+
+			count := (y1 - y0) * (x1 - x0)
+			offset := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[offset+c] = uint8((sum[c] + count/2) / count)
+			}
+		}
+	}
+
+	return dst
+}
+
+// span returns the range of source pixels covered by destination pixel i
+// when scaling src pixels to dst pixels, always at least one pixel wide
+func span(i, dst, src int) (int, int) {
+	start := i * src / dst
+	end := (i + 1) * src / dst
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
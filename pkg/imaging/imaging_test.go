@@ -0,0 +1,81 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	data := encodePNG(t, image.NewRGBA(image.Rect(0, 0, 40, 30)))
+
+	img, err := Decode(data, 40*30)
+	if err != nil || img.Bounds().Dx() != 40 || img.Bounds().Dy() != 30 {
+		t.Errorf("Expected a 40x30 image, got: %v, error: %v", img, err)
+	}
+
+	if _, err := Decode(data, 40*30-1); err != ErrTooManyPixels {
+		t.Errorf("Expected %v, got: %v", ErrTooManyPixels, err)
+	}
+
+	if _, err := Decode([]byte("GIF87a? not really"), 100); err == nil {
+		t.Error("Expected an error for a corrupt image")
+	}
+
+	if _, err := Decode([]byte("%PDF-1.4"), 100); err != ErrUnsupportedFormat {
+		t.Errorf("Expected %v, got: %v", ErrUnsupportedFormat, err)
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	// A 300x100 image: red on the left, green in the middle, blue on the right
+	src := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 300; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 100 && x < 200 {
+				c = color.RGBA{G: 255, A: 255}
+			} else if x >= 200 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+
+	thumbnail := Thumbnail(src, 32)
+	if thumbnail.Bounds().Dx() != 32 || thumbnail.Bounds().Dy() != 32 {
+		t.Fatalf("Expected a 32x32 thumbnail, got: %v", thumbnail.Bounds())
+	}
+
+	// Only the green center is kept
+	for _, point := range []image.Point{{0, 0}, {16, 16}, {31, 31}} {
+		if c := thumbnail.RGBAAt(point.X, point.Y); c != (color.RGBA{G: 255, A: 255}) {
+			t.Errorf("Expected green at %v, got: %v", point, c)
+		}
+	}
+
+	// Pixels are averaged when scaling down
+	checkers := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	checkers.Set(0, 0, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	checkers.Set(1, 1, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	checkers.Set(1, 0, color.RGBA{A: 255})
+	checkers.Set(0, 1, color.RGBA{A: 255})
+	if c := Thumbnail(checkers, 1).RGBAAt(0, 0); c.R != 128 || c.A != 255 {
+		t.Errorf("Expected gray, got: %v", c)
+	}
+
+	// Small images are enlarged
+	if c := Thumbnail(checkers, 4).RGBAAt(3, 0); c.R != 0 {
+		t.Errorf("Expected black in the top right corner, got: %v", c)
+	}
+}
@@ -0,0 +1,255 @@
+// Package markdown renders the small Markdown subset supported in chat
+// messages (bold, inline code, code blocks and links) to sanitized HTML.
+//
+// Everything that isn't recognized markup is HTML-escaped, so raw HTML in a
+// message is shown as text. Links are only produced for http, https and
+// mailto URLs; anything else is rendered as plain text.
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Schemes that may appear in rendered links
+var allowedSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+var (
+	fenceOpenPattern  = regexp.MustCompile("^```([A-Za-z0-9_+-]*)\\s*$")
+	fenceClosePattern = regexp.MustCompile("^```\\s*$")
+)
+
+// Render converts message content to sanitized HTML
+func Render(content string) string {
+	content = stripUnsafeRunes(content)
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	var out strings.Builder
+	var paragraph []string
+
+	flushParagraph := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		out.WriteString("<p>")
+		for i, line := range paragraph {
+			if i > 0 {
+				out.WriteString("<br>")
+			}
+			out.WriteString(renderInline(line))
+		}
+		out.WriteString("</p>")
+		paragraph = nil
+	}
+
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		// Fenced code block; an unclosed fence runs to the end of the message
+		if match := fenceOpenPattern.FindStringSubmatch(line); match != nil {
+			flushParagraph()
+
+			var code []string
+			for i++; i < len(lines) && !fenceClosePattern.MatchString(lines[i]); i++ {
+				code = append(code, lines[i])
+			}
+
+			out.WriteString("<pre><code")
+			if match[1] != "" {
+				out.WriteString(` class="language-` + html.EscapeString(strings.ToLower(match[1])) + `"`)
+			}
+			out.WriteString(">")
+			out.WriteString(html.EscapeString(strings.Join(code, "\n")))
+			out.WriteString("</code></pre>")
+			continue
+		}
+
+		// Blank lines separate paragraphs
+		if strings.TrimSpace(line) == "" {
+			flushParagraph()
+			continue
+		}
+
+		paragraph = append(paragraph, line)
+	}
+	flushParagraph()
+
+	return out.String()
+}
+
+// renderInline renders bold text, inline code and links within a line
+func renderInline(text string) string {
+	return renderSpans(text, true)
+}
+
+// renderSpans renders inline markup; links are disabled inside link labels
+// so anchors are never nested
+func renderSpans(text string, links bool) string {
+	var out strings.Builder
+
+	for i := 0; i < len(text); {
+		rest := text[i:]
+
+		switch {
+		// Inline code: contents are never interpreted
+		case rest[0] == '`':
+			if end := strings.IndexByte(rest[1:], '`'); end > 0 {
+				out.WriteString("<code>" + html.EscapeString(rest[1:end+1]) + "</code>")
+				i += end + 2
+				continue
+			}
+
+		// Bold: the markers must hug the text, so "2 ** 3" stays as is
+		case strings.HasPrefix(rest, "**") && len(rest) > 2 && !isSpaceByte(rest[2]):
+			if end := closingBold(rest[2:]); end > 0 {
+				out.WriteString("<strong>" + renderSpans(rest[2:end+2], links) + "</strong>")
+				i += end + 4
+				continue
+			}
+
+		// Link: [text](url)
+		case links && rest[0] == '[':
+			if rendered, length, ok := renderLink(rest); ok {
+				out.WriteString(rendered)
+				i += length
+				continue
+			}
+
+		// Bare URL, not in the middle of a word
+		case links && startsURL(rest) && (i == 0 || !isWordByte(text[i-1])):
+			rawURL := scanURL(rest)
+			if isSafeURL(rawURL) {
+				out.WriteString(anchor(rawURL, html.EscapeString(rawURL)))
+				i += len(rawURL)
+				continue
+			}
+		}
+
+		// Plain text up to the next possible markup character
+		next := strings.IndexAny(rest[1:], "`*[h")
+		if next == -1 {
+			next = len(rest) - 1
+		}
+		out.WriteString(html.EscapeString(rest[:next+1]))
+		i += next + 1
+	}
+
+	return out.String()
+}
+
+// closingBold returns the index of the "**" closing bold text, or -1
+func closingBold(text string) int {
+	for offset := 0; ; {
+		end := strings.Index(text[offset:], "**")
+		if end == -1 {
+			return -1
+		}
+		end += offset
+		if end > 0 && !isSpaceByte(text[end-1]) {
+			return end
+		}
+		offset = end + 2
+	}
+}
+
+// renderLink renders a [text](url) link at the start of text. It returns the
+// HTML, the number of bytes consumed and whether a safe link was found.
+func renderLink(text string) (string, int, bool) {
+	closeText := strings.Index(text, "](")
+	if closeText < 1 {
+		return "", 0, false
+	}
+
+	closeURL := strings.IndexByte(text[closeText+2:], ')')
+	if closeURL < 1 {
+		return "", 0, false
+	}
+
+	label := text[1:closeText]
+	rawURL := strings.TrimSpace(text[closeText+2 : closeText+2+closeURL])
+	if strings.ContainsAny(label, "[]") || !isSafeURL(rawURL) {
+		return "", 0, false
+	}
+
+	return anchor(rawURL, renderSpans(label, false)), closeText + 2 + closeURL + 1, true
+}
+
+// anchor builds a link that opens in a new tab without giving it access to the opener
+func anchor(rawURL, label string) string {
+	return `<a href="` + html.EscapeString(rawURL) + `" target="_blank" rel="nofollow noopener noreferrer">` + label + "</a>"
+}
+
+// isSafeURL reports whether a URL is absolute and uses an allowed scheme
+func isSafeURL(rawURL string) bool {
+	if rawURL == "" || strings.ContainsAny(rawURL, " \t\n<>\"'`") {
+		return false
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	scheme := strings.ToLower(parsed.Scheme)
+	if !allowedSchemes[scheme] {
+		return false
+	}
+	if scheme != "mailto" && parsed.Host == "" {
+		return false
+	}
+	return true
+}
+
+func startsURL(text string) bool {
+	return strings.HasPrefix(text, "http://") || strings.HasPrefix(text, "https://")
+}
+
+// scanURL returns the bare URL at the start of text, without trailing punctuation
+func scanURL(text string) string {
+	end := strings.IndexFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '<' || r == '>' || r == '"' || r == '`'
+	})
+	if end == -1 {
+		end = len(text)
+	}
+
+	rawURL := strings.TrimRight(text[:end], ".,;:!?'*")
+
+	// Keep closing parentheses only when they're balanced, e.g. in Wikipedia links
+	for strings.HasSuffix(rawURL, ")") && strings.Count(rawURL, ")") > strings.Count(rawURL, "(") {
+		rawURL = strings.TrimSuffix(rawURL, ")")
+	}
+	return rawURL
+}
+
+func isSpaceByte(b byte) bool {
+	return b == ' ' || b == '\t'
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b == '/' || b == ':' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// stripUnsafeRunes removes control characters and bidirectional overrides,
+// which can be used to disguise links or garble the surrounding page
+func stripUnsafeRunes(content string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t' || r == '\r':
+			return r
+		case unicode.IsControl(r):
+			return -1
+		case r >= 0x202A && r <= 0x202E, r >= 0x2066 && r <= 0x2069:
+			return -1
+		}
+		return r
+	}, content)
+}
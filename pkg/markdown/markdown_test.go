@@ -0,0 +1,70 @@
+package markdown
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Run with -update to regenerate the golden files after an intended change
+var update = flag.Bool("update", false, "update golden files")
+
+func TestRender_Golden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.md"))
+	if err != nil {
+		t.Fatalf("Failed to list test cases: %v", err)
+	}
+	if len(inputs) == 0 {
+		t.Fatalf("No test cases found in testdata")
+	}
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".md")
+		t.Run(name, func(t *testing.T) {
+			content, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatalf("Failed to read input: %v", err)
+			}
+
+			result := Render(strings.TrimSuffix(string(content), "\n")) + "\n"
+
+			golden := strings.TrimSuffix(input, ".md") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(result), 0o644); err != nil {
+					t.Fatalf("Failed to update golden file: %v", err)
+				}
+			}
+
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("Failed to read golden file (run with -update to create it): %v", err)
+			}
+
+			if result != string(expected) {
+				t.Errorf("Output mismatch for %s\nExpected:\n%s\nGot:\n%s", name, expected, result)
+			}
+		})
+	}
+}
+
+func TestRender_NeverEmitsRawTags(t *testing.T) {
+	inputs := []string{
+		"<b>hi</b>",
+		"**<i>x</i>**",
+		"[<svg onload=alert(1)>](https://example.com)",
+		"`</code><script>`",
+		"```\n</pre><script>alert(1)</script>\n```",
+		"https://example.com/<script>",
+	}
+
+	for _, input := range inputs {
+		result := Render(input)
+		for _, tag := range []string{"<script", "<svg", "<i>", "<b>", "</pre><script"} {
+			if strings.Contains(result, tag) {
+				t.Errorf("Render(%q) emitted raw %s: %s", input, tag, result)
+			}
+		}
+	}
+}
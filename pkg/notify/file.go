@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileNotifier appends notifications to a file, one JSON object per line
+type FileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+// fileEntry is a line of the notifications file
+type fileEntry struct {
+	Time      time.Time `json:"time"`
+	UserID    string    `json:"user_id"`
+	Recipient string    `json:"recipient"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+}
+
+// NewFileNotifier creates a file notifier writing to path, creating its directory if needed
+func NewFileNotifier(path string) (*FileNotifier, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create notifications directory: %w", err)
+	}
+	return &FileNotifier{path: path}, nil
+}
+
+// Send implements Notifier
+func (n *FileNotifier) Send(ctx context.Context, message Message) error {
+	line, err := json.Marshal(fileEntry{
+		Time:      time.Now(),
+		UserID:    message.UserID,
+		Recipient: message.Recipient,
+		Subject:   message.Subject,
+		Body:      message.Body,
+	})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	// Notifications contain secrets such as reset links, so the file is private
+	file, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,21 @@
+package notify
+
+import (
+	"context"
+	"log"
+)
+
+// LogNotifier writes notifications to the server log. It is meant for local
+// development, where there is nowhere else to deliver them.
+type LogNotifier struct{}
+
+// NewLogNotifier creates a log notifier
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+// Send implements Notifier
+func (n *LogNotifier) Send(ctx context.Context, message Message) error {
+	log.Printf("Notification for %s: %s\n%s", message.Recipient, message.Subject, message.Body)
+	return nil
+}
@@ -0,0 +1,36 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// Message is a notification addressed to a single user
+type Message struct {
+	UserID    string
+	Recipient string // username of the user; delivery backends may map it to an address
+	Subject   string
+	Body      string
+}
+
+// Notifier delivers notifications to users outside the chat, e.g. password
+// reset links
+type Notifier interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// New creates the notifier of the given backend, "log" or "file". The file
+// backend writes to path.
+func New(backend, path string) (Notifier, error) {
+	switch backend {
+	case "log":
+		log.Println("Using log notifier; notifications are written to the server log")
+		return NewLogNotifier(), nil
+	case "file":
+		log.Printf("Using file notifier, writing to %s", path)
+		return NewFileNotifier(path)
+	default:
+		return nil, fmt.Errorf("unknown notifier backend: %s", backend)
+	}
+}
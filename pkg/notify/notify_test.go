@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "notifications.log")
+	notifier, err := NewFileNotifier(path)
+	if err != nil {
+		t.Fatalf("Expected no error creating notifier, got: %v", err)
+	}
+
+	messages := []Message{
+		{UserID: "1", Recipient: "alice", Subject: "First", Body: "line one\nline two"},
+		{UserID: "2", Recipient: "bob", Subject: "Second", Body: "hello"},
+	}
+	for _, message := range messages {
+		if err := notifier.Send(context.Background(), message); err != nil {
+			t.Fatalf("Expected no error sending, got: %v", err)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected notifications file to exist, got: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected file mode 0600, got: %o", info.Mode().Perm())
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var entries []fileEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry fileEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Expected a JSON object per line, got: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) != len(messages) {
+		t.Fatalf("Expected %d entries, got: %d", len(messages), len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Recipient != messages[i].Recipient || entry.Body != messages[i].Body {
+			t.Errorf("Expected entry %d to match %+v, got: %+v", i, messages[i], entry)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New("carrier-pigeon", ""); err == nil {
+		t.Error("Expected error for unknown backend")
+	}
+
+	notifier, err := New("log", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, ok := notifier.(*LogNotifier); !ok {
+		t.Errorf("Expected log notifier, got: %T", notifier)
+	}
+
+	notifier, err = New("file", filepath.Join(t.TempDir(), "notifications.log"))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, ok := notifier.(*FileNotifier); !ok {
+		t.Errorf("Expected file notifier, got: %T", notifier)
+	}
+}
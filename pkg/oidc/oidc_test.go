@@ -0,0 +1,232 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dbvitor/chat-go/pkg/oidc/oidctest"
+)
+
+const redirectURL = "http://chat.test/api/auth/oidc/callback"
+
+func newTestProvider(t *testing.T) (*Provider, *oidctest.Provider) {
+	t.Helper()
+
+	fake := oidctest.NewProvider(t)
+	provider, err := NewProvider(Config{
+		IssuerURL:    fake.Issuer(),
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"profile", "email"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error creating provider, got: %v", err)
+	}
+	return provider, fake
+}
+
+// login runs the authorization code flow against the fake provider
+func login(t *testing.T, provider *Provider, fake *oidctest.Provider, claims map[string]interface{}) (*Claims, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	state, _ := RandomString()
+	nonce, _ := RandomString()
+	verifier, _ := RandomString()
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("Expected no error building authorization URL, got: %v", err)
+	}
+
+	callback := fake.Authorize(t, authURL, claims)
+	if callback.Query().Get("state") != state {
+		t.Fatalf("Expected state to round-trip, got: %s", callback.Query().Get("state"))
+	}
+
+	tokens, err := provider.Exchange(ctx, callback.Query().Get("code"), verifier)
+	if err != nil {
+		t.Fatalf("Expected no error exchanging code, got: %v", err)
+	}
+
+	return provider.VerifyIDToken(ctx, tokens.IDToken, nonce)
+}
+
+func TestLoginFlow(t *testing.T) {
+	provider, fake := newTestProvider(t)
+
+	claims, err := login(t, provider, fake, map[string]interface{}{
+		"sub":                "user-123",
+		"email":              "alice@example.com",
+		"preferred_username": "alice",
+		"groups":             []string{"staff", "chat-admins"},
+	})
+	if err != nil {
+		t.Fatalf("Expected valid ID token, got: %v", err)
+	}
+
+	if claims.Subject != "user-123" || claims.PreferredUsername != "alice" || claims.Email != "alice@example.com" {
+		t.Errorf("Expected claims of alice, got: %+v", claims)
+	}
+	if len(claims.Groups) != 2 || claims.Groups[1] != "chat-admins" {
+		t.Errorf("Expected groups, got: %v", claims.Groups)
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	provider, fake := newTestProvider(t)
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	parsed, _ := url.Parse(authURL)
+	query := parsed.Query()
+	if !strings.HasPrefix(authURL, fake.Issuer()+"/authorize?") {
+		t.Errorf("Expected discovered authorization endpoint, got: %s", authURL)
+	}
+	if query.Get("scope") != "openid profile email" {
+		t.Errorf("Expected openid scope first, got: %s", query.Get("scope"))
+	}
+	if query.Get("code_challenge") != CodeChallenge("verifier") || query.Get("redirect_uri") != redirectURL {
+		t.Errorf("Expected PKCE challenge and redirect URI, got: %s", authURL)
+	}
+}
+
+func TestExchange_WrongVerifier(t *testing.T) {
+	provider, fake := newTestProvider(t)
+	ctx := context.Background()
+
+	authURL, _ := provider.AuthCodeURL(ctx, "state", "nonce", "right-verifier")
+	callback := fake.Authorize(t, authURL, map[string]interface{}{"sub": "user-123"})
+
+	// An intercepted code is useless without the verifier
+	_, err := provider.Exchange(ctx, callback.Query().Get("code"), "wrong-verifier")
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("Expected invalid_grant error, got: %v", err)
+	}
+}
+
+func TestVerifyIDToken_Rejected(t *testing.T) {
+	provider, fake := newTestProvider(t)
+
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss":   fake.Issuer(),
+			"sub":   "user-123",
+			"aud":   oidctest.ClientID,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": "nonce",
+		}
+	}
+	with := func(name string, value interface{}) string {
+		claims := valid()
+		claims[name] = value
+		return fake.Sign(claims)
+	}
+
+	// A token signed with another key
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	forged := signES256(t, otherKey, valid())
+
+	// A token without a signature
+	unsignedHeader := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	payload, _ := json.Marshal(valid())
+	unsigned := unsignedHeader + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+
+	// A token whose payload was altered after signing
+	parts := strings.Split(fake.Sign(valid()), ".")
+	altered, _ := json.Marshal(map[string]interface{}{"sub": "admin"})
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString(altered) + "." + parts[2]
+
+	testCases := []struct {
+		name     string
+		token    string
+		expected error
+	}{
+		{"Wrong issuer", with("iss", "https://evil.example.com"), ErrInvalidToken},
+		{"Wrong audience", with("aud", "another-client"), ErrInvalidToken},
+		{"Multiple audiences without authorized party", with("aud", []string{oidctest.ClientID, "other"}), ErrInvalidToken},
+		{"Expired", with("exp", time.Now().Add(-time.Hour).Unix()), ErrTokenExpired},
+		{"Issued in the future", with("iat", time.Now().Add(time.Hour).Unix()), ErrInvalidToken},
+		{"Wrong nonce", with("nonce", "replayed"), ErrInvalidToken},
+		{"Missing subject", with("sub", ""), ErrInvalidToken},
+		{"Signed with an unknown key", forged, ErrInvalidToken},
+		{"Algorithm none", unsigned, ErrInvalidToken},
+		{"Tampered payload", tampered, ErrInvalidToken},
+		{"Malformed", "not-a-token", ErrInvalidToken},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := provider.VerifyIDToken(context.Background(), tc.token, "nonce")
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("Expected %v, got: %v", tc.expected, err)
+			}
+		})
+	}
+
+	// The multiple audience token is accepted when we are the authorized party
+	claims := valid()
+	claims["aud"] = []string{oidctest.ClientID, "other"}
+	claims["azp"] = oidctest.ClientID
+	if _, err := provider.VerifyIDToken(context.Background(), fake.Sign(claims), "nonce"); err != nil {
+		t.Errorf("Expected token with authorized party to be valid, got: %v", err)
+	}
+}
+
+func TestDiscovery_IssuerMismatch(t *testing.T) {
+	fake := oidctest.NewProvider(t)
+
+	// A provider reached through another URL than the issuer it claims
+	provider, _ := NewProvider(Config{
+		IssuerURL:   strings.Replace(fake.Issuer(), "127.0.0.1", "localhost", 1),
+		ClientID:    oidctest.ClientID,
+		RedirectURL: redirectURL,
+	})
+
+	if _, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier"); err == nil {
+		t.Error("Expected discovery to fail when the issuer doesn't match")
+	}
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// Example from RFC 7636, appendix B
+	challenge := CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if challenge != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("Expected RFC 7636 challenge, got: %s", challenge)
+	}
+}
+
+// signES256 creates a token signed with an ECDSA key
+func signES256(t *testing.T, key *ecdsa.PrivateKey, claims map[string]interface{}) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": "other-key"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
@@ -0,0 +1,403 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Allowed difference between our clock and the provider's
+const clockSkew = time.Minute
+
+// Shortest interval between two fetches of the provider's keys, so tokens
+// with unknown key IDs can't make us hammer the provider
+const minKeyRefreshInterval = time.Minute
+
+var (
+	ErrInvalidToken = errors.New("invalid ID token")
+	ErrTokenExpired = errors.New("ID token has expired")
+)
+
+// Claims are the claims of a validated ID token
+type Claims struct {
+	Issuer            string
+	Subject           string
+	Audience          []string
+	Expiry            time.Time
+	IssuedAt          time.Time
+	Nonce             string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	Groups            []string
+}
+
+// rawClaims is the JSON payload of an ID token
+type rawClaims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	AuthorizedParty   string   `json:"azp"`
+	Expiry            int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     bool     `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+}
+
+// audience is the "aud" claim, which is either a string or an array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+// header is the JOSE header of a token
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// Signature algorithms accepted for ID tokens. "none" and the HMAC algorithms
+// are deliberately missing.
+var algorithms = map[string]struct {
+	hash  crypto.Hash
+	kty   string
+	curve string // for ECDSA, the curve the algorithm is defined for
+}{
+	"RS256": {crypto.SHA256, "RSA", ""},
+	"RS384": {crypto.SHA384, "RSA", ""},
+	"RS512": {crypto.SHA512, "RSA", ""},
+	"ES256": {crypto.SHA256, "EC", "P-256"},
+	"ES384": {crypto.SHA384, "EC", "P-384"},
+}
+
+// VerifyIDToken checks the signature and claims of an ID token returned by
+// Exchange, including that it was issued for the login with the given nonce
+func (p *Provider) VerifyIDToken(ctx context.Context, token, nonce string) (*Claims, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var head header
+	if err := decodeSegment(parts[0], &head); err != nil {
+		return nil, fmt.Errorf("%w: malformed header", ErrInvalidToken)
+	}
+	algorithm, ok := algorithms[head.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, head.Algorithm)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+
+	key, err := p.keys.get(ctx, p, head.KeyID, algorithm.kty)
+	if err != nil {
+		return nil, err
+	}
+	if ecKey, ok := key.(*ecdsa.PublicKey); ok && ecKey.Curve.Params().Name != algorithm.curve {
+		return nil, fmt.Errorf("%w: %s key used with %s", ErrInvalidToken, ecKey.Curve.Params().Name, head.Algorithm)
+	}
+
+	hasher := algorithm.hash.New()
+	hasher.Write([]byte(parts[0] + "." + parts[1]))
+	if !verifySignature(key, algorithm.hash, hasher.Sum(nil), signature) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	// The signature is valid, so the payload can be trusted to be the provider's
+	var raw rawClaims
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrInvalidToken)
+	}
+
+	if err := p.checkClaims(&raw, metadata.Issuer, nonce); err != nil {
+		return nil, err
+	}
+
+	var extra map[string]interface{}
+	if err := decodeSegment(parts[1], &extra); err != nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrInvalidToken)
+	}
+
+	return &Claims{
+		Issuer:            raw.Issuer,
+		Subject:           raw.Subject,
+		Audience:          raw.Audience,
+		Expiry:            time.Unix(raw.Expiry, 0),
+		IssuedAt:          time.Unix(raw.IssuedAt, 0),
+		Nonce:             raw.Nonce,
+		Email:             raw.Email,
+		EmailVerified:     raw.EmailVerified,
+		Name:              raw.Name,
+		PreferredUsername: raw.PreferredUsername,
+		Groups:            stringList(extra[p.config.GroupsClaim]),
+	}, nil
+}
+
+// checkClaims validates the registered claims of an ID token (OpenID Connect
+// Core, section 3.1.3.7)
+func (p *Provider) checkClaims(raw *rawClaims, issuer, nonce string) error {
+	if raw.Issuer != issuer {
+		return fmt.Errorf("%w: issuer %q", ErrInvalidToken, raw.Issuer)
+	}
+	if raw.Subject == "" {
+		return fmt.Errorf("%w: no subject", ErrInvalidToken)
+	}
+
+	intended := false
+	for _, aud := range raw.Audience {
+		if aud == p.config.ClientID {
+			intended = true
+		}
+	}
+	if !intended {
+		return fmt.Errorf("%w: not issued for this client", ErrInvalidToken)
+	}
+	if len(raw.Audience) > 1 && raw.AuthorizedParty != p.config.ClientID {
+		return fmt.Errorf("%w: authorized party %q", ErrInvalidToken, raw.AuthorizedParty)
+	}
+
+	now := p.now()
+	if raw.Expiry == 0 || now.After(time.Unix(raw.Expiry, 0).Add(clockSkew)) {
+		return ErrTokenExpired
+	}
+	if time.Unix(raw.IssuedAt, 0).After(now.Add(clockSkew)) {
+		return fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	}
+
+	if raw.Nonce != nonce {
+		return fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+
+	return nil
+}
+
+// verifySignature checks a signature made with an RSA or ECDSA key
+func verifySignature(key crypto.PublicKey, hash crypto.Hash, digest, signature []byte) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		// JWS encodes ECDSA signatures as the two fixed-size integers r and s
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	default:
+		return false
+	}
+}
+
+// decodeSegment decodes a base64url-encoded JSON segment of a token
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// stringList converts a claim holding a string or an array of strings
+func stringList(value interface{}) []string {
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		var list []string
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	default:
+		return nil
+	}
+}
+
+// jsonWebKey is a key of a JWK set (RFC 7517)
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// keySet caches the signing keys of a provider, refetching them when a token
+// names a key it doesn't know, e.g. after the provider rotated its keys
+type keySet struct {
+	url string
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func newKeySet(url string) *keySet {
+	return &keySet{url: url}
+}
+
+// get returns the key with an ID, of the type the token's algorithm needs
+func (k *keySet) get(ctx context.Context, p *Provider, keyID, keyType string) (crypto.PublicKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	key := k.lookup(keyID, keyType)
+	if key == nil && p.now().Sub(k.fetchedAt) >= minKeyRefreshInterval {
+		if err := k.fetch(ctx, p); err != nil {
+			return nil, err
+		}
+		key = k.lookup(keyID, keyType)
+	}
+
+	if key == nil {
+		return nil, fmt.Errorf("%w: unknown signing key %q", ErrInvalidToken, keyID)
+	}
+	return key, nil
+}
+
+// lookup finds a cached key. Tokens without a key ID match the only key of the set.
+func (k *keySet) lookup(keyID, keyType string) crypto.PublicKey {
+	var key crypto.PublicKey
+	if keyID != "" {
+		key = k.keys[keyID]
+	} else if len(k.keys) == 1 {
+		for _, only := range k.keys {
+			key = only
+		}
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey:
+		if keyType == "RSA" {
+			return key
+		}
+	case *ecdsa.PublicKey:
+		if keyType == "EC" {
+			return key
+		}
+	}
+	return nil
+}
+
+// fetch replaces the cached keys with the provider's current ones
+func (k *keySet) fetch(ctx context.Context, p *Provider) error {
+	k.fetchedAt = p.now()
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, k.url, &set); err != nil {
+		return fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			// Skip keys we can't use rather than failing on all of them
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+
+	k.keys = keys
+	return nil
+}
+
+// publicKey decodes an RSA or EC public key
+func (j *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch j.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(j.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", j.Curve)
+		}
+		x, err := decodeBigInt(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", j.KeyType)
+	}
+}
+
+// decodeBigInt decodes a base64url-encoded big-endian integer
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,222 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket that refills at a fixed rate up to a burst size
+type Limiter struct {
+	rate   float64 // tokens added per second
+	burst  float64
+	tokens float64
+	last   time.Time
+	mutex  sync.Mutex
+	now    func() time.Time
+}
+
+// NewLimiter creates a token bucket that starts full
+func NewLimiter(rate float64, burst int) *Limiter {
+	return newLimiter(rate, burst, time.Now)
+}
+
+func newLimiter(rate float64, burst int, now func() time.Time) *Limiter {
+	return &Limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now(),
+		now:    now,
+	}
+}
+
+// Allow consumes a token if one is available
+func (l *Limiter) Allow() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.refill()
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// RetryAfter returns how long until the next token becomes available
+func (l *Limiter) RetryAfter() time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.refill()
+	if l.tokens >= 1 || l.rate <= 0 {
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+// refill adds the tokens accumulated since the last call; caller must hold the mutex
+func (l *Limiter) refill() {
+	now := l.now()
+	elapsed := now.Sub(l.last).Seconds()
+	l.last = now
+
+	l.tokens += elapsed * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
+
+// full reports whether the bucket has completely refilled
+func (l *Limiter) full() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.refill()
+	return l.tokens >= l.burst
+}
+
+// KeyedLimiter keeps an independent token bucket per key (e.g. IP address or user ID)
+type KeyedLimiter struct {
+	rate     float64
+	burst    int
+	limiters map[string]*Limiter
+	mutex    sync.Mutex
+	now      func() time.Time
+}
+
+// NewKeyedLimiter creates a limiter that hands out one bucket per key
+func NewKeyedLimiter(rate float64, burst int) *KeyedLimiter {
+	return &KeyedLimiter{
+		rate:     rate,
+		burst:    burst,
+		limiters: make(map[string]*Limiter),
+		now:      time.Now,
+	}
+}
+
+// Allow consumes a token from the bucket for the given key
+func (k *KeyedLimiter) Allow(key string) bool {
+	return k.get(key).Allow()
+}
+
+// RetryAfter returns how long the given key has to wait for its next token
+func (k *KeyedLimiter) RetryAfter(key string) time.Duration {
+	return k.get(key).RetryAfter()
+}
+
+// Cleanup drops buckets that have fully refilled, since they behave like new ones
+func (k *KeyedLimiter) Cleanup() {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	for key, limiter := range k.limiters {
+		if limiter.full() {
+			delete(k.limiters, key)
+		}
+	}
+}
+
+// StartCleanup periodically runs Cleanup in the background
+func (k *KeyedLimiter) StartCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			k.Cleanup()
+		}
+	}()
+}
+
+func (k *KeyedLimiter) get(key string) *Limiter {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	limiter, ok := k.limiters[key]
+	if !ok {
+		limiter = newLimiter(k.rate, k.burst, k.now)
+		k.limiters[key] = limiter
+	}
+	return limiter
+}
+
+// Lockout blocks a key after too many failures within a time window
+type Lockout struct {
+	maxFailures int
+	window      time.Duration
+	duration    time.Duration
+	entries     map[string]*lockoutEntry
+	mutex       sync.Mutex
+	now         func() time.Time
+}
+
+type lockoutEntry struct {
+	failures    int
+	firstFail   time.Time
+	lockedUntil time.Time
+}
+
+// NewLockout creates a lockout that locks a key for duration after maxFailures
+// failures within window
+func NewLockout(maxFailures int, window, duration time.Duration) *Lockout {
+	return &Lockout{
+		maxFailures: maxFailures,
+		window:      window,
+		duration:    duration,
+		entries:     make(map[string]*lockoutEntry),
+		now:         time.Now,
+	}
+}
+
+// Locked reports whether the key is locked and for how much longer
+func (l *Lockout) Locked(key string) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	entry, ok := l.entries[key]
+	if !ok {
+		return false, 0
+	}
+
+	now := l.now()
+	remaining := entry.lockedUntil.Sub(now)
+	if remaining > 0 {
+		return true, remaining
+	}
+
+	// Forget stale entries so the map doesn't grow without bound
+	if now.Sub(entry.firstFail) > l.window {
+		delete(l.entries, key)
+	}
+	return false, 0
+}
+
+// Fail records a failure for the key and reports whether it is now locked
+func (l *Lockout) Fail(key string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	entry, ok := l.entries[key]
+	if !ok || now.Sub(entry.firstFail) > l.window {
+		entry = &lockoutEntry{firstFail: now}
+		l.entries[key] = entry
+	}
+
+	entry.failures++
+	if entry.failures >= l.maxFailures {
+		entry.lockedUntil = now.Add(l.duration)
+		entry.failures = 0
+		entry.firstFail = now
+		return true
+	}
+	return false
+}
+
+// Reset clears the failures recorded for the key, e.g. after a successful login
+func (l *Lockout) Reset(key string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.entries, key)
+}
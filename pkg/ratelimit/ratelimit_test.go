@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for deterministic tests
+type fakeClock struct {
+	current time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.current
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.current = c.current.Add(d)
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	clock := &fakeClock{current: time.Unix(0, 0)}
+	limiter := newLimiter(1, 3, clock.now)
+
+	// Burst is available immediately
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow() {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+	}
+
+	// Bucket is now empty
+	if limiter.Allow() {
+		t.Errorf("Expected request to be rejected after burst")
+	}
+
+	if retry := limiter.RetryAfter(); retry != time.Second {
+		t.Errorf("Expected retry after 1s, got: %s", retry)
+	}
+
+	// One token refills after a second
+	clock.advance(time.Second)
+	if !limiter.Allow() {
+		t.Errorf("Expected request to be allowed after refill")
+	}
+	if limiter.Allow() {
+		t.Errorf("Expected only one token to have refilled")
+	}
+
+	// Refill never exceeds the burst size
+	clock.advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow() {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+	}
+	if limiter.Allow() {
+		t.Errorf("Expected bucket to be capped at burst size")
+	}
+}
+
+func TestKeyedLimiter_IndependentKeys(t *testing.T) {
+	clock := &fakeClock{current: time.Unix(0, 0)}
+	limiter := NewKeyedLimiter(1, 1)
+	limiter.now = clock.now
+
+	if !limiter.Allow("ip:1.2.3.4") {
+		t.Fatalf("Expected first request to be allowed")
+	}
+	if limiter.Allow("ip:1.2.3.4") {
+		t.Errorf("Expected second request from same key to be rejected")
+	}
+	if !limiter.Allow("ip:5.6.7.8") {
+		t.Errorf("Expected request from another key to be allowed")
+	}
+
+	// Full buckets are dropped on cleanup
+	clock.advance(time.Minute)
+	limiter.Cleanup()
+	if len(limiter.limiters) != 0 {
+		t.Errorf("Expected cleanup to drop idle buckets, got: %d", len(limiter.limiters))
+	}
+}
+
+func TestLockout(t *testing.T) {
+	clock := &fakeClock{current: time.Unix(0, 0)}
+	lockout := NewLockout(3, time.Minute, 5*time.Minute)
+	lockout.now = clock.now
+
+	// Two failures don't lock the key
+	lockout.Fail("alice")
+	lockout.Fail("alice")
+	if locked, _ := lockout.Locked("alice"); locked {
+		t.Fatalf("Expected key not to be locked yet")
+	}
+
+	// Third failure locks it
+	if !lockout.Fail("alice") {
+		t.Errorf("Expected third failure to lock the key")
+	}
+	locked, remaining := lockout.Locked("alice")
+	if !locked || remaining != 5*time.Minute {
+		t.Errorf("Expected key to be locked for 5m, got: %t %s", locked, remaining)
+	}
+
+	// Other keys are unaffected
+	if locked, _ := lockout.Locked("bob"); locked {
+		t.Errorf("Expected other key not to be locked")
+	}
+
+	// Lock expires
+	clock.advance(5 * time.Minute)
+	if locked, _ := lockout.Locked("alice"); locked {
+		t.Errorf("Expected lock to expire")
+	}
+}
+
+func TestLockout_WindowAndReset(t *testing.T) {
+	clock := &fakeClock{current: time.Unix(0, 0)}
+	lockout := NewLockout(2, time.Minute, time.Hour)
+	lockout.now = clock.now
+
+	// Failures outside the window don't accumulate
+	lockout.Fail("alice")
+	clock.advance(2 * time.Minute)
+	if lockout.Fail("alice") {
+		t.Errorf("Expected failures outside the window to be forgotten")
+	}
+
+	// Reset clears recorded failures
+	lockout.Reset("alice")
+	if lockout.Fail("alice") {
+		t.Errorf("Expected reset to clear failures")
+	}
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// ErrNotFound is returned when an object doesn't exist
+var ErrNotFound = errors.New("object not found")
+
+// Storage stores binary objects such as attachments under string keys
+type Storage interface {
+	// Put stores size bytes read from r under key
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object stored under key; the caller must close it
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object stored under key, if any
+	Delete(ctx context.Context, key string) error
+}
+
+// NewFromEnv creates the storage backend selected by STORAGE_BACKEND ("local" or "s3")
+func NewFromEnv() (Storage, error) {
+	backend := os.Getenv("STORAGE_BACKEND")
+	if backend == "" {
+		backend = "local"
+	}
+
+	switch backend {
+	case "local":
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "./data/attachments"
+		}
+		log.Printf("Using local storage in %s", dir)
+		return NewLocalStorage(dir)
+	case "s3":
+		config := S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		}
+		log.Printf("Using S3 storage at %s, bucket %s", config.Endpoint, config.Bucket)
+		return NewS3Storage(config)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", backend)
+	}
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testStorage runs the behavior every backend must share
+func testStorage(t *testing.T, store Storage) {
+	ctx := context.Background()
+	content := []byte("hello attachment")
+
+	// Put and read back
+	err := store.Put(ctx, "room/file name.txt", bytes.NewReader(content), int64(len(content)), "text/plain")
+	if err != nil {
+		t.Fatalf("Expected no error on put, got: %v", err)
+	}
+
+	reader, err := store.Get(ctx, "room/file name.txt")
+	if err != nil {
+		t.Fatalf("Expected no error on get, got: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if !bytes.Equal(data, content) {
+		t.Errorf("Expected %q, got %q", content, data)
+	}
+
+	// Missing objects
+	if _, err := store.Get(ctx, "room/missing.txt"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got: %v", err)
+	}
+
+	// Delete, twice
+	if err := store.Delete(ctx, "room/file name.txt"); err != nil {
+		t.Fatalf("Expected no error on delete, got: %v", err)
+	}
+	if err := store.Delete(ctx, "room/file name.txt"); err != nil {
+		t.Errorf("Expected deleting a missing object to succeed, got: %v", err)
+	}
+	if _, err := store.Get(ctx, "room/file name.txt"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound after delete, got: %v", err)
+	}
+}
+
+func TestLocalStorage(t *testing.T) {
+	store, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	testStorage(t, store)
+}
+
+func TestLocalStorage_RejectsTraversal(t *testing.T) {
+	store, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	for _, key := range []string{"", "../outside", "room/../../outside", "/"} {
+		err := store.Put(context.Background(), key, strings.NewReader("x"), 1, "text/plain")
+		if err == nil {
+			t.Errorf("Expected key %q to be rejected", key)
+		}
+	}
+}
+
+// fakeS3 is a minimal in-memory stand-in for an S3-compatible server
+type fakeS3 struct {
+	objects map[string][]byte
+	mutex   sync.Mutex
+	t       *testing.T
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Every request must be signed
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=test-key/") ||
+		!strings.Contains(authorization, "/us-east-1/s3/aws4_request") ||
+		!strings.Contains(authorization, "Signature=") ||
+		r.Header.Get("X-Amz-Date") == "" ||
+		r.Header.Get("X-Amz-Content-Sha256") != "UNSIGNED-PAYLOAD" {
+		f.t.Errorf("Unsigned or malformed request: %s", authorization)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	key := r.URL.Path
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3Storage(t *testing.T) {
+	fake := &fakeS3{objects: make(map[string][]byte), t: t}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store, err := NewS3Storage(S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "attachments",
+		AccessKey: "test-key",
+		SecretKey: "test-secret",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	testStorage(t, store)
+
+	// Objects are stored path-style under the bucket
+	store.Put(context.Background(), "a/b.txt", strings.NewReader("x"), 1, "text/plain")
+	if _, ok := fake.objects["/attachments/a/b.txt"]; !ok {
+		t.Errorf("Expected object at /attachments/a/b.txt, got: %v", fake.objects)
+	}
+}
+
+func TestS3Storage_ServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("<Error>boom</Error>"))
+	}))
+	defer server.Close()
+
+	store, _ := NewS3Storage(S3Config{Endpoint: server.URL, Bucket: "attachments"})
+
+	err := store.Put(context.Background(), "a.txt", strings.NewReader("x"), 1, "text/plain")
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Expected status error, got: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Secret of the SHA1 test vectors in RFC 6238, appendix B
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	// The RFC lists 8 digit codes; the last 6 digits are the 6 digit codes
+	testCases := []struct {
+		unix     int64
+		expected string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tc := range testCases {
+		code, err := Code(rfcSecret, Step(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if code != tc.expected {
+			t.Errorf("Expected code %s at %d, got: %s", tc.expected, tc.unix, code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, _ := Code(rfcSecret, Step(now))
+	previous, _ := Code(rfcSecret, Step(now)-1)
+	stale, _ := Code(rfcSecret, Step(now)-2)
+
+	if step, ok := Validate(rfcSecret, code, now); !ok || step != Step(now) {
+		t.Errorf("Expected current code to be valid at step %d, got: %d, %t", Step(now), step, ok)
+	}
+	if step, ok := Validate(rfcSecret, previous, now); !ok || step != Step(now)-1 {
+		t.Errorf("Expected previous code to be valid within skew, got: %d, %t", step, ok)
+	}
+	if _, ok := Validate(rfcSecret, stale, now); ok {
+		t.Error("Expected code older than the skew to be rejected")
+	}
+	if _, ok := Validate(rfcSecret, "12345", now); ok {
+		t.Error("Expected short code to be rejected")
+	}
+	if _, ok := Validate("not base32!", code, now); ok {
+		t.Error("Expected invalid secret to be rejected")
+	}
+}
+
+func TestGenerateSecretAndURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("Expected 32 character secret, got: %d", len(secret))
+	}
+
+	uri := URI("Chat Go", "alice smith", secret)
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("Expected a valid URI, got: %v", err)
+	}
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" {
+		t.Errorf("Expected otpauth://totp URI, got: %s", uri)
+	}
+	if !strings.HasPrefix(parsed.Path, "/Chat Go:alice smith") {
+		t.Errorf("Expected issuer and account label, got: %s", parsed.Path)
+	}
+	if parsed.Query().Get("secret") != secret || parsed.Query().Get("issuer") != "Chat Go" {
+		t.Errorf("Expected secret and issuer parameters, got: %s", parsed.RawQuery)
+	}
+}
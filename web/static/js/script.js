@@ -7,19 +7,65 @@ document.addEventListener('DOMContentLoaded', () => {
     const showRegisterLink = document.getElementById('show-register');
     const showLoginLink = document.getElementById('show-login');
     const logoutBtn = document.getElementById('logout-btn');
+    const forgotPasswordLink = document.getElementById('forgot-password');
+    const profileBtn = document.getElementById('profile-btn');
+    const avatarInput = document.getElementById('avatar-input');
+    const changePasswordBtn = document.getElementById('change-password-btn');
+    const twoFactorBtn = document.getElementById('two-factor-btn');
+    const exportDataBtn = document.getElementById('export-data-btn');
+    const scheduledBtn = document.getElementById('scheduled-btn');
+    const scheduleBtn = document.getElementById('schedule-btn');
+    const deleteAccountBtn = document.getElementById('delete-account-btn');
     const messageForm = document.getElementById('message-form');
     const messageInput = document.getElementById('message-input');
     const messagesContainer = document.getElementById('messages');
     const chatroomList = document.getElementById('chatroom-list');
+    const memberList = document.getElementById('member-list');
+    const typingIndicator = document.getElementById('typing-indicator');
+    const attachBtn = document.getElementById('attach-btn');
+    const attachmentInput = document.getElementById('attachment-input');
+    const pendingAttachmentsDiv = document.getElementById('pending-attachments');
+    const notificationsBtn = document.getElementById('notifications-btn');
+    const notificationsCount = document.getElementById('notifications-count');
+    const notificationsPanel = document.getElementById('notifications-panel');
+    const notificationsList = document.getElementById('notifications-list');
+    const notificationsReadAllBtn = document.getElementById('notifications-read-all-btn');
     const createRoomBtn = document.getElementById('create-room-btn');
+    const roomNameHeading = document.getElementById('room-name');
+    const roomTopic = document.getElementById('room-topic');
+    const roomSettingsBtn = document.getElementById('room-settings-btn');
+    const pinnedMessages = document.getElementById('pinned-messages');
     const newRoomNameInput = document.getElementById('new-room-name');
+    const roomSearchInput = document.getElementById('room-search');
+    const roomSortSelect = document.getElementById('room-sort');
+    const loadMoreRoomsBtn = document.getElementById('load-more-rooms-btn');
 
     // State
     let currentUser = null;
     let currentChatroom = null;
     let socket = null;
+    let typingUsers = new Map();
+    let lastTypingSent = 0;
+    let typingStopTimer = null;
+    let receipts = new Map();
+    let lastMessageId = null;
+    let markReadTimer = null;
+    let pendingAttachments = [];
+    let pins = [];
+    let chatrooms = [];
+    let chatroomsNextOffset = null;
+    let roomSearchTimer = null;
 
-    // Check if user is authenticated
+    // Redeem a password reset link or show a failed single sign-on, then
+    // check if user is authenticated
+    const params = new URLSearchParams(window.location.search);
+    if (params.get('reset_token')) {
+        resetPassword(params.get('reset_token'));
+    } else if (params.get('sso_error')) {
+        window.history.replaceState(null, '', window.location.pathname);
+        alert(`Login failed: ${params.get('sso_error')}`);
+    }
+    fetchLoginMethods();
     checkAuth();
 
     // Event listeners
@@ -43,13 +89,15 @@ document.addEventListener('DOMContentLoaded', () => {
         const password = document.getElementById('login-password').value;
 
         try {
-            const response = await fetch('/api/auth/login', {
+            const response = await apiFetch('/api/auth/login', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ username, password })
             });
 
-            if (response.ok) {
+            if (response.status === 202) {
+                verifyTwoFactor();
+            } else if (response.ok) {
                 checkAuth();
             } else {
                 const error = await response.text();
@@ -66,7 +114,7 @@ document.addEventListener('DOMContentLoaded', () => {
         const password = document.getElementById('register-password').value;
 
         try {
-            const response = await fetch('/api/auth/register', {
+            const response = await apiFetch('/api/auth/register', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ username, password })
@@ -85,11 +133,12 @@ document.addEventListener('DOMContentLoaded', () => {
 
     logoutBtn.addEventListener('click', async () => {
         try {
-            const response = await fetch('/api/auth/logout', { method: 'POST' });
+            const response = await apiFetch('/api/auth/logout', { method: 'POST' });
             if (response.ok) {
                 disconnectSocket();
                 currentUser = null;
                 currentChatroom = null;
+                chatrooms = [];
                 authContainer.style.display = 'block';
                 chatContainer.style.display = 'none';
             }
@@ -98,20 +147,344 @@ document.addEventListener('DOMContentLoaded', () => {
         }
     });
 
+    // Schedule the typed message, e.g. an announcement, for a later time
+    scheduleBtn.addEventListener('click', async () => {
+        const content = messageInput.value.trim();
+        if (!currentChatroom || !content) {
+            alert('Type the message to schedule first');
+            return;
+        }
+
+        const inAnHour = new Date(Date.now() + 60 * 60 * 1000);
+        const suggestion = `${inAnHour.getFullYear()}-${String(inAnHour.getMonth() + 1).padStart(2, '0')}-${String(inAnHour.getDate()).padStart(2, '0')} ` +
+            `${String(inAnHour.getHours()).padStart(2, '0')}:${String(inAnHour.getMinutes()).padStart(2, '0')}`;
+        const when = prompt('Send at (YYYY-MM-DD HH:MM, your local time):', suggestion);
+        if (!when) {
+            return;
+        }
+        const sendAt = new Date(when.trim().replace(' ', 'T'));
+        if (isNaN(sendAt.getTime())) {
+            alert('Invalid date and time');
+            return;
+        }
+
+        try {
+            const response = await apiFetch(`/api/chatrooms/${currentChatroom.id}/schedules`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ content, send_at: sendAt.toISOString() })
+            });
+            if (response.ok) {
+                messageInput.value = '';
+                renderNotice(`Message scheduled for ${sendAt.toLocaleString()}`);
+            } else {
+                const error = await response.text();
+                alert(`Failed to schedule message: ${error}`);
+            }
+        } catch (error) {
+            alert(`Error: ${error.message}`);
+        }
+    });
+
+    // List pending scheduled messages and reminders, offering to cancel one
+    scheduledBtn.addEventListener('click', async () => {
+        try {
+            const response = await apiFetch('/api/schedules');
+            if (!response.ok) {
+                alert('Failed to load scheduled messages');
+                return;
+            }
+            const schedules = await response.json();
+            if (schedules.length === 0) {
+                alert('You have no scheduled messages or reminders');
+                return;
+            }
+
+            const lines = schedules.map((schedule, i) =>
+                `${i + 1}. ${new Date(schedule.send_at).toLocaleString()} (${schedule.kind}): ${schedule.content}`);
+            const choice = prompt(`${lines.join('\n')}\n\nType a number to cancel it:`);
+            const schedule = schedules[parseInt(choice, 10) - 1];
+            if (!schedule) {
+                return;
+            }
+
+            const cancelResponse = await apiFetch(`/api/schedules/${schedule.id}`, { method: 'DELETE' });
+            if (!cancelResponse.ok) {
+                const error = await cancelResponse.text();
+                alert(`Failed to cancel: ${error}`);
+            }
+        } catch (error) {
+            alert(`Error: ${error.message}`);
+        }
+    });
+
+    exportDataBtn.addEventListener('click', () => {
+        // The browser downloads the archive using the session cookie
+        window.location.href = '/api/account/export';
+    });
+
+    deleteAccountBtn.addEventListener('click', async () => {
+        const confirmation = prompt(
+            'Deleting your account can\'t be undone. Your messages stay in the chatrooms as "Deleted user".\n\n' +
+            'Enter your password to confirm, or your username if you sign in with single sign-on:'
+        );
+        if (!confirmation) {
+            return;
+        }
+
+        try {
+            const response = await apiFetch('/api/account', {
+                method: 'DELETE',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ password: confirmation, confirm: confirmation })
+            });
+            if (response.ok) {
+                disconnectSocket();
+                currentUser = null;
+                currentChatroom = null;
+                chatrooms = [];
+                authContainer.style.display = 'block';
+                chatContainer.style.display = 'none';
+                alert('Your account was deleted');
+            } else {
+                const error = await response.text();
+                alert(`Failed to delete account: ${error}`);
+            }
+        } catch (error) {
+            alert(`Error: ${error.message}`);
+        }
+    });
+
+    forgotPasswordLink.addEventListener('click', async (e) => {
+        e.preventDefault();
+        const username = prompt('Username of the account to reset:', document.getElementById('login-username').value);
+        if (!username) return;
+
+        try {
+            const response = await apiFetch('/api/auth/password/reset-request', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ username })
+            });
+
+            if (response.ok) {
+                alert('If the account exists, a reset link has been sent.');
+            } else {
+                const error = await response.text();
+                alert(`Password reset failed: ${error}`);
+            }
+        } catch (error) {
+            alert(`Error: ${error.message}`);
+        }
+    });
+
+    profileBtn.addEventListener('click', async () => {
+        const display_name = prompt('Display name (leave empty to use your username):', currentUser.display_name || '');
+        if (display_name === null) return;
+        const status_text = prompt('Status:', currentUser.status_text || '');
+        if (status_text === null) return;
+        const time_zone = prompt('Time zone:', currentUser.time_zone || Intl.DateTimeFormat().resolvedOptions().timeZone);
+        if (time_zone === null) return;
+
+        try {
+            const response = await apiFetch('/api/profile', {
+                method: 'PATCH',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ display_name, status_text, time_zone })
+            });
+            if (!response.ok) {
+                alert(`Profile update failed: ${await response.text()}`);
+                return;
+            }
+            Object.assign(currentUser, await response.json());
+
+            if (confirm('Profile saved. Do you want to change your avatar?')) {
+                avatarInput.click();
+            }
+        } catch (error) {
+            alert(`Error: ${error.message}`);
+        }
+    });
+
+    avatarInput.addEventListener('change', async () => {
+        const file = avatarInput.files[0];
+        avatarInput.value = '';
+        if (!file) return;
+
+        const formData = new FormData();
+        formData.append('file', file);
+        try {
+            const response = await apiFetch('/api/profile/avatar', { method: 'PUT', body: formData });
+            if (response.ok) {
+                Object.assign(currentUser, await response.json());
+            } else {
+                alert(`Avatar upload failed: ${await response.text()}`);
+            }
+        } catch (error) {
+            alert(`Error: ${error.message}`);
+        }
+    });
+
+    changePasswordBtn.addEventListener('click', async () => {
+        const current_password = prompt('Current password:');
+        if (!current_password) return;
+        const new_password = prompt('New password:');
+        if (!new_password) return;
+
+        try {
+            const response = await apiFetch('/api/auth/password', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ current_password, new_password })
+            });
+
+            if (response.ok) {
+                alert('Password changed. Your other sessions have been logged out.');
+            } else {
+                const error = await response.text();
+                alert(`Password change failed: ${error}`);
+            }
+        } catch (error) {
+            alert(`Error: ${error.message}`);
+        }
+    });
+
+    twoFactorBtn.addEventListener('click', async () => {
+        try {
+            const status = await (await apiFetch('/api/auth/2fa')).json();
+
+            if (status.enabled) {
+                const code = prompt(`Two-factor authentication is enabled (${status.recovery_codes_left} recovery codes left).\nEnter a code to disable it:`);
+                if (!code) return;
+                const response = await apiFetch('/api/auth/2fa/disable', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ code })
+                });
+                alert(response.ok ? 'Two-factor authentication disabled.' : `Failed: ${await response.text()}`);
+                return;
+            }
+
+            const enrollResponse = await apiFetch('/api/auth/2fa/enroll', { method: 'POST' });
+            if (!enrollResponse.ok) {
+                alert(`Failed: ${await enrollResponse.text()}`);
+                return;
+            }
+            const enrollment = await enrollResponse.json();
+            const code = prompt(`Add this secret to your authenticator app:\n${enrollment.secret}\n\nor import:\n${enrollment.uri}\n\nThen enter the code it shows:`);
+            if (!code) return;
+
+            const response = await apiFetch('/api/auth/2fa/enable', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ code })
+            });
+            if (response.ok) {
+                const { recovery_codes } = await response.json();
+                alert(`Two-factor authentication enabled. Save these recovery codes; each works once:\n\n${recovery_codes.join('\n')}`);
+            } else {
+                alert(`Failed: ${await response.text()}`);
+            }
+        } catch (error) {
+            alert(`Error: ${error.message}`);
+        }
+    });
+
     messageForm.addEventListener('submit', (e) => {
         e.preventDefault();
         const content = messageInput.value.trim();
-        if (content && socket) {
-            socket.send(JSON.stringify({ content }));
+        if ((content || pendingAttachments.length > 0) && socket) {
+            const attachment_ids = pendingAttachments.map(attachment => attachment.id);
+            socket.send(JSON.stringify({ content, attachment_ids }));
             messageInput.value = '';
+            pendingAttachments = [];
+            renderPendingAttachments();
+            // The server clears our typing indicator when a message is sent
+            clearTimeout(typingStopTimer);
+            lastTypingSent = 0;
+        }
+    });
+
+    attachBtn.addEventListener('click', () => {
+        if (currentChatroom) {
+            attachmentInput.click();
         }
     });
 
+    attachmentInput.addEventListener('change', async () => {
+        const file = attachmentInput.files[0];
+        attachmentInput.value = '';
+        if (!file || !currentChatroom) {
+            return;
+        }
+
+        const formData = new FormData();
+        formData.append('file', file);
+
+        try {
+            const response = await apiFetch(`/api/chatrooms/${currentChatroom.id}/attachments`, {
+                method: 'POST',
+                body: formData
+            });
+
+            if (response.ok) {
+                pendingAttachments.push(await response.json());
+                renderPendingAttachments();
+            } else {
+                const error = await response.text();
+                alert(`Upload failed: ${error}`);
+            }
+        } catch (error) {
+            alert(`Error: ${error.message}`);
+        }
+    });
+
+    notificationsBtn.addEventListener('click', () => {
+        const visible = notificationsPanel.style.display !== 'none';
+        notificationsPanel.style.display = visible ? 'none' : 'block';
+        if (!visible) {
+            fetchNotifications();
+        }
+    });
+
+    notificationsReadAllBtn.addEventListener('click', async () => {
+        try {
+            const response = await apiFetch('/api/notifications/read', { method: 'POST' });
+            if (response.ok) {
+                fetchNotifications();
+            }
+        } catch (error) {
+            console.error(`Error marking notifications as read: ${error.message}`);
+        }
+    });
+
+    messageInput.addEventListener('input', () => {
+        if (!socket || socket.readyState !== WebSocket.OPEN) {
+            return;
+        }
+
+        // Refresh the indicator at most every 3 seconds while typing
+        const now = Date.now();
+        if (now - lastTypingSent > 3000) {
+            socket.send(JSON.stringify({ type: 'typing_start' }));
+            lastTypingSent = now;
+        }
+
+        // Stop after 3 seconds without input
+        clearTimeout(typingStopTimer);
+        typingStopTimer = setTimeout(() => {
+            if (socket && socket.readyState === WebSocket.OPEN) {
+                socket.send(JSON.stringify({ type: 'typing_stop' }));
+            }
+            lastTypingSent = 0;
+        }, 3000);
+    });
+
     createRoomBtn.addEventListener('click', async () => {
         const name = newRoomNameInput.value.trim();
         if (name) {
             try {
-                const response = await fetch('/api/chatrooms', {
+                const response = await apiFetch('/api/chatrooms', {
                     method: 'POST',
                     headers: { 'Content-Type': 'application/json' },
                     body: JSON.stringify({ name })
@@ -131,14 +504,93 @@ document.addEventListener('DOMContentLoaded', () => {
     });
 
     // Functions
+    // apiFetch is fetch with the CSRF token the server requires on
+    // state-changing requests, read from its cookie
+    function apiFetch(url, options = {}) {
+        const method = (options.method || 'GET').toUpperCase();
+        if (!['GET', 'HEAD', 'OPTIONS'].includes(method)) {
+            const match = document.cookie.match(/(?:^|;\s*)csrf_token=([^;]+)/);
+            options.headers = { ...options.headers, 'X-CSRF-Token': match ? match[1] : '' };
+        }
+        return fetch(url, options);
+    }
+
+    async function verifyTwoFactor() {
+        const code = prompt('Enter the code from your authenticator app, or a recovery code:');
+        if (!code) return;
+
+        try {
+            const response = await apiFetch('/api/auth/2fa/verify', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ code })
+            });
+
+            if (response.ok) {
+                checkAuth();
+            } else {
+                const error = await response.text();
+                alert(`Login failed: ${error}`);
+            }
+        } catch (error) {
+            alert(`Error: ${error.message}`);
+        }
+    }
+
+    async function fetchLoginMethods() {
+        try {
+            const response = await apiFetch('/api/auth/methods');
+            if (!response.ok) return;
+            const methods = await response.json();
+
+            if (methods.sso) {
+                document.getElementById('sso-login-link').textContent = `Log in with ${methods.sso_name}`;
+                document.getElementById('sso-login').style.display = 'block';
+            }
+            if (!methods.password) {
+                document.querySelectorAll('.form-container').forEach(container => {
+                    container.style.display = 'none';
+                });
+            }
+        } catch (error) {
+            console.error(`Error fetching login methods: ${error.message}`);
+        }
+    }
+
+    async function resetPassword(token) {
+        // Drop the token from the address bar and history
+        window.history.replaceState(null, '', window.location.pathname);
+
+        const password = prompt('Choose a new password:');
+        if (!password) return;
+
+        try {
+            const response = await apiFetch('/api/auth/password/reset', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ token, password })
+            });
+
+            if (response.ok) {
+                alert('Password reset. You can now log in with your new password.');
+            } else {
+                const error = await response.text();
+                alert(`Password reset failed: ${error}`);
+            }
+        } catch (error) {
+            alert(`Error: ${error.message}`);
+        }
+    }
+
     async function checkAuth() {
         try {
-            const response = await fetch('/api/auth/check');
+            const response = await apiFetch('/api/auth/check');
             if (response.ok) {
                 currentUser = await response.json();
                 authContainer.style.display = 'none';
                 chatContainer.style.display = 'block';
                 fetchChatrooms();
+                fetchNotifications();
             } else {
                 authContainer.style.display = 'block';
                 chatContainer.style.display = 'none';
@@ -150,11 +602,27 @@ document.addEventListener('DOMContentLoaded', () => {
         }
     }
 
-    async function fetchChatrooms() {
+    // fetchChatrooms reloads the room list, keeping as many rooms as were
+    // loaded, or appends the next page with loadMore
+    async function fetchChatrooms(loadMore = false) {
+        const params = new URLSearchParams({ sort: roomSortSelect.value });
+        const search = roomSearchInput.value.trim();
+        if (search) {
+            params.set('q', search);
+        }
+        if (loadMore) {
+            params.set('offset', chatroomsNextOffset);
+        } else if (chatrooms.length > 0) {
+            params.set('limit', Math.min(Math.max(chatrooms.length, 50), 100));
+        }
+
         try {
-            const response = await fetch('/api/chatrooms');
+            const response = await apiFetch(`/api/chatrooms?${params}`);
             if (response.ok) {
-                const chatrooms = await response.json();
+                const page = await response.json();
+                chatrooms = loadMore ? chatrooms.concat(page.chatrooms) : page.chatrooms;
+                chatroomsNextOffset = page.next_offset ?? null;
+                loadMoreRoomsBtn.style.display = chatroomsNextOffset !== null ? '' : 'none';
                 renderChatrooms(chatrooms);
                 if (chatrooms.length > 0 && !currentChatroom) {
                     joinChatroom(chatrooms[0].id);
@@ -165,38 +633,211 @@ document.addEventListener('DOMContentLoaded', () => {
         }
     }
 
+    // Searching or sorting starts the room list over
+    roomSearchInput.addEventListener('input', () => {
+        clearTimeout(roomSearchTimer);
+        roomSearchTimer = setTimeout(() => {
+            chatrooms = [];
+            fetchChatrooms();
+        }, 300);
+    });
+
+    roomSortSelect.addEventListener('change', () => {
+        chatrooms = [];
+        fetchChatrooms();
+    });
+
+    loadMoreRoomsBtn.addEventListener('click', () => fetchChatrooms(true));
+
     function renderChatrooms(chatrooms) {
         chatroomList.innerHTML = '';
         chatrooms.forEach(chatroom => {
             const li = document.createElement('li');
             li.textContent = chatroom.name;
             li.dataset.id = chatroom.id;
+            li.title = `${chatroom.member_count} ${chatroom.member_count === 1 ? 'member' : 'members'}`;
+            if (chatroom.last_message) {
+                const summaryDiv = document.createElement('div');
+                summaryDiv.classList.add('room-summary');
+                summaryDiv.textContent = `${chatroom.last_message.username}: ${chatroom.last_message.content}`;
+                li.appendChild(summaryDiv);
+            }
             if (currentChatroom && chatroom.id === currentChatroom.id) {
                 li.classList.add('active');
+            } else if (chatroom.unread_count > 0) {
+                const badge = document.createElement('span');
+                badge.classList.add('badge');
+                badge.textContent = chatroom.unread_count;
+                li.appendChild(badge);
+            }
+            if (chatroom.archived_at) {
+                li.classList.add('archived');
+            }
+            if (chatroom.can_manage) {
+                const deleteBtn = document.createElement('button');
+                deleteBtn.classList.add('delete-room');
+                deleteBtn.textContent = '×';
+                deleteBtn.title = 'Delete chatroom';
+                deleteBtn.addEventListener('click', (event) => {
+                    event.stopPropagation();
+                    deleteChatroom(chatroom);
+                });
+                li.appendChild(deleteBtn);
             }
             li.addEventListener('click', () => joinChatroom(chatroom.id));
             chatroomList.appendChild(li);
         });
     }
 
+    async function deleteChatroom(chatroom) {
+        if (!confirm(`Delete ${chatroom.name} and all of its messages? This can't be undone.`)) {
+            return;
+        }
+
+        try {
+            const response = await apiFetch(`/api/chatrooms/${chatroom.id}`, { method: 'DELETE' });
+            if (!response.ok) {
+                const error = await response.text();
+                alert(`Failed to delete chatroom: ${error}`);
+            }
+            fetchChatrooms();
+        } catch (error) {
+            alert(`Error: ${error.message}`);
+        }
+    }
+
+    roomSettingsBtn.addEventListener('click', async () => {
+        if (!currentChatroom) {
+            return;
+        }
+        const archiveAction = currentChatroom.archived_at ? 'unarchive' : 'archive';
+        const action = prompt(`Type one of: rename, topic, description, ${archiveAction}, delete`);
+        if (!action) {
+            return;
+        }
+
+        let request;
+        switch (action.trim().toLowerCase()) {
+            case 'rename': {
+                const name = prompt('New name:', currentChatroom.name);
+                if (name === null) return;
+                request = { method: 'PATCH', body: { name } };
+                break;
+            }
+            case 'topic': {
+                const topic = prompt('Topic (one line, leave empty to clear):', currentChatroom.topic);
+                if (topic === null) return;
+                request = { method: 'PATCH', body: { topic } };
+                break;
+            }
+            case 'description': {
+                const description = prompt('Description (leave empty to clear):', currentChatroom.description);
+                if (description === null) return;
+                request = { method: 'PATCH', body: { description } };
+                break;
+            }
+            case archiveAction:
+                request = { method: 'POST', path: `/${archiveAction}` };
+                break;
+            case 'delete':
+                deleteChatroom(currentChatroom);
+                return;
+            default:
+                alert(`Unknown action: ${action}`);
+                return;
+        }
+
+        try {
+            const response = await apiFetch(`/api/chatrooms/${currentChatroom.id}${request.path || ''}`, {
+                method: request.method,
+                headers: { 'Content-Type': 'application/json' },
+                body: request.body ? JSON.stringify(request.body) : undefined
+            });
+            if (!response.ok) {
+                const error = await response.text();
+                alert(`Failed to update chatroom: ${error}`);
+            }
+            // Connected clients, this one included, get a room_updated frame
+        } catch (error) {
+            alert(`Error: ${error.message}`);
+        }
+    });
+
+    // renderRoomHeader shows the current room's name and topic, and turns
+    // the message form off while it's archived
+    function renderRoomHeader() {
+        const archived = Boolean(currentChatroom && currentChatroom.archived_at);
+        roomNameHeading.textContent = currentChatroom ? currentChatroom.name + (archived ? ' (archived)' : '') : '';
+        roomTopic.textContent = currentChatroom ? currentChatroom.topic : '';
+        roomTopic.title = currentChatroom ? currentChatroom.description : '';
+        roomSettingsBtn.style.display = currentChatroom && currentChatroom.can_manage ? '' : 'none';
+        messageInput.disabled = archived;
+        attachBtn.disabled = archived;
+        messageInput.placeholder = archived ? 'This chatroom is archived and read-only' : 'Type a message...';
+        messagesContainer.querySelectorAll('.message[data-id] .pin-toggle').forEach(pinBtn => {
+            renderPinToggle(pinBtn, pinBtn.closest('.message').dataset.id);
+        });
+    }
+
+    // updateChatroom applies a room_updated frame to the room list and header
+    function updateChatroom(chatroom) {
+        if (currentChatroom && currentChatroom.id === chatroom.id) {
+            // Frames are sent to everyone, so they don't say who may manage the room
+            currentChatroom = { ...chatroom, can_manage: currentChatroom.can_manage };
+            renderRoomHeader();
+        }
+        fetchChatrooms();
+    }
+
+    // leaveDeletedChatroom moves to another room once the current one is deleted
+    function leaveDeletedChatroom(chatroomId) {
+        if (!currentChatroom || currentChatroom.id !== chatroomId) {
+            return;
+        }
+        disconnectSocket();
+        messagesContainer.innerHTML = '';
+        memberList.innerHTML = '';
+        currentChatroom = null;
+        renderRoomHeader();
+        pins = [];
+        renderPins();
+        alert('This chatroom was deleted');
+        fetchChatrooms();
+    }
+
     async function joinChatroom(chatroomId) {
         try {
-            const response = await fetch(`/api/chatrooms/${chatroomId}`);
+            const response = await apiFetch(`/api/chatrooms/${chatroomId}`);
             if (response.ok) {
                 const chatroom = await response.json();
                 
                 // Disconnect from previous chatroom
                 disconnectSocket();
                 
-                // Clear messages
+                // Clear messages, members and typing indicators
                 messagesContainer.innerHTML = '';
+                memberList.innerHTML = '';
+                typingUsers.clear();
+                renderTyping();
                 
                 // Update current chatroom
                 currentChatroom = chatroom;
+                renderRoomHeader();
+                pins = chatroom.pins || [];
+                renderPins();
                 
+                // Reset read state and uploads meant for the previous room
+                receipts.clear();
+                lastMessageId = null;
+                pendingAttachments = [];
+                renderPendingAttachments();
+
                 // Update UI
                 document.querySelectorAll('#chatroom-list li').forEach(li => {
                     li.classList.toggle('active', li.dataset.id === chatroomId);
+                    if (li.dataset.id === chatroomId) {
+                        li.querySelectorAll('.badge').forEach(badge => badge.remove());
+                    }
                 });
                 
                 // Connect to new chatroom
@@ -215,7 +856,58 @@ document.addEventListener('DOMContentLoaded', () => {
         
         socket.onmessage = (event) => {
             const message = JSON.parse(event.data);
-            renderMessage(message);
+            if (message.type === 'error') {
+                renderError(message.error);
+            } else if (message.type === 'presence') {
+                renderMembers(message.users);
+            } else if (message.type === 'typing') {
+                updateTyping(message);
+            } else if (message.type === 'receipts') {
+                receipts = new Map(message.receipts.map(receipt => [receipt.user_id, receipt]));
+                renderSeenBy();
+            } else if (message.type === 'mention') {
+                notifyMention(message.message);
+            } else if (message.type === 'read') {
+                receipts.set(message.user_id, message);
+                renderSeenBy();
+            } else if (message.type === 'message_updated') {
+                updateMessage(message.message);
+            } else if (message.type === 'profile') {
+                updateAuthor(message.profile);
+            } else if (message.type === 'room_info') {
+                currentChatroom = { ...message.chatroom, can_manage: message.can_manage };
+                renderRoomHeader();
+                pins = message.pins || [];
+                renderPins();
+            } else if (message.type === 'pins') {
+                pins = message.pins || [];
+                renderPins();
+            } else if (message.type === 'scheduled') {
+                renderNotice(`Reminder set for ${new Date(message.schedule.send_at).toLocaleString()}`);
+            } else if (message.type === 'reminder') {
+                // Reminders are only shown to their author
+                if (currentChatroom && message.reminder.chatroom_id === currentChatroom.id) {
+                    renderNotice(`Reminder: ${message.reminder.content}`);
+                } else {
+                    alert(`Reminder: ${message.reminder.content}`);
+                }
+            } else if (message.type === 'room_updated') {
+                updateChatroom(message.chatroom);
+            } else if (message.type === 'room_deleted') {
+                if (currentChatroom && currentChatroom.id === message.chatroom_id) {
+                    leaveDeletedChatroom(message.chatroom_id);
+                } else {
+                    fetchChatrooms();
+                }
+            } else {
+                typingUsers.delete(message.user_id);
+                renderTyping();
+                renderMessage(message);
+                if (message.id) {
+                    lastMessageId = message.id;
+                    scheduleMarkRead();
+                }
+            }
             
             // Scroll to bottom
             messagesContainer.scrollTop = messagesContainer.scrollHeight;
@@ -238,8 +930,23 @@ document.addEventListener('DOMContentLoaded', () => {
     }
 
     function renderMessage(message) {
+        messagesContainer.appendChild(buildMessage(message));
+    }
+
+    function updateMessage(message) {
+        const existing = messagesContainer.querySelector(`[data-id="${message.id}"]`);
+        if (existing) {
+            existing.replaceWith(buildMessage(message));
+            renderSeenBy();
+        }
+    }
+
+    function buildMessage(message) {
         const messageDiv = document.createElement('div');
         messageDiv.classList.add('message');
+        if (message.id) {
+            messageDiv.dataset.id = message.id;
+        }
         
         // Add class based on message type
         messageDiv.classList.add(`message-${message.type}`);
@@ -248,25 +955,361 @@ document.addEventListener('DOMContentLoaded', () => {
         if (message.type === 'chat' && message.user_id === currentUser.id) {
             messageDiv.classList.add('own');
         }
+
+        // Highlight messages mentioning the current user
+        if (message.mentions && message.mentions.some(mention => mention.user_id === currentUser.id)) {
+            messageDiv.classList.add('mentioned');
+        }
         
         // Add message content
         if (message.type !== 'system') {
-            const usernameDiv = document.createElement('div');
+            messageDiv.dataset.userId = message.user_id;
+            const authorDiv = document.createElement('div');
+            authorDiv.classList.add('author');
+            const avatarImg = document.createElement('img');
+            avatarImg.classList.add('avatar');
+            avatarImg.alt = '';
+            authorDiv.appendChild(avatarImg);
+            const usernameDiv = document.createElement('span');
             usernameDiv.classList.add('username');
-            usernameDiv.textContent = message.username;
-            messageDiv.appendChild(usernameDiv);
+            authorDiv.appendChild(usernameDiv);
+            renderAuthor(authorDiv, message);
+            messageDiv.appendChild(authorDiv);
         }
         
         const contentDiv = document.createElement('div');
         contentDiv.classList.add('content');
-        contentDiv.textContent = message.content;
+        if (message.html) {
+            // Rendered and sanitized by the server
+            contentDiv.innerHTML = message.html;
+        } else {
+            contentDiv.textContent = message.content;
+        }
         messageDiv.appendChild(contentDiv);
         
+        if (message.attachments && message.attachments.length > 0) {
+            messageDiv.appendChild(renderAttachments(message.attachments));
+        }
+
+        if (message.previews && message.previews.length > 0) {
+            message.previews.forEach(preview => {
+                messageDiv.appendChild(renderPreview(preview));
+            });
+        }
+
         const timeDiv = document.createElement('div');
         timeDiv.classList.add('time');
         timeDiv.textContent = new Date(message.created_at).toLocaleTimeString();
         messageDiv.appendChild(timeDiv);
+
+        // Moderators can pin messages that were saved
+        if (message.id && message.type !== 'system') {
+            const pinBtn = document.createElement('button');
+            pinBtn.classList.add('pin-toggle');
+            pinBtn.addEventListener('click', () => togglePin(message.id));
+            messageDiv.appendChild(pinBtn);
+            renderPinToggle(pinBtn, message.id);
+        }
         
-        messagesContainer.appendChild(messageDiv);
+        return messageDiv;
+    }
+
+    // renderPins shows the pinned messages of the current room above its history
+    function renderPins() {
+        pinnedMessages.innerHTML = '';
+        pinnedMessages.style.display = pins.length > 0 ? '' : 'none';
+        pins.forEach(pin => {
+            const pinDiv = document.createElement('div');
+            pinDiv.classList.add('pin');
+            pinDiv.title = `Pinned by ${pin.pinned_by_username} on ${new Date(pin.pinned_at).toLocaleString()}`;
+
+            const textSpan = document.createElement('span');
+            textSpan.textContent = `${pin.message.display_name || pin.message.username}: ${pin.message.content}`;
+            textSpan.addEventListener('click', () => {
+                const messageDiv = messagesContainer.querySelector(`[data-id="${pin.message_id}"]`);
+                if (messageDiv) {
+                    messageDiv.scrollIntoView({ behavior: 'smooth', block: 'center' });
+                }
+            });
+            pinDiv.appendChild(textSpan);
+            pinnedMessages.appendChild(pinDiv);
+        });
+
+        messagesContainer.querySelectorAll('.message[data-id]').forEach(messageDiv => {
+            const pinBtn = messageDiv.querySelector('.pin-toggle');
+            if (pinBtn) {
+                renderPinToggle(pinBtn, messageDiv.dataset.id);
+            }
+        });
+    }
+
+    // renderPinToggle labels a message's pin button, which only moderators
+    // of rooms that aren't archived see
+    function renderPinToggle(pinBtn, messageId) {
+        const pinned = pins.some(pin => pin.message_id === messageId);
+        pinBtn.textContent = pinned ? 'Unpin' : 'Pin';
+        pinBtn.style.display = currentChatroom && currentChatroom.can_manage && !currentChatroom.archived_at ? '' : 'none';
+    }
+
+    async function togglePin(messageId) {
+        if (!currentChatroom) {
+            return;
+        }
+        const pinned = pins.some(pin => pin.message_id === messageId);
+        try {
+            const response = await apiFetch(`/api/chatrooms/${currentChatroom.id}/pins/${messageId}`, {
+                method: pinned ? 'DELETE' : 'PUT'
+            });
+            if (!response.ok) {
+                const error = await response.text();
+                alert(`Failed to ${pinned ? 'unpin' : 'pin'} message: ${error}`);
+            }
+            // The room's clients, this one included, get a pins frame
+        } catch (error) {
+            alert(`Error: ${error.message}`);
+        }
+    }
+
+    // Fills in the name and avatar of a message author from a message or profile
+    function renderAuthor(authorDiv, author) {
+        const usernameDiv = authorDiv.querySelector('.username');
+        usernameDiv.textContent = author.display_name || author.username;
+        usernameDiv.title = `@${author.username}`;
+
+        const avatarImg = authorDiv.querySelector('.avatar');
+        if (author.avatar_url) {
+            avatarImg.src = author.avatar_url;
+            avatarImg.style.display = '';
+        } else {
+            avatarImg.removeAttribute('src');
+            avatarImg.style.display = 'none';
+        }
+    }
+
+    // Applies a changed profile to the messages already shown
+    function updateAuthor(profile) {
+        if (currentUser && profile.user_id === currentUser.id) {
+            Object.assign(currentUser, profile);
+        }
+        messagesContainer.querySelectorAll(`.message[data-user-id="${profile.user_id}"] .author`).forEach(authorDiv => {
+            renderAuthor(authorDiv, profile);
+        });
+    }
+
+    function renderMembers(users) {
+        memberList.innerHTML = '';
+        users.forEach(user => {
+            const li = document.createElement('li');
+            li.textContent = user.display_name || user.username;
+            if (user.status_text) {
+                const status = document.createElement('span');
+                status.classList.add('member-status');
+                status.textContent = user.status_text;
+                li.appendChild(status);
+            }
+            li.title = `@${user.username}`;
+            memberList.appendChild(li);
+        });
+    }
+
+    async function fetchNotifications() {
+        try {
+            const response = await apiFetch('/api/notifications');
+            if (response.ok) {
+                const feed = await response.json();
+                renderNotifications(feed.notifications);
+                notificationsCount.textContent = feed.unread_count > 0 ? feed.unread_count : '';
+            }
+        } catch (error) {
+            console.error(`Error fetching notifications: ${error.message}`);
+        }
+    }
+
+    function renderNotifications(notifications) {
+        notificationsList.innerHTML = '';
+        if (notifications.length === 0) {
+            const li = document.createElement('li');
+            li.textContent = 'No notifications';
+            notificationsList.appendChild(li);
+            return;
+        }
+
+        notifications.forEach(notification => {
+            const li = document.createElement('li');
+            li.textContent = `${notification.actor_username} mentioned you: ${notification.content}`;
+            if (!notification.read_at) {
+                li.classList.add('unread');
+            }
+            li.addEventListener('click', async () => {
+                await apiFetch(`/api/notifications/${notification.id}/read`, { method: 'POST' });
+                notificationsPanel.style.display = 'none';
+                fetchNotifications();
+                if (!currentChatroom || currentChatroom.id !== notification.chatroom_id) {
+                    joinChatroom(notification.chatroom_id);
+                }
+            });
+            notificationsList.appendChild(li);
+        });
+    }
+
+    function notifyMention(message) {
+        // Messages in the current room are already highlighted as they arrive
+        if (!currentChatroom || message.chatroom_id !== currentChatroom.id) {
+            const count = parseInt(notificationsCount.textContent || '0', 10);
+            notificationsCount.textContent = count + 1;
+        } else {
+            fetchNotifications();
+        }
+    }
+
+    function renderAttachments(attachments) {
+        const attachmentsDiv = document.createElement('div');
+        attachmentsDiv.classList.add('attachments');
+        attachments.forEach(attachment => {
+            const link = document.createElement('a');
+            link.href = attachment.url;
+            link.target = '_blank';
+            link.rel = 'noopener';
+            if (attachment.content_type.startsWith('image/')) {
+                const img = document.createElement('img');
+                img.src = attachment.url;
+                img.alt = attachment.filename;
+                link.appendChild(img);
+            } else {
+                link.textContent = `📎 ${attachment.filename}`;
+            }
+            attachmentsDiv.appendChild(link);
+        });
+        return attachmentsDiv;
+    }
+
+    function renderPreview(preview) {
+        const card = document.createElement('a');
+        card.classList.add('link-preview');
+        card.href = preview.url;
+        card.target = '_blank';
+        card.rel = 'nofollow noopener noreferrer';
+
+        if (preview.image_url) {
+            const img = document.createElement('img');
+            img.src = preview.image_url;
+            img.alt = '';
+            img.loading = 'lazy';
+            img.referrerPolicy = 'no-referrer';
+            card.appendChild(img);
+        }
+
+        const textDiv = document.createElement('div');
+        if (preview.site_name) {
+            const siteDiv = document.createElement('div');
+            siteDiv.classList.add('site-name');
+            siteDiv.textContent = preview.site_name;
+            textDiv.appendChild(siteDiv);
+        }
+        const titleDiv = document.createElement('div');
+        titleDiv.classList.add('title');
+        titleDiv.textContent = preview.title;
+        textDiv.appendChild(titleDiv);
+        if (preview.description) {
+            const descriptionDiv = document.createElement('div');
+            descriptionDiv.classList.add('description');
+            descriptionDiv.textContent = preview.description;
+            textDiv.appendChild(descriptionDiv);
+        }
+        card.appendChild(textDiv);
+
+        return card;
+    }
+
+    function renderPendingAttachments() {
+        pendingAttachmentsDiv.innerHTML = '';
+        pendingAttachments.forEach(attachment => {
+            const span = document.createElement('span');
+            span.textContent = attachment.filename;
+            pendingAttachmentsDiv.appendChild(span);
+        });
+    }
+
+    function scheduleMarkRead() {
+        // Batch bursts of messages into a single mark_read frame
+        clearTimeout(markReadTimer);
+        markReadTimer = setTimeout(() => {
+            if (document.visibilityState !== 'visible' || !lastMessageId) {
+                return;
+            }
+            if (socket && socket.readyState === WebSocket.OPEN) {
+                socket.send(JSON.stringify({ type: 'mark_read', message_id: lastMessageId }));
+            }
+        }, 500);
+    }
+
+    document.addEventListener('visibilitychange', () => {
+        if (document.visibilityState === 'visible') {
+            scheduleMarkRead();
+        }
+    });
+
+    function renderSeenBy() {
+        messagesContainer.querySelectorAll('.seen-by').forEach(el => el.remove());
+
+        // Group readers by the message they last read
+        const readers = new Map();
+        receipts.forEach(receipt => {
+            if (receipt.user_id === currentUser.id) {
+                return;
+            }
+            if (!readers.has(receipt.message_id)) {
+                readers.set(receipt.message_id, []);
+            }
+            readers.get(receipt.message_id).push(receipt.username);
+        });
+
+        readers.forEach((names, messageId) => {
+            const messageDiv = messagesContainer.querySelector(`[data-id="${messageId}"]`);
+            if (messageDiv) {
+                const seenDiv = document.createElement('div');
+                seenDiv.classList.add('seen-by');
+                seenDiv.textContent = `Seen by ${names.join(', ')}`;
+                messageDiv.appendChild(seenDiv);
+            }
+        });
+    }
+
+    function updateTyping(frame) {
+        if (frame.user_id === currentUser.id) {
+            return;
+        }
+        if (frame.typing) {
+            typingUsers.set(frame.user_id, frame.username);
+        } else {
+            typingUsers.delete(frame.user_id);
+        }
+        renderTyping();
+    }
+
+    function renderTyping() {
+        const names = Array.from(typingUsers.values());
+        if (names.length === 0) {
+            typingIndicator.textContent = '';
+        } else if (names.length === 1) {
+            typingIndicator.textContent = `${names[0]} is typing…`;
+        } else {
+            typingIndicator.textContent = `${names.join(', ')} are typing…`;
+        }
+    }
+
+    function renderNotice(text) {
+        const noticeDiv = document.createElement('div');
+        noticeDiv.classList.add('message', 'message-system');
+        noticeDiv.textContent = text;
+        messagesContainer.appendChild(noticeDiv);
+        messagesContainer.scrollTop = messagesContainer.scrollHeight;
+    }
+
+    function renderError(error) {
+        const errorDiv = document.createElement('div');
+        errorDiv.classList.add('message', 'message-error');
+        errorDiv.textContent = error;
+        messagesContainer.appendChild(errorDiv);
     }
 }); 
\ No newline at end of file